@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/settings"
+)
+
+// Names of HTTP headers used to authenticate API requests. Client puts id of
+// its API key, current unix timestamp, random nonce and HMAC-SHA256 signature
+// of request into them
+const (
+	APIKeyHeader    = "X-Api-Key"
+	TimestampHeader = "X-Api-Timestamp"
+	NonceHeader     = "X-Api-Nonce"
+	SignatureHeader = "X-Api-Signature"
+)
+
+const nonceLength = 16
+
+// APIKey is a credential that can be used to access API. ID is sent with each
+// request in clear text, Secret is only used to compute request signature and
+// is never sent over the network. Roles determine which API endpoints can be
+// called with this key
+type APIKey struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+	Roles  []Role `mapstructure:"roles"`
+}
+
+// Errors returned when request can't be authenticated
+var (
+	ErrMissingCredentials = errors.New("Request is not signed: API key, timestamp, nonce and signature are required")
+	ErrUnknownAPIKey      = errors.New("Unknown API key")
+	ErrBadTimestamp       = errors.New("Request timestamp is invalid or too far from server time")
+	ErrNonceReused        = errors.New("Request nonce was already used")
+	ErrBadSignature       = errors.New("Request signature is invalid")
+)
+
+func isAuthenticationError(err error) bool {
+	switch err {
+	case ErrMissingCredentials, ErrUnknownAPIKey, ErrBadTimestamp, ErrNonceReused, ErrBadSignature:
+		return true
+	default:
+		return false
+	}
+}
+
+// requestAuthenticator checks signatures of incoming API requests. If no keys
+// are given (which loadAPIKeys only allows when api.auth.disabled is set),
+// authentication is disabled and all requests are accepted
+type requestAuthenticator struct {
+	keys         map[string]*APIKey
+	maxClockSkew time.Duration
+
+	seenNoncesMutex sync.Mutex
+	seenNonces      map[string]time.Time
+}
+
+func loadAPIKeys(s settings.Settings) []*APIKey {
+	var keys []*APIKey
+
+	err := s.GetViper().UnmarshalKey("api.auth.keys", &keys)
+	if err != nil {
+		log.Fatalf("Failed to read API keys from config: %v", err)
+	}
+	if s.GetBool("api.auth.disabled") {
+		if len(keys) > 0 {
+			log.Fatal("Error: api.auth.disabled is set, but api.auth.keys " +
+				"is not empty. Remove one of these settings")
+		}
+		return nil
+	}
+	if len(keys) == 0 {
+		log.Fatal("Error: no API keys configured in api.auth.keys. To run " +
+			"API without authentication, set api.auth.disabled to true")
+	}
+	for _, key := range keys {
+		if key.ID == "" || key.Secret == "" {
+			log.Fatal("Error: each API key in api.auth.keys must have id and secret")
+		}
+	}
+	checkRoles(keys)
+	return keys
+}
+
+func newRequestAuthenticator(keys []*APIKey, maxClockSkew time.Duration) *requestAuthenticator {
+	a := &requestAuthenticator{
+		keys:         make(map[string]*APIKey),
+		maxClockSkew: maxClockSkew,
+		seenNonces:   make(map[string]time.Time),
+	}
+	for _, key := range keys {
+		if _, ok := a.keys[key.ID]; ok {
+			log.Fatalf("Error: duplicate API key id %s", key.ID)
+		}
+		a.keys[key.ID] = key
+	}
+	if !a.enabled() {
+		log.Print("Warning: api.auth.disabled is set, API requests will " +
+			"NOT be authenticated. This should not be used in production")
+	}
+	return a
+}
+
+func (a *requestAuthenticator) enabled() bool {
+	return len(a.keys) > 0
+}
+
+// authenticate checks that request is signed with one of known API keys and
+// returns this key. Request body is read to compute signature and is replaced
+// with a copy, so handlers can read it again
+func (a *requestAuthenticator) authenticate(request *http.Request) (*APIKey, error) {
+	keyID := request.Header.Get(APIKeyHeader)
+	timestampStr := request.Header.Get(TimestampHeader)
+	nonce := request.Header.Get(NonceHeader)
+	signature := request.Header.Get(SignatureHeader)
+
+	if keyID == "" || timestampStr == "" || nonce == "" || signature == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	key, ok := a.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownAPIKey
+	}
+
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return nil, ErrBadTimestamp
+	}
+	requestTime := time.Unix(timestamp, 0)
+	now := time.Now()
+	if requestTime.Before(now.Add(-a.maxClockSkew)) || requestTime.After(now.Add(a.maxClockSkew)) {
+		return nil, ErrBadTimestamp
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	expectedSignature := requestSignature(
+		key.Secret,
+		request.Method,
+		request.URL.RequestURI(),
+		timestampStr,
+		nonce,
+		body,
+	)
+	decodedSignature, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, expectedSignature) {
+		return nil, ErrBadSignature
+	}
+
+	// check nonce only after signature: otherwise anyone could "burn" nonces
+	// of legitimate clients by sending requests with bad signatures
+	if !a.useNonce(keyID+":"+nonce, now) {
+		return nil, ErrNonceReused
+	}
+	return key, nil
+}
+
+// useNonce remembers nonce and returns false if it was already seen. Nonces
+// are forgotten when they become older than allowed timestamp skew, after
+// that request with such nonce will be rejected by timestamp check anyway
+func (a *requestAuthenticator) useNonce(nonce string, now time.Time) bool {
+	a.seenNoncesMutex.Lock()
+	defer a.seenNoncesMutex.Unlock()
+
+	for seenNonce, seenAt := range a.seenNonces {
+		if now.Sub(seenAt) > 2*a.maxClockSkew {
+			delete(a.seenNonces, seenNonce)
+		}
+	}
+	if _, ok := a.seenNonces[nonce]; ok {
+		return false
+	}
+	a.seenNonces[nonce] = now
+	return true
+}
+
+func requestSignature(secret, method, requestURI, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" +
+		nonce + "\n" + hex.EncodeToString(bodyHash[:])))
+	return mac.Sum(nil)
+}
+
+// SignRequest sets authentication headers for API request with given method,
+// request URI (path and query) and body. Signature is HMAC-SHA256 computed with
+// given secret over method, request URI, current timestamp, random nonce and
+// SHA256 hash of body, separated by newlines.
+func SignRequest(header http.Header, method, requestURI string, body []byte, keyID, secret string) error {
+	nonceBytes := make([]byte, nonceLength)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	signature := requestSignature(secret, method, requestURI, timestamp, nonce, body)
+
+	header.Set(APIKeyHeader, keyID)
+	header.Set(TimestampHeader, timestamp)
+	header.Set(NonceHeader, nonce)
+	header.Set(SignatureHeader, hex.EncodeToString(signature))
+	return nil
+}
+
+// authenticated wraps handler so that it is only called for requests signed
+// with a valid API key. Requests that fail authentication get response with
+// HTTP status 401 and error description. API key is stored in request context
+// and can be obtained by handler with apiKeyFromRequest
+func (s *Server) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		if !s.authenticator.enabled() {
+			handler(response, request)
+			return
+		}
+		key, err := s.authenticator.authenticate(request)
+		if err != nil {
+			log.Printf(
+				"Rejecting unauthenticated request to %s from %s: %v",
+				request.URL.Path,
+				request.RemoteAddr,
+				err,
+			)
+			response.WriteHeader(http.StatusUnauthorized)
+			s.respond(response, nil, err)
+			return
+		}
+		handler(response, withAPIKey(request, key))
+	}
+}
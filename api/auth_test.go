@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testKeyID     = "test-key"
+	testKeySecret = "test-secret"
+	testBody      = `{"address": "2NAvKkyAJK7EQChnSCNyWo4ALX5LQt1A4tL"}`
+)
+
+func newTestAuthenticator() *requestAuthenticator {
+	return newRequestAuthenticator(
+		[]*APIKey{{ID: testKeyID, Secret: testKeySecret}},
+		time.Minute,
+	)
+}
+
+func newSignedTestRequest(t *testing.T, keyID, secret string) *http.Request {
+	request, err := http.NewRequest(
+		http.MethodPost,
+		"http://127.0.0.1:8000"+WithdrawURL,
+		bytes.NewReader([]byte(testBody)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SignRequest(
+		request.Header,
+		http.MethodPost,
+		request.URL.RequestURI(),
+		[]byte(testBody),
+		keyID,
+		secret,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return request
+}
+
+func TestAuthenticateValidRequest(t *testing.T) {
+	a := newTestAuthenticator()
+	request := newSignedTestRequest(t, testKeyID, testKeySecret)
+
+	key, err := a.authenticate(request)
+	if err != nil {
+		t.Fatalf("Correctly signed request was rejected: %v", err)
+	}
+	if got, want := key.ID, testKeyID; got != want {
+		t.Errorf("Request authenticated with key %s instead of %s", got, want)
+	}
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(request.Body); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := body.String(), testBody; got != want {
+		t.Errorf("Request body was not preserved: got %q", got)
+	}
+}
+
+func TestAuthenticateRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(*http.Request)
+		wantErr error
+	}{
+		{
+			name: "unsigned",
+			prepare: func(r *http.Request) {
+				r.Header.Del(SignatureHeader)
+			},
+			wantErr: ErrMissingCredentials,
+		},
+		{
+			name: "tampered body",
+			prepare: func(r *http.Request) {
+				r.Body = http.NoBody
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name: "tampered path",
+			prepare: func(r *http.Request) {
+				r.URL.Path = ConfirmURL
+			},
+			wantErr: ErrBadSignature,
+		},
+		{
+			name: "stale timestamp",
+			prepare: func(r *http.Request) {
+				stale := time.Now().Add(-time.Hour).Unix()
+				r.Header.Set(TimestampHeader, strconv.FormatInt(stale, 10))
+			},
+			wantErr: ErrBadTimestamp,
+		},
+	}
+
+	for _, test := range tests {
+		a := newTestAuthenticator()
+		request := newSignedTestRequest(t, testKeyID, testKeySecret)
+		test.prepare(request)
+
+		if _, err := a.authenticate(request); err != test.wantErr {
+			t.Errorf("%s request: expected error %v, got %v",
+				test.name, test.wantErr, err)
+		}
+	}
+}
+
+func TestAuthenticateRejectsUnknownKey(t *testing.T) {
+	a := newTestAuthenticator()
+	request := newSignedTestRequest(t, "other-key", testKeySecret)
+
+	if _, err := a.authenticate(request); err != ErrUnknownAPIKey {
+		t.Errorf("Expected error %v, got %v", ErrUnknownAPIKey, err)
+	}
+}
+
+func TestAuthenticateRejectsReplay(t *testing.T) {
+	a := newTestAuthenticator()
+	request := newSignedTestRequest(t, testKeyID, testKeySecret)
+
+	replayed, err := http.NewRequest(
+		request.Method,
+		request.URL.String(),
+		bytes.NewReader([]byte(testBody)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed.Header = request.Header
+
+	if _, err := a.authenticate(request); err != nil {
+		t.Fatalf("Correctly signed request was rejected: %v", err)
+	}
+	if _, err := a.authenticate(replayed); err != ErrNonceReused {
+		t.Errorf("Expected error %v for replayed request, got %v",
+			ErrNonceReused, err)
+	}
+}
+
+func TestWalletNotifyFromTrustedNetwork(t *testing.T) {
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	_, docker, _ := net.ParseCIDR("172.18.0.0/16")
+	s := &Server{
+		authenticator: newRequestAuthenticator(
+			[]*APIKey{{ID: testKeyID, Secret: testKeySecret, Roles: []Role{DepositorRole}}},
+			time.Minute,
+		),
+		walletNotifyNetworks: []*net.IPNet{loopback, docker},
+	}
+	handler := s.walletNotifyAuthorized(DepositorRole, func(response http.ResponseWriter, request *http.Request) {
+		s.respond(response, nil, nil)
+	})
+
+	tests := []struct {
+		remoteAddr string
+		signed     bool
+		wantStatus int
+	}{
+		{remoteAddr: "127.0.0.1:41000", wantStatus: http.StatusOK},
+		{remoteAddr: "172.18.0.3:41000", wantStatus: http.StatusOK},
+		{remoteAddr: "10.0.0.5:41000", wantStatus: http.StatusUnauthorized},
+		{remoteAddr: "10.0.0.5:41000", signed: true, wantStatus: http.StatusOK},
+	}
+	for _, test := range tests {
+		var request *http.Request
+		if test.signed {
+			request = newSignedTestRequest(t, testKeyID, testKeySecret)
+		} else {
+			request = httptest.NewRequest(http.MethodGet, NotifyWalletURL, nil)
+		}
+		request.RemoteAddr = test.remoteAddr
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+
+		if got := recorder.Code; got != test.wantStatus {
+			t.Errorf("Request from %s (signed: %t): expected status %d, "+
+				"got %d", test.remoteAddr, test.signed, test.wantStatus, got)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/onederx/bitcoin-processing/api"
+	"github.com/onederx/bitcoin-processing/wallet"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// AccelerateDeposit speeds up confirmation of unconfirmed incoming tx with
+// Child-Pays-For-Parent tx. Response is CPFP tx
+func (cli *Client) AccelerateDeposit(request *wallet.AccelerateDepositRequest) (*types.Transaction, error) {
+	var responseData types.Transaction
+
+	err := cli.sendHTTPAPIRequest(api.AccelerateDepositURL, request, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+
+	return &responseData, err
+}
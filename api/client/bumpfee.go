@@ -0,0 +1,21 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/onederx/bitcoin-processing/api"
+	"github.com/onederx/bitcoin-processing/wallet"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// BumpFee replaces bitcoin tx of unconfirmed withdrawal by one paying higher
+// fee. Response is withdrawal with new hash
+func (cli *Client) BumpFee(request *wallet.BumpFeeRequest) (*types.Transaction, error) {
+	var responseData types.Transaction
+
+	err := cli.sendHTTPAPIRequest(api.BumpFeeURL, request, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+
+	return &responseData, err
+}
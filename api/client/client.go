@@ -1,12 +1,42 @@
 package client
 
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
 type Client struct {
 	apiBaseURL       string
 	websocketClients []*WebsocketClient
+
+	apiKeyID     string
+	apiKeySecret string
+
+	httpClient      *http.Client
+	websocketDialer *websocket.Dialer
 }
 
-func NewClient(apiBaseURL string) *Client {
-	return &Client{
-		apiBaseURL: apiBaseURL,
+// Option sets optional parameters of Client, it is passed to NewClient
+type Option func(*Client)
+
+// WithAPIKey makes client sign all requests with given API key. It is needed
+// if API keys are configured on server side (api.auth.keys in server config)
+func WithAPIKey(keyID, secret string) Option {
+	return func(cli *Client) {
+		cli.apiKeyID = keyID
+		cli.apiKeySecret = secret
+	}
+}
+
+func NewClient(apiBaseURL string, options ...Option) *Client {
+	cli := &Client{
+		apiBaseURL:      apiBaseURL,
+		httpClient:      http.DefaultClient,
+		websocketDialer: websocket.DefaultDialer,
+	}
+	for _, option := range options {
+		option(cli)
 	}
+	return cli
 }
@@ -3,14 +3,59 @@ package client
 import (
 	"encoding/json"
 
+	"github.com/gofrs/uuid"
+
 	"github.com/onederx/bitcoin-processing/api"
 	"github.com/onederx/bitcoin-processing/wallet/types"
 )
 
+// GetTransactions fetches all transactions matching filter. Transactions are
+// requested page by page until the last one, filter.Cursor sets position to
+// start from and filter.Limit sets size of each page
 func (cli *Client) GetTransactions(filter *api.GetTransactionsFilter) ([]*types.Transaction, error) {
+	result := make([]*types.Transaction, 0)
+
+	pageFilter := *filter
+	for {
+		txns, nextCursor, err := cli.GetTransactionsPage(&pageFilter)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, txns...)
+		if nextCursor == "" {
+			return result, nil
+		}
+		pageFilter.Cursor = nextCursor
+	}
+}
+
+// GetTransactionsPage fetches one page of transactions matching filter. Besides
+// transactions, it returns cursor of next page which is empty for last page
+func (cli *Client) GetTransactionsPage(filter *api.GetTransactionsFilter) ([]*types.Transaction, string, error) {
+	var responseData []*types.Transaction
+
+	nextCursor, err := cli.sendPagedHTTPAPIRequest(api.GetTransactionsURL, filter, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+	return responseData, nextCursor, err
+}
+
+// GetTransaction fetches transaction with given id
+func (cli *Client) GetTransaction(id uuid.UUID) (*types.Transaction, error) {
+	var responseData types.Transaction
+
+	err := cli.sendHTTPAPIRequest(api.GetTransactionURL, id, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+	return &responseData, err
+}
+
+// GetTransactionsByHash fetches all transactions corresponding to bitcoin tx
+// with given hash
+func (cli *Client) GetTransactionsByHash(hash string) ([]*types.Transaction, error) {
 	var responseData []*types.Transaction
 
-	err := cli.sendHTTPAPIRequest(api.GetTransactionsURL, filter, func(response []byte) error {
+	err := cli.sendHTTPAPIRequest(api.GetTransactionsByHashURL, hash, func(response []byte) error {
 		return json.Unmarshal(response, &responseData)
 	})
 	return responseData, err
@@ -3,8 +3,8 @@ package client
 import (
 	"bytes"
 	"encoding/json"
-	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/onederx/bitcoin-processing/api"
 	"github.com/onederx/bitcoin-processing/util"
@@ -26,29 +26,64 @@ type callbackedHTTPAPIResponse struct {
 	Result callbackedHTTPAPIResult `json:"result"`
 }
 
+// signRequest adds authentication headers to request if client has API key
+func (cli *Client) signRequest(header http.Header, method string, requestURL *url.URL, body []byte) error {
+	if cli.apiKeyID == "" {
+		return nil
+	}
+	return api.SignRequest(
+		header,
+		method,
+		requestURL.RequestURI(),
+		body,
+		cli.apiKeyID,
+		cli.apiKeySecret,
+	)
+}
+
 func (cli *Client) sendHTTPAPIRequest(relativeURL string, request interface{}, resultCb func([]byte) error) error {
-	var requestBody io.Reader
+	_, err := cli.sendPagedHTTPAPIRequest(relativeURL, request, resultCb)
+	return err
+}
+
+// sendPagedHTTPAPIRequest is same as sendHTTPAPIRequest, but also returns
+// cursor of next page from response of paginated API method
+func (cli *Client) sendPagedHTTPAPIRequest(relativeURL string, request interface{}, resultCb func([]byte) error) (string, error) {
+	var requestBody []byte
 
 	if request != nil {
 		requestBodyJSON, err := json.Marshal(request)
 		if err != nil {
-			return err
+			return "", err
 		}
-		requestBody = bytes.NewReader(requestBodyJSON)
-	} else {
-		requestBody = bytes.NewReader(nil)
+		requestBody = requestBodyJSON
 	}
 
 	fullURL, err := util.URLJoin(cli.apiBaseURL, relativeURL)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	resp, err := http.Post(fullURL, "application/json", requestBody)
+	httpRequest, err := http.NewRequest(
+		http.MethodPost,
+		fullURL,
+		bytes.NewReader(requestBody),
+	)
+	if err != nil {
+		return "", err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
 
+	err = cli.signRequest(httpRequest.Header, http.MethodPost, httpRequest.URL, requestBody)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	resp, err := cli.httpClient.Do(httpRequest)
+
+	if err != nil {
+		return "", err
 	}
 
 	defer resp.Body.Close()
@@ -60,11 +95,17 @@ func (cli *Client) sendHTTPAPIRequest(relativeURL string, request interface{}, r
 	err = json.NewDecoder(resp.Body).Decode(&apiResponse)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if apiResponse.Error != "ok" {
-		return apiResponse.Error
+		if apiResponse.ErrorCode != "" {
+			return "", &api.APIError{
+				Code:    apiResponse.ErrorCode,
+				Message: string(apiResponse.Error),
+			}
+		}
+		return "", apiResponse.Error
 	}
-	return nil
+	return apiResponse.NextCursor, nil
 }
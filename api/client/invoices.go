@@ -0,0 +1,32 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/api"
+	"github.com/onederx/bitcoin-processing/wallet"
+)
+
+// CreateInvoice requests new invoice: expected amount to be paid to new
+// address before given time. Response contains this address
+func (cli *Client) CreateInvoice(request *wallet.CreateInvoiceRequest) (*wallet.Invoice, error) {
+	var responseData wallet.Invoice
+
+	err := cli.sendHTTPAPIRequest(api.CreateInvoiceURL, request, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+	return &responseData, err
+}
+
+// GetInvoice fetches invoice with given id along with its status and amount
+// received so far
+func (cli *Client) GetInvoice(id uuid.UUID) (*wallet.Invoice, error) {
+	var responseData wallet.Invoice
+
+	err := cli.sendHTTPAPIRequest(api.GetInvoiceURL, id, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+	return &responseData, err
+}
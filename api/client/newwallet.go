@@ -2,15 +2,81 @@ package client
 
 import (
 	"encoding/json"
+	"net/url"
 
 	"github.com/onederx/bitcoin-processing/api"
 	"github.com/onederx/bitcoin-processing/wallet"
 )
 
 func (cli *Client) NewWallet(metainfo interface{}) (*wallet.Account, error) {
+	return cli.NewWalletWithAddressType(metainfo, "")
+}
+
+// NewWalletWithAddressType creates new account with address of given type
+// ("legacy", "p2sh-segwit", "bech32" or "bech32m"). Empty type means default
+// type set in processing config
+func (cli *Client) NewWalletWithAddressType(metainfo interface{}, addressType string) (*wallet.Account, error) {
+	var responseData wallet.Account
+
+	requestURL := api.NewWalletURL
+	if addressType != "" {
+		requestURL += "?address_type=" + url.QueryEscape(addressType)
+	}
+	err := cli.sendHTTPAPIRequest(requestURL, metainfo, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+	return &responseData, err
+}
+
+// GetAccount fetches account with given address along with its metainfo
+func (cli *Client) GetAccount(address string) (*wallet.Account, error) {
+	var responseData wallet.Account
+
+	err := cli.sendHTTPAPIRequest(api.GetAccountURL, address, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+	return &responseData, err
+}
+
+// GetAccounts fetches all accounts matching filter requesting them page by
+// page (see GetTransactions)
+func (cli *Client) GetAccounts(filter *api.GetAccountsFilter) ([]*wallet.Account, error) {
+	result := make([]*wallet.Account, 0)
+
+	pageFilter := *filter
+	for {
+		accounts, nextCursor, err := cli.GetAccountsPage(&pageFilter)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, accounts...)
+		if nextCursor == "" {
+			return result, nil
+		}
+		pageFilter.Cursor = nextCursor
+	}
+}
+
+// GetAccountsPage fetches one page of accounts matching filter. Besides
+// accounts, it returns cursor of next page which is empty for last page
+func (cli *Client) GetAccountsPage(filter *api.GetAccountsFilter) ([]*wallet.Account, string, error) {
+	var responseData []*wallet.Account
+
+	nextCursor, err := cli.sendPagedHTTPAPIRequest(api.GetAccountsURL, filter, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+	return responseData, nextCursor, err
+}
+
+// UpdateAccountMetainfo replaces metainfo of account with given address
+func (cli *Client) UpdateAccountMetainfo(address string, metainfo map[string]interface{}) (*wallet.Account, error) {
 	var responseData wallet.Account
 
-	err := cli.sendHTTPAPIRequest(api.NewWalletURL, metainfo, func(response []byte) error {
+	request := &api.UpdateAccountMetainfoRequest{
+		Address:  address,
+		Metainfo: metainfo,
+	}
+	err := cli.sendHTTPAPIRequest(api.UpdateAccountMetainfoURL, request, func(response []byte) error {
 		return json.Unmarshal(response, &responseData)
 	})
 	return &responseData, err
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/api"
+	"github.com/onederx/bitcoin-processing/util"
+)
+
+// GetAccountQRCode fetches image of QR code with payment URI of account with
+// given address. Zero fields of params are set to defaults by server
+func (cli *Client) GetAccountQRCode(address string, params *api.QRCodeParams) ([]byte, error) {
+	path := strings.Replace(api.V2AccountQRCodeURL, "{address}", url.PathEscape(address), 1)
+	return cli.getQRCode(path, params)
+}
+
+// GetInvoiceQRCode fetches image of QR code with payment URI of invoice with
+// given id. Zero fields of params are set to defaults by server
+func (cli *Client) GetInvoiceQRCode(id uuid.UUID, params *api.QRCodeParams) ([]byte, error) {
+	path := strings.Replace(api.V2InvoiceQRCodeURL, "{id}", id.String(), 1)
+	return cli.getQRCode(path, params)
+}
+
+// getQRCode requests image from v2 API. Unlike v1 methods, it is a GET
+// request and successful response is not JSON
+func (cli *Client) getQRCode(path string, params *api.QRCodeParams) ([]byte, error) {
+	query := make(url.Values)
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	if params.Scale != 0 {
+		query.Set("scale", strconv.Itoa(params.Scale))
+	}
+	relativeURL := path
+	if len(query) > 0 {
+		relativeURL += "?" + query.Encode()
+	}
+	fullURL, err := util.URLJoin(cli.apiBaseURL, relativeURL)
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequest(http.MethodGet, fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = cli.signRequest(httpRequest.Header, http.MethodGet, httpRequest.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return body, nil
+	}
+
+	var apiResponse api.GenericHTTPAPIResponse
+	if err = json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, err
+	}
+	return nil, &api.APIError{
+		Code:    apiResponse.ErrorCode,
+		Message: string(apiResponse.Error),
+	}
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/onederx/bitcoin-processing/settings"
+)
+
+// AddFlags adds command line flags with API key and TLS parameters of client
+// to given flag set. They should be bound to settings with BindFlags
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String("api-key-id", "", "id of API key to sign requests with")
+	flags.String("api-key-secret", "", "secret of API key to sign requests with")
+	flags.String("ca-file", "", "file with CA certificates to verify API server certificate")
+	flags.String("cert-file", "", "file with client certificate for mutual TLS")
+	flags.String("key-file", "", "file with client certificate key for mutual TLS")
+}
+
+// BindFlags binds flags added by AddFlags to api.client.* settings, so that
+// flags given in command line override values from config file
+func BindFlags(flags *pflag.FlagSet, s settings.Settings) {
+	v := s.GetViper()
+	v.BindPFlag("api.client.key_id", flags.Lookup("api-key-id"))
+	v.BindPFlag("api.client.secret", flags.Lookup("api-key-secret"))
+	v.BindPFlag("api.client.tls.ca_file", flags.Lookup("ca-file"))
+	v.BindPFlag("api.client.tls.cert_file", flags.Lookup("cert-file"))
+	v.BindPFlag("api.client.tls.key_file", flags.Lookup("key-file"))
+}
+
+// NewClientFromSettings creates client for API at given URL with API key
+// and TLS parameters taken from api.client.* settings
+func NewClientFromSettings(apiBaseURL string, s settings.Settings) (*Client, error) {
+	var options []Option
+
+	keyID := s.GetString("api.client.key_id")
+	if keyID != "" {
+		options = append(options, WithAPIKey(keyID, s.GetString("api.client.secret")))
+	}
+
+	caFile := s.GetString("api.client.tls.ca_file")
+	certFile := s.GetString("api.client.tls.cert_file")
+	keyFile := s.GetString("api.client.tls.key_file")
+	if caFile != "" || certFile != "" || keyFile != "" {
+		tlsConfig, err := NewTLSConfig(caFile, certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithTLSConfig(tlsConfig))
+	}
+	return NewClient(apiBaseURL, options...), nil
+}
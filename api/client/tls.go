@@ -0,0 +1,64 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// WithTLSConfig makes client use given TLS config for HTTPS and secure
+// websocket connections. Config can be built with NewTLSConfig
+func WithTLSConfig(config *tls.Config) Option {
+	return func(cli *Client) {
+		cli.httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: config,
+			},
+		}
+		dialer := *websocket.DefaultDialer
+		dialer.TLSClientConfig = config
+		cli.websocketDialer = &dialer
+	}
+}
+
+// NewTLSConfig builds TLS config for client. If caFile is not empty, server
+// certificate is verified using CA certificates from this file instead of
+// system ones (this is needed if server uses self-signed certificate).
+// If certFile and keyFile are not empty, client presents this certificate to
+// server, this is needed if server requires client certificates (has
+// api.http.tls.client_ca_file set in config)
+func NewTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No PEM certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New(
+				"Both certificate and key files are needed to use client " +
+					"certificate",
+			)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -1,12 +1,14 @@
 package client
 
 import (
-	"github.com/gorilla/websocket"
 	"log"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/gorilla/websocket"
+
 	"github.com/onederx/bitcoin-processing/api"
 	"github.com/onederx/bitcoin-processing/events"
 )
@@ -31,7 +33,13 @@ func (cli *Client) NewWebsocketClient(startSeq int, messageCb func(*events.Notif
 
 	log.Printf("Connecting to %s", u.String())
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	header := make(http.Header)
+	err = cli.signRequest(header, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c, _, err := cli.websocketDialer.Dial(u.String(), header)
 	if err != nil {
 		return nil, err
 	}
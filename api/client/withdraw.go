@@ -15,6 +15,18 @@ func (cli *Client) WithdrawToColdStorage(request *wallet.WithdrawRequest) (*wall
 	return cli.withdraw(api.WithdrawToColdStorageURL, request)
 }
 
+// WithdrawBatch requests batch withdrawal: all entries of request are paid by
+// one bitcoin transaction. Response has ids of batch and entries filled
+func (cli *Client) WithdrawBatch(request *wallet.BatchWithdrawRequest) (*wallet.BatchWithdrawRequest, error) {
+	var responseData wallet.BatchWithdrawRequest
+
+	err := cli.sendHTTPAPIRequest(api.WithdrawBatchURL, request, func(response []byte) error {
+		return json.Unmarshal(response, &responseData)
+	})
+
+	return &responseData, err
+}
+
 func (cli *Client) withdraw(relativeURL string, request *wallet.WithdrawRequest) (*wallet.WithdrawRequest, error) {
 	var responseData wallet.WithdrawRequest
 
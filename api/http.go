@@ -6,25 +6,38 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
 	"github.com/onederx/bitcoin-processing/wallet"
+	"github.com/onederx/bitcoin-processing/wallet/types"
 )
 
 const (
 	NewWalletURL                  = "/new_wallet"
 	NotifyWalletURL               = "/notify_wallet"
 	WithdrawURL                   = "/withdraw"
+	WithdrawBatchURL              = "/withdraw_batch"
 	GetHotStorageAddressURL       = "/get_hot_storage_address"
 	GetTransactionsURL            = "/get_transactions"
+	GetTransactionURL             = "/get_transaction"
+	GetTransactionsByHashURL      = "/get_transactions_by_hash"
+	GetAccountURL                 = "/get_account"
+	GetAccountsURL                = "/get_accounts"
+	UpdateAccountMetainfoURL      = "/update_account_metainfo"
+	CreateInvoiceURL              = "/create_invoice"
+	GetInvoiceURL                 = "/get_invoice"
 	GetBalanceURL                 = "/get_balance"
 	GetRequiredFromColdStorageURL = "/get_required_from_cold_storage"
 	CancelPendingURL              = "/cancel_pending"
 	WithdrawToColdStorageURL      = "/withdraw_to_cold_storage"
 	ConfirmURL                    = "/confirm"
+	BumpFeeURL                    = "/bump_fee"
+	AccelerateDepositURL          = "/accelerate_deposit"
 	GetEventsURL                  = "/get_events"
 	MuteEventsURL                 = "/mute_events"
 
@@ -32,11 +45,99 @@ const (
 )
 
 // GetTransactionsFilter describes data sent by client to set up filters in
-// /get_transactions request. Currently, filtering by direction and status
-// is supported, empty value means do not filter
+// /get_transactions request. Empty value of any filter means do not filter,
+// meaning of filters is described in wallet.TransactionsFilter.
+// Transactions are returned in pages of at most Limit txns (100 by default)
+// sorted by creation time in Order ("asc" - default, or "desc"). Response
+// has next_cursor field if there are more txns, it should be passed as Cursor
+// to get next page
 type GetTransactionsFilter struct {
-	Direction string `json:"direction,omitempty"`
-	Status    string `json:"status,omitempty"`
+	Direction     string            `json:"direction,omitempty"`
+	Status        string            `json:"status,omitempty"`
+	Address       string            `json:"address,omitempty"`
+	Hash          string            `json:"hash,omitempty"`
+	BatchID       *uuid.UUID        `json:"batch_id,omitempty"`
+	ParentID      *uuid.UUID        `json:"parent_id,omitempty"`
+	ColdStorage   *bool             `json:"cold_storage,omitempty"`
+	MinAmount     bitcoin.BTCAmount `json:"min_amount,omitempty"`
+	MaxAmount     bitcoin.BTCAmount `json:"max_amount,omitempty"`
+	CreatedAfter  *time.Time        `json:"created_after,omitempty"`
+	CreatedBefore *time.Time        `json:"created_before,omitempty"`
+	UpdatedAfter  *time.Time        `json:"updated_after,omitempty"`
+	UpdatedBefore *time.Time        `json:"updated_before,omitempty"`
+	Metainfo      interface{}       `json:"metainfo,omitempty"`
+
+	Order  string `json:"order,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// GetAccountsFilter describes data sent by client in /get_accounts request.
+// Metainfo selects accounts which metainfo contains given JSON value (like
+// metainfo filter of GetTransactionsFilter), pagination parameters are same
+// as in GetTransactionsFilter, but accounts are sorted by address
+type GetAccountsFilter struct {
+	Metainfo interface{} `json:"metainfo,omitempty"`
+	Limit    int         `json:"limit,omitempty"`
+	Cursor   string      `json:"cursor,omitempty"`
+}
+
+// UpdateAccountMetainfoRequest is sent by client in /update_account_metainfo
+// request to replace metainfo of account with given address
+type UpdateAccountMetainfoRequest struct {
+	Address  string                 `json:"address"`
+	Metainfo map[string]interface{} `json:"metainfo"`
+}
+
+// NewAccountParams are query parameters of /new_wallet request (and v2
+// account creation) which body is account metainfo. AddressType is one of
+// "legacy", "p2sh-segwit", "bech32" and "bech32m", if it is not set,
+// "wallet.address_type" from config is used
+type NewAccountParams struct {
+	AddressType bitcoin.AddressType `json:"address_type,omitempty"`
+}
+
+func addressTypeFromRequest(request *http.Request) (bitcoin.AddressType, error) {
+	addressType, err := bitcoin.AddressTypeFromString(request.URL.Query().Get("address_type"))
+	if err != nil {
+		return bitcoin.InvalidAddressType, invalidRequestError(err)
+	}
+	return addressType, nil
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func uuidOrNil(id *uuid.UUID) uuid.UUID {
+	if id == nil {
+		return uuid.Nil
+	}
+	return *id
+}
+
+func (f *GetTransactionsFilter) walletFilter() wallet.TransactionsFilter {
+	return wallet.TransactionsFilter{
+		Direction:     f.Direction,
+		Status:        f.Status,
+		Address:       f.Address,
+		Hash:          f.Hash,
+		BatchID:       uuidOrNil(f.BatchID),
+		ParentID:      uuidOrNil(f.ParentID),
+		ColdStorage:   f.ColdStorage,
+		MinAmount:     f.MinAmount,
+		MaxAmount:     f.MaxAmount,
+		CreatedAfter:  timeOrZero(f.CreatedAfter),
+		CreatedBefore: timeOrZero(f.CreatedBefore),
+		UpdatedAfter:  timeOrZero(f.UpdatedAfter),
+		UpdatedBefore: timeOrZero(f.UpdatedBefore),
+		Metainfo:      f.Metainfo,
+		Order:         wallet.SortOrder(f.Order),
+		Limit:         f.Limit,
+	}
 }
 
 type HTTPAPIResponseError string
@@ -45,8 +146,55 @@ func (err HTTPAPIResponseError) Error() string {
 	return string(err)
 }
 
+// HTTPAPIErrorCode is a machine-readable code of error returned by API. It is
+// sent along with human-readable error message for errors that clients may
+// want to handle specially
+type HTTPAPIErrorCode string
+
+// Possible error codes.
+// ErrorCodeUnauthenticated means request was not signed with a valid API key
+// ErrorCodePermissionDenied means API key used to sign request has no right
+// to do requested operation
+// ErrorCodeMethodNotAllowed means v2 API resource does not support HTTP method
+// of request
+// Other codes are returned when wallet refuses to process request, they are
+// described in wallet package
+const (
+	ErrorCodeUnauthenticated  HTTPAPIErrorCode = "unauthenticated"
+	ErrorCodePermissionDenied HTTPAPIErrorCode = "permission_denied"
+	ErrorCodeMethodNotAllowed HTTPAPIErrorCode = "method_not_allowed"
+
+	ErrorCodeInvalidRequest     = HTTPAPIErrorCode(wallet.ErrorCodeInvalidRequest)
+	ErrorCodeInsufficientFunds  = HTTPAPIErrorCode(wallet.ErrorCodeInsufficientFunds)
+	ErrorCodeDuplicateID        = HTTPAPIErrorCode(wallet.ErrorCodeDuplicateID)
+	ErrorCodeAmountBelowMinimum = HTTPAPIErrorCode(wallet.ErrorCodeAmountBelowMinimum)
+	ErrorCodeFeeBelowMinimum    = HTTPAPIErrorCode(wallet.ErrorCodeFeeBelowMinimum)
+	ErrorCodeNotPending         = HTTPAPIErrorCode(wallet.ErrorCodeNotPending)
+	ErrorCodeHotWalletAddress   = HTTPAPIErrorCode(wallet.ErrorCodeHotWalletAddress)
+	ErrorCodeNotFound           = HTTPAPIErrorCode(wallet.ErrorCodeNotFound)
+	ErrorCodeSelfApproval       = HTTPAPIErrorCode(wallet.ErrorCodeSelfApproval)
+	ErrorCodeDuplicateApproval  = HTTPAPIErrorCode(wallet.ErrorCodeDuplicateApproval)
+	ErrorCodeNotReplaceable     = HTTPAPIErrorCode(wallet.ErrorCodeNotReplaceable)
+	ErrorCodeNotAcceleratable   = HTTPAPIErrorCode(wallet.ErrorCodeNotAcceleratable)
+)
+
+// APIError is an error with machine-readable code returned by API. Client
+// returns it instead of HTTPAPIResponseError when response has error code
+type APIError struct {
+	Code    HTTPAPIErrorCode
+	Message string
+}
+
+func (err *APIError) Error() string {
+	return err.Message
+}
+
 type GenericHTTPAPIResponse struct {
-	Error HTTPAPIResponseError `json:"error"`
+	Error     HTTPAPIResponseError `json:"error"`
+	ErrorCode HTTPAPIErrorCode     `json:"error_code,omitempty"`
+
+	// NextCursor is set in paginated responses if there is next page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type BalanceInfo struct {
@@ -59,12 +207,41 @@ type httpAPIResponse struct {
 	Result interface{} `json:"result"`
 }
 
+func errorCode(err error) HTTPAPIErrorCode {
+	if isAuthenticationError(err) {
+		return ErrorCodeUnauthenticated
+	}
+	switch e := err.(type) {
+	case *PermissionDeniedError:
+		return ErrorCodePermissionDenied
+	case *APIError:
+		return e.Code
+	case *wallet.Error:
+		return HTTPAPIErrorCode(e.Code)
+	}
+	return ""
+}
+
+// invalidRequestError is returned when request body can't be parsed
+func invalidRequestError(err error) error {
+	return &APIError{
+		Code:    ErrorCodeInvalidRequest,
+		Message: "Invalid request: " + err.Error(),
+	}
+}
+
 func (s *Server) respond(response http.ResponseWriter, data interface{}, err error) {
+	s.respondPage(response, data, "", err)
+}
+
+// respondPage sends one page of paginated result along with cursor of next page
+func (s *Server) respondPage(response http.ResponseWriter, data interface{}, nextCursor string, err error) {
 	var responseBody []byte
 	if err != nil {
 		responseBody, err = json.Marshal(httpAPIResponse{
 			GenericHTTPAPIResponse: GenericHTTPAPIResponse{
-				Error: HTTPAPIResponseError(err.Error()),
+				Error:     HTTPAPIResponseError(err.Error()),
+				ErrorCode: errorCode(err),
 			}},
 		)
 		if err != nil {
@@ -81,8 +258,11 @@ func (s *Server) respond(response http.ResponseWriter, data interface{}, err err
 		return
 	}
 	responseBody, err = json.Marshal(httpAPIResponse{
-		GenericHTTPAPIResponse: GenericHTTPAPIResponse{Error: "ok"},
-		Result:                 data,
+		GenericHTTPAPIResponse: GenericHTTPAPIResponse{
+			Error:      "ok",
+			NextCursor: nextCursor,
+		},
+		Result: data,
 	})
 	if err != nil {
 		panic("Failed to marshal ok response for error " + err.Error())
@@ -114,7 +294,12 @@ func (s *Server) newBitcoinAddress(response http.ResponseWriter, request *http.R
 	} else {
 		metainfo = nil
 	}
-	account, err := s.wallet.CreateAccount(metainfo)
+	addressType, err := addressTypeFromRequest(request)
+	if err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	account, err := s.wallet.CreateAccount(metainfo, addressType)
 	s.respond(response, account, err)
 }
 
@@ -123,30 +308,87 @@ func (s *Server) notifyWalletTxStatusChanged(response http.ResponseWriter, reque
 	s.respond(response, nil, nil)
 }
 
-func (s *Server) withdraw(toColdStorage bool, response http.ResponseWriter, request *http.Request) {
+// decodeWithdrawRequest reads withdraw request from request body and fills
+// fields that were not set by client with default values
+func (s *Server) decodeWithdrawRequest(request *http.Request) (*wallet.WithdrawRequest, error) {
 	var req wallet.WithdrawRequest
 
 	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
-		s.respond(response, nil, err)
-		return
+		return nil, invalidRequestError(err)
 	}
 	if req.ID == uuid.Nil {
-		if !s.allowWithdrawalWithoutID {
-			s.respond(
-				response,
-				nil,
-				fmt.Errorf("Withdrawal without id is not allowed"),
-			)
-			return
+		id, err := s.newWithdrawalID()
+		if err != nil {
+			return nil, err
 		}
-		req.ID = uuid.Must(uuid.NewV4())
-		log.Printf("Generated new withdrawal id %s", req.ID)
+		req.ID = id
 	}
 	if req.FeeType == "" {
 		log.Printf("Fee type not specified: setting to 'fixed' by default")
 		req.FeeType = "fixed"
 	}
-	err := s.wallet.Withdraw(&req, toColdStorage)
+	req.CreatedBy = apiKeyIDFromRequest(request)
+	return &req, nil
+}
+
+// newWithdrawalID generates id for withdrawal which client sent without one,
+// if this is allowed by config
+func (s *Server) newWithdrawalID() (uuid.UUID, error) {
+	if !s.allowWithdrawalWithoutID {
+		return uuid.Nil, &APIError{
+			Code:    ErrorCodeInvalidRequest,
+			Message: "Withdrawal without id is not allowed",
+		}
+	}
+	id := uuid.Must(uuid.NewV4())
+	log.Printf("Generated new withdrawal id %s", id)
+	return id, nil
+}
+
+// decodeBatchWithdrawRequest reads batch withdraw request from request body
+// and generates ids of entries that were not set by client
+func (s *Server) decodeBatchWithdrawRequest(request *http.Request) (*wallet.BatchWithdrawRequest, error) {
+	var req wallet.BatchWithdrawRequest
+
+	if err := decodeRequestBody(request, &req); err != nil {
+		return nil, err
+	}
+	for _, entry := range req.Withdrawals {
+		if entry == nil {
+			return nil, &APIError{
+				Code:    ErrorCodeInvalidRequest,
+				Message: "Batch withdrawal entry is null",
+			}
+		}
+		if entry.ID == uuid.Nil {
+			id, err := s.newWithdrawalID()
+			if err != nil {
+				return nil, err
+			}
+			entry.ID = id
+		}
+	}
+	req.CreatedBy = apiKeyIDFromRequest(request)
+	return &req, nil
+}
+
+func (s *Server) withdrawBatch(response http.ResponseWriter, request *http.Request) {
+	req, err := s.decodeBatchWithdrawRequest(request)
+	if err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	err = s.wallet.WithdrawBatch(req)
+	s.respond(response, req, err)
+}
+
+func (s *Server) withdraw(toColdStorage bool, response http.ResponseWriter, request *http.Request) {
+	req, err := s.decodeWithdrawRequest(request)
+	if err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	err = s.wallet.Withdraw(req, toColdStorage)
 	s.respond(response, req, err)
 }
 
@@ -177,11 +419,119 @@ func (s *Server) getTransactions(response http.ResponseWriter, request *http.Req
 			return
 		}
 	}
-	txns, err := s.wallet.GetTransactionsWithFilter(txFilter.Direction, txFilter.Status)
+	txns, nextCursor, err := s.wallet.GetTransactions(
+		txFilter.walletFilter(),
+		txFilter.Cursor,
+	)
+
+	s.respondPage(response, txns, nextCursor, err)
+}
+
+// decodeRequestBody unmarshals JSON request body into v
+func decodeRequestBody(request *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return invalidRequestError(err)
+	}
+	return nil
+}
+
+func (s *Server) getTransaction(response http.ResponseWriter, request *http.Request) {
+	var id uuid.UUID
+
+	if err := decodeRequestBody(request, &id); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	tx, err := s.wallet.GetTransactionByID(id)
+	s.respond(response, tx, err)
+}
+
+func (s *Server) getTransactionsByHash(response http.ResponseWriter, request *http.Request) {
+	var hash string
 
+	if err := decodeRequestBody(request, &hash); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	txns, err := s.wallet.GetTransactionsByHash(hash)
 	s.respond(response, txns, err)
 }
 
+func (s *Server) getAccount(response http.ResponseWriter, request *http.Request) {
+	var address string
+
+	if err := decodeRequestBody(request, &address); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	account, err := s.wallet.GetAccount(address)
+	s.respond(response, account, err)
+}
+
+func (s *Server) getAccounts(response http.ResponseWriter, request *http.Request) {
+	var filter GetAccountsFilter
+	var body []byte
+	var err error
+
+	if body, err = ioutil.ReadAll(request.Body); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &filter); err != nil {
+			s.respond(response, nil, invalidRequestError(err))
+			return
+		}
+	}
+	accounts, nextCursor, err := s.wallet.GetAccounts(
+		wallet.AccountsFilter{Metainfo: filter.Metainfo, Limit: filter.Limit},
+		filter.Cursor,
+	)
+	s.respondPage(response, accounts, nextCursor, err)
+}
+
+func (s *Server) updateAccountMetainfo(response http.ResponseWriter, request *http.Request) {
+	var req UpdateAccountMetainfoRequest
+
+	if err := decodeRequestBody(request, &req); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	account, err := s.wallet.UpdateAccountMetainfo(
+		req.Address,
+		req.Metainfo,
+		apiKeyIDFromRequest(request),
+	)
+	s.respond(response, account, err)
+}
+
+func (s *Server) createInvoice(response http.ResponseWriter, request *http.Request) {
+	var req wallet.CreateInvoiceRequest
+
+	if err := decodeRequestBody(request, &req); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	req.CreatedBy = apiKeyIDFromRequest(request)
+	invoice, err := s.wallet.CreateInvoice(&req)
+	s.respond(response, invoice, err)
+}
+
+func (s *Server) getInvoice(response http.ResponseWriter, request *http.Request) {
+	var id uuid.UUID
+
+	if err := decodeRequestBody(request, &id); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	invoice, err := s.wallet.GetInvoice(id)
+	s.respond(response, invoice, err)
+}
+
 func (s *Server) getBalance(response http.ResponseWriter, request *http.Request) {
 	var respData BalanceInfo
 	var err error
@@ -225,10 +575,38 @@ func (s *Server) confirmPendingTransaction(response http.ResponseWriter, request
 		s.respond(response, nil, err)
 		return
 	}
-	err = s.wallet.ConfirmPendingTransaction(id)
+	err = s.wallet.ConfirmPendingTransaction(id, apiKeyIDFromRequest(request))
 	s.respond(response, nil, err)
 }
 
+// bumpFee replaces bitcoin tx of unconfirmed or dropped withdrawal by one
+// paying higher fee and responds with updated withdrawal
+func (s *Server) bumpFee(response http.ResponseWriter, request *http.Request) {
+	var req wallet.BumpFeeRequest
+
+	if err := decodeRequestBody(request, &req); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	if err := s.wallet.BumpFee(&req); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	tx, err := s.wallet.GetTransactionByID(req.ID)
+	s.respond(response, tx, err)
+}
+
+func (s *Server) accelerateDeposit(response http.ResponseWriter, request *http.Request) {
+	var req wallet.AccelerateDepositRequest
+
+	if err := decodeRequestBody(request, &req); err != nil {
+		s.respond(response, nil, err)
+		return
+	}
+	tx, err := s.wallet.AccelerateDeposit(&req)
+	s.respond(response, tx, err)
+}
+
 func (s *Server) getEvents(response http.ResponseWriter, request *http.Request) {
 	var body []byte
 	var err error
@@ -270,20 +648,224 @@ func (s *Server) muteEvents(response http.ResponseWriter, request *http.Request)
 	s.respond(response, nil, err)
 }
 
+// v1Routes describes v1 API endpoints. All of them are called with POST (in
+// fact, any method is accepted) and respond with status 200 unless request
+// fails authentication
+func (s *Server) v1Routes() []route {
+	return []route{
+		{
+			method:   http.MethodPost,
+			path:     NewWalletURL,
+			role:     DepositorRole,
+			handler:  s.newBitcoinAddress,
+			summary:  "Create new account (generate new address to receive payments)",
+			request:  map[string]interface{}{},
+			query:    NewAccountParams{},
+			response: wallet.Account{},
+		},
+		{
+			method:  http.MethodPost,
+			path:    NotifyWalletURL,
+			role:    DepositorRole,
+			handler: s.notifyWalletTxStatusChanged,
+			summary: "Notify processing that wallet has updates (called by walletnotify)",
+
+			walletNotify: true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     WithdrawURL,
+			role:     WithdrawerRole,
+			handler:  s.withdrawRegular,
+			summary:  "Request withdrawal",
+			request:  wallet.WithdrawRequest{},
+			response: wallet.WithdrawRequest{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     WithdrawBatchURL,
+			role:     WithdrawerRole,
+			handler:  s.withdrawBatch,
+			summary:  "Request batch withdrawal paid by one bitcoin transaction",
+			request:  wallet.BatchWithdrawRequest{},
+			response: wallet.BatchWithdrawRequest{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetHotStorageAddressURL,
+			role:     ReaderRole,
+			handler:  s.getHotStorageAddress,
+			summary:  "Get hot wallet address",
+			response: "",
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetTransactionsURL,
+			role:     ReaderRole,
+			handler:  s.getTransactions,
+			summary:  "Get page of transactions",
+			request:  GetTransactionsFilter{},
+			response: []*types.Transaction{},
+			paged:    true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetTransactionURL,
+			role:     ReaderRole,
+			handler:  s.getTransaction,
+			summary:  "Get transaction given its id",
+			request:  uuid.UUID{},
+			response: types.Transaction{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetTransactionsByHashURL,
+			role:     ReaderRole,
+			handler:  s.getTransactionsByHash,
+			summary:  "Get all transactions corresponding to bitcoin tx with given hash",
+			request:  "",
+			response: []*types.Transaction{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetAccountURL,
+			role:     ReaderRole,
+			handler:  s.getAccount,
+			summary:  "Get account given its address",
+			request:  "",
+			response: wallet.Account{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetAccountsURL,
+			role:     ReaderRole,
+			handler:  s.getAccounts,
+			summary:  "Get page of accounts, optionally filtered by metainfo",
+			request:  GetAccountsFilter{},
+			response: []*wallet.Account{},
+			paged:    true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     UpdateAccountMetainfoURL,
+			role:     AdminRole,
+			handler:  s.updateAccountMetainfo,
+			summary:  "Replace metainfo of account given its address",
+			request:  UpdateAccountMetainfoRequest{},
+			response: wallet.Account{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     CreateInvoiceURL,
+			role:     DepositorRole,
+			handler:  s.createInvoice,
+			summary:  "Create invoice: request to pay expected amount to new address before given time",
+			request:  wallet.CreateInvoiceRequest{},
+			response: wallet.Invoice{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetInvoiceURL,
+			role:     ReaderRole,
+			handler:  s.getInvoice,
+			summary:  "Get invoice given its id",
+			request:  uuid.UUID{},
+			response: wallet.Invoice{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetBalanceURL,
+			role:     ReaderRole,
+			handler:  s.getBalance,
+			summary:  "Get wallet balance",
+			response: BalanceInfo{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetRequiredFromColdStorageURL,
+			role:     ReaderRole,
+			handler:  s.getRequiredFromColdStorage,
+			summary:  "Get amount that should be transferred from cold storage to fund pending withdrawals",
+			response: bitcoin.BTCAmount(0),
+		},
+		{
+			method:  http.MethodPost,
+			path:    CancelPendingURL,
+			role:    ApproverRole,
+			handler: s.cancelPending,
+			summary: "Cancel pending withdrawal given its id",
+			request: uuid.UUID{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     WithdrawToColdStorageURL,
+			role:     AdminRole,
+			handler:  s.withdrawToColdStorage,
+			summary:  "Request withdrawal to cold storage",
+			request:  wallet.WithdrawRequest{},
+			response: wallet.WithdrawRequest{},
+		},
+		{
+			method:  http.MethodPost,
+			path:    ConfirmURL,
+			role:    ApproverRole,
+			handler: s.confirmPendingTransaction,
+			summary: "Approve withdrawal pending manual confirmation given its id",
+			request: uuid.UUID{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     BumpFeeURL,
+			role:     WithdrawerRole,
+			handler:  s.bumpFee,
+			summary:  "Bump fee of unconfirmed or dropped withdrawal given its id (replace-by-fee)",
+			request:  wallet.BumpFeeRequest{},
+			response: types.Transaction{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     AccelerateDepositURL,
+			role:     AdminRole,
+			handler:  s.accelerateDeposit,
+			summary:  "Accelerate unconfirmed deposit given its id with Child-Pays-For-Parent tx",
+			request:  wallet.AccelerateDepositRequest{},
+			response: types.Transaction{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     GetEventsURL,
+			role:     ReaderRole,
+			handler:  s.getEvents,
+			summary:  "Get events starting from given sequence number",
+			request:  SubscribeMessage{},
+			response: []*events.NotificationWithSeq{},
+		},
+		{
+			method:  http.MethodPost,
+			path:    MuteEventsURL,
+			role:    AdminRole,
+			handler: s.muteEvents,
+			summary: "Stop sending events about tx with given id (or \"current_problematic\")",
+			request: uuid.UUID{},
+		},
+	}
+}
+
+// handlerRegistrar is a multiplexer API endpoints are registered with, it is
+// http.ServeMux
+type handlerRegistrar interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
 func (s *Server) initHTTPAPIServer() {
-	m := s.httpServer.Handler.(*http.ServeMux)
-	m.HandleFunc(NewWalletURL, s.newBitcoinAddress)
-	m.HandleFunc(NotifyWalletURL, s.notifyWalletTxStatusChanged)
-	m.HandleFunc(WithdrawURL, s.withdrawRegular)
-	m.HandleFunc(GetHotStorageAddressURL, s.getHotStorageAddress)
-	m.HandleFunc(GetTransactionsURL, s.getTransactions)
-	m.HandleFunc(GetBalanceURL, s.getBalance)
-	m.HandleFunc(GetRequiredFromColdStorageURL, s.getRequiredFromColdStorage)
-	m.HandleFunc(CancelPendingURL, s.cancelPending)
-	m.HandleFunc(WithdrawToColdStorageURL, s.withdrawToColdStorage)
-	m.HandleFunc(ConfirmURL, s.confirmPendingTransaction)
-	m.HandleFunc(GetEventsURL, s.getEvents)
-	m.HandleFunc(MuteEventsURL, s.muteEvents)
+	m := s.httpServer.Handler.(handlerRegistrar)
+	for _, r := range s.v1Routes() {
+		m.HandleFunc(r.path, s.routeHandler(r))
+	}
+
+	m.Handle(v2Prefix+"/", s.newV2Router())
 
+	m.HandleFunc(openAPISpecURL, s.serveOpenAPISpec)
 	m.Handle(metricsEndpoint, promhttp.Handler())
 }
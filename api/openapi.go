@@ -0,0 +1,454 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const openAPISpecURL = "/openapi.json"
+
+// OpenAPI spec is generated from route tables (v1Routes and v2Routes) and
+// types of request and response bodies using reflection, so it is always in
+// sync with code. Committed copy of spec (api/openapi.json) is checked against
+// generated one by tests.
+
+type schema map[string]interface{}
+
+// schemaGenerator builds JSON schemas of Go types. Schemas of struct types
+// are stored in components and referenced by name
+type schemaGenerator struct {
+	components map[string]schema
+}
+
+var knownTypeSchemas = map[reflect.Type]schema{
+	reflect.TypeOf(bitcoin.BTCAmount(0)): {
+		"type":        "string",
+		"description": "Amount of BTC as a decimal number in a string",
+		"example":     "0.001",
+	},
+	reflect.TypeOf(uuid.UUID{}): {"type": "string", "format": "uuid"},
+	reflect.TypeOf(time.Time{}): {"type": "string", "format": "date-time"},
+}
+
+// enumTypes maps types that are serialized as strings to first valid value.
+// Values are enumerated from it until String() returns "invalid"
+var enumTypes = map[reflect.Type]int64{
+	reflect.TypeOf(types.TransactionStatus(0)):    int64(types.NewTransaction),
+	reflect.TypeOf(types.TransactionDirection(0)): int64(types.IncomingDirection),
+	reflect.TypeOf(bitcoin.FeeType(0)):            int64(bitcoin.PerKBRateFee),
+	reflect.TypeOf(bitcoin.FeePayer(0)):           int64(bitcoin.RecipientPaysFee),
+	reflect.TypeOf(bitcoin.AddressType(0)):        int64(bitcoin.LegacyAddress),
+	reflect.TypeOf(events.EventType(0)):           int64(events.NewAddressEvent),
+	reflect.TypeOf(wallet.InvoiceStatus(0)):       int64(wallet.UnpaidInvoice),
+}
+
+func enumValues(t reflect.Type, first int64) []string {
+	var values []string
+	value := reflect.New(t).Elem()
+
+	for i := first; ; i++ {
+		value.SetInt(i)
+		str := value.Interface().(fmt.Stringer).String()
+		if str == "invalid" {
+			return values
+		}
+		values = append(values, str)
+	}
+}
+
+func (g *schemaGenerator) schemaOf(value interface{}) schema {
+	return g.schemaOfType(reflect.TypeOf(value))
+}
+
+func (g *schemaGenerator) schemaOfType(t reflect.Type) schema {
+	if s, ok := knownTypeSchemas[t]; ok {
+		return s
+	}
+	if first, ok := enumTypes[t]; ok {
+		return schema{"type": "string", "enum": enumValues(t, first)}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOfType(t.Elem())
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": g.schemaOfType(t.Elem())}
+	case reflect.Map:
+		return schema{
+			"type":                 "object",
+			"additionalProperties": g.schemaOfType(t.Elem()),
+		}
+	case reflect.Interface:
+		// any JSON value
+		return schema{}
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		panic("OpenAPI spec: unsupported type " + t.String())
+	}
+}
+
+func (g *schemaGenerator) structRef(t reflect.Type) schema {
+	name := t.Name()
+	if existing, ok := g.components[name]; ok {
+		if existing["x-go-type"] != t.String() {
+			panic("OpenAPI spec: duplicate schema name " + name)
+		}
+	} else {
+		// placeholder prevents infinite recursion on self-referencing types
+		g.components[name] = schema{"x-go-type": t.String()}
+		properties := make(map[string]interface{})
+		g.addStructProperties(t, properties)
+		g.components[name] = schema{
+			"type":       "object",
+			"properties": properties,
+			"x-go-type":  t.String(),
+		}
+	}
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonFieldName returns name of struct field in JSON or empty string if field
+// is not serialized
+func jsonFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" && !field.Anonymous {
+		return "" // unexported
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func (g *schemaGenerator) addStructProperties(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			// fields of embedded structs are serialized as if they were
+			// fields of outer struct
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			g.addStructProperties(embedded, properties)
+			continue
+		}
+		if name := jsonFieldName(field); name != "" {
+			properties[name] = g.schemaOfType(field.Type)
+		}
+	}
+}
+
+func (g *schemaGenerator) responseSchema(result interface{}, paged bool) schema {
+	properties := map[string]interface{}{
+		"error": schema{
+			"type":        "string",
+			"description": "\"ok\" or error description",
+		},
+		"error_code": schema{
+			"type": "string",
+			"enum": errorCodes(),
+		},
+	}
+	if result != nil {
+		properties["result"] = g.schemaOf(result)
+	}
+	if paged {
+		properties["next_cursor"] = schema{
+			"type":        "string",
+			"description": "Cursor of next page, absent on last page",
+		}
+	}
+	return schema{"type": "object", "properties": properties}
+}
+
+// errorCodes lists all error codes, they are taken from errorCodeHTTPStatuses
+func errorCodes() []string {
+	codes := make([]string, 0, len(errorCodeHTTPStatuses))
+	for code := range errorCodeHTTPStatuses {
+		codes = append(codes, string(code))
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func (g *schemaGenerator) parameters(r route) []interface{} {
+	var parameters []interface{}
+
+	for _, part := range strings.Split(r.path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			name := strings.Trim(part, "{}")
+			// ids are uuids, other variables (hash, address) are strings
+			parameterSchema := g.schemaOf("")
+			if name == "id" {
+				parameterSchema = g.schemaOf(uuid.UUID{})
+			}
+			parameters = append(parameters, schema{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   parameterSchema,
+			})
+		}
+	}
+	if r.query != nil {
+		t := reflect.TypeOf(r.query)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := jsonFieldName(field)
+			if name == "" {
+				continue
+			}
+			parameter := schema{"name": name, "in": "query"}
+			switch field.Type.Kind() {
+			case reflect.Interface, reflect.Map:
+				// JSON-encoded value
+				parameter["content"] = schema{
+					"application/json": schema{"schema": g.schemaOfType(field.Type)},
+				}
+			default:
+				parameter["schema"] = g.schemaOfType(field.Type)
+			}
+			parameters = append(parameters, parameter)
+		}
+	}
+	return parameters
+}
+
+func (g *schemaGenerator) operation(r route, errorStatuses bool) schema {
+	op := schema{
+		"summary":         r.summary,
+		"operationId":     strings.ToLower(r.method) + strings.NewReplacer("/", "_", "{", "", "}", "").Replace(r.path),
+		"x-required-role": r.role,
+		"responses": schema{
+			"200": schema{
+				"description": "Success (v1 API also uses this status for errors)",
+				"content": schema{
+					"application/json": schema{"schema": g.responseSchema(r.response, r.paged)},
+				},
+			},
+		},
+	}
+	if r.walletNotify {
+		op["description"] = "Unsigned requests are accepted from networks " +
+			"listed in api.auth.wallet_notify_from (loopback by default)"
+	}
+	if r.websocket {
+		op["x-websocket-subscribe-message"] = g.schemaOf(SubscribeMessage{})
+		op["responses"] = schema{
+			"101": schema{
+				"description": "Switching to websocket. Client sends " +
+					"subscribe message with sequence number of first " +
+					"event it needs, then server sends events starting " +
+					"from it",
+				"content": schema{
+					"application/json": schema{"schema": g.websocketEventSchema()},
+				},
+			},
+		}
+	}
+	if r.images != nil {
+		content := make(schema)
+		for _, contentType := range r.images {
+			content[contentType] = schema{
+				"schema": schema{"type": "string", "format": "binary"},
+			}
+		}
+		op["responses"].(schema)["200"] = schema{
+			"description": "Image",
+			"content":     content,
+		}
+	}
+	if errorStatuses {
+		op["responses"].(schema)["default"] = schema{
+			"description": "Error, error_code field tells what is wrong",
+			"content": schema{
+				"application/json": schema{"schema": g.responseSchema(nil, false)},
+			},
+		}
+	}
+	if parameters := g.parameters(r); len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	if r.request != nil {
+		op["requestBody"] = schema{
+			"content": schema{
+				"application/json": schema{"schema": g.schemaOf(r.request)},
+			},
+		}
+	}
+	return op
+}
+
+// eventDataSchema describes data attached to events of given type. Its type
+// is learned from unmarshaler registered for event type in events package
+func (g *schemaGenerator) eventDataSchema(eventType events.EventType) schema {
+	t := events.NotificationDataType(eventType)
+	if t == nil {
+		// any JSON value
+		return schema{}
+	}
+	return g.schemaOfType(t)
+}
+
+func eventTypes() []events.EventType {
+	var eventTypes []events.EventType
+
+	for _, name := range enumValues(reflect.TypeOf(events.EventType(0)), int64(events.NewAddressEvent)) {
+		eventType, _ := events.EventTypeFromString(name)
+		eventTypes = append(eventTypes, eventType)
+	}
+	return eventTypes
+}
+
+// webhooks describes HTTP callback requests, one webhook per event type. Body
+// of callback request is event data with event type and sequence number added
+func (g *schemaGenerator) webhooks() schema {
+	webhooks := make(schema)
+
+	for _, eventType := range eventTypes() {
+		if !events.IsSentToHTTPCallback(eventType) {
+			continue
+		}
+		body := schema{
+			"allOf": []interface{}{
+				g.eventDataSchema(eventType),
+				schema{
+					"type": "object",
+					"properties": schema{
+						"type": schema{"type": "string", "enum": []string{eventType.String()}},
+						"seq":  schema{"type": "integer"},
+					},
+				},
+			},
+		}
+		webhooks[eventType.String()] = schema{
+			"post": schema{
+				"summary": "HTTP callback sent to transaction.callback.url " +
+					"on " + eventType.String() + " event",
+				"requestBody": schema{
+					"content": schema{
+						"application/json": schema{"schema": body},
+					},
+				},
+				"responses": schema{
+					"200": schema{"description": "Event accepted"},
+				},
+			},
+		}
+	}
+	return webhooks
+}
+
+// websocketEventSchema describes event message sent to websocket subscriber.
+// Unlike HTTP callback, event data is not flattened, it is in data field
+func (g *schemaGenerator) websocketEventSchema() schema {
+	var dataSchemas []interface{}
+	seen := make(map[string]bool)
+
+	for _, eventType := range eventTypes() {
+		dataSchema := g.eventDataSchema(eventType)
+		ref, _ := dataSchema["$ref"].(string)
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		dataSchemas = append(dataSchemas, dataSchema)
+	}
+	return schema{
+		"type": "object",
+		"properties": schema{
+			"type": g.schemaOf(events.EventType(0)),
+			"seq":  schema{"type": "integer"},
+			"data": schema{"oneOf": dataSchemas},
+		},
+	}
+}
+
+func newOpenAPISpec(v1Routes, v2Routes []route) schema {
+	g := &schemaGenerator{components: make(map[string]schema)}
+	paths := make(map[string]schema)
+
+	addRoutes := func(routes []route, errorStatuses bool) {
+		for _, r := range routes {
+			if paths[r.path] == nil {
+				paths[r.path] = make(schema)
+			}
+			paths[r.path][strings.ToLower(r.method)] = g.operation(r, errorStatuses)
+		}
+	}
+	addRoutes(v1Routes, false)
+	addRoutes(v2Routes, true)
+
+	securityHeaders := []string{
+		APIKeyHeader, TimestampHeader, NonceHeader, SignatureHeader,
+	}
+	securitySchemes := make(schema)
+	security := make(schema)
+	for _, header := range securityHeaders {
+		securitySchemes[header] = schema{
+			"type": "apiKey",
+			"in":   "header",
+			"name": header,
+		}
+		security[header] = []string{}
+	}
+
+	return schema{
+		"openapi": "3.1.0",
+		"info": schema{
+			"title": "bitcoin-processing API",
+			"description": "Gateway for accepting and sending bitcoin " +
+				"payments. Requests are signed with HMAC-SHA256 as described " +
+				"in README, x-required-role tells which API key role is " +
+				"needed for each operation.",
+			"version": "2",
+		},
+		"paths":    paths,
+		"security": []interface{}{security},
+		"webhooks": g.webhooks(),
+		"components": schema{
+			"schemas":         g.components,
+			"securitySchemes": securitySchemes,
+		},
+	}
+}
+
+// openAPISpecJSON returns OpenAPI spec of API serialized to JSON
+func (s *Server) openAPISpecJSON() []byte {
+	spec, err := json.MarshalIndent(newOpenAPISpec(append(s.v1Routes(), s.websocketRoute()), s.v2Routes()), "", "  ")
+	if err != nil {
+		panic("Failed to marshal OpenAPI spec: " + err.Error())
+	}
+	return append(spec, '\n')
+}
+
+func (s *Server) serveOpenAPISpec(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(s.openAPISpecJSON())
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/onederx/bitcoin-processing/events"
+)
+
+const openAPISpecFile = "openapi.json"
+
+var updateOpenAPISpec = flag.Bool(
+	"update-openapi",
+	false,
+	"rewrite api/openapi.json with spec generated from current code",
+)
+
+// TestOpenAPISpecIsUpToDate fails if routes or types of requests and responses
+// were changed, but committed spec was not regenerated. To regenerate it, run
+//
+//	go test ./api -run TestOpenAPISpecIsUpToDate -update-openapi
+func TestOpenAPISpecIsUpToDate(t *testing.T) {
+	generated := (&Server{}).openAPISpecJSON()
+
+	if *updateOpenAPISpec {
+		if err := ioutil.WriteFile(openAPISpecFile, generated, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	committed, err := ioutil.ReadFile(openAPISpecFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(committed, generated) {
+		t.Error("api/openapi.json is outdated. Regenerate it with " +
+			"go test ./api -run TestOpenAPISpecIsUpToDate -update-openapi")
+	}
+}
+
+// recordingMux is http.ServeMux that remembers registered handlers
+type recordingMux struct {
+	*http.ServeMux
+	handlers map[string]http.Handler
+}
+
+func (m *recordingMux) Handle(pattern string, handler http.Handler) {
+	m.handlers[pattern] = handler
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *recordingMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// TestOpenAPISpecCoversAllRoutes checks spec against handlers actually
+// registered by API server: every endpoint (except metrics and spec itself)
+// should be described in spec and every path in spec should be served
+func TestOpenAPISpecCoversAllRoutes(t *testing.T) {
+	m := &recordingMux{ServeMux: http.NewServeMux(), handlers: make(map[string]http.Handler)}
+	s := &Server{
+		httpServer:    &http.Server{Handler: m},
+		authenticator: newRequestAuthenticator(nil, time.Minute),
+	}
+	s.initHTTPAPIServer()
+	s.initWebsocketAPIServer()
+
+	spec := newOpenAPISpec(append(s.v1Routes(), s.websocketRoute()), s.v2Routes())
+	paths := spec["paths"].(map[string]schema)
+	served := make(map[string]bool)
+
+	for pattern, handler := range m.handlers {
+		switch pattern {
+		case metricsEndpoint, openAPISpecURL:
+			continue
+		case v2Prefix + "/":
+			err := handler.(*mux.Router).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+				path, err := route.GetPathTemplate()
+				if err != nil {
+					return err
+				}
+				methods, err := route.GetMethods()
+				if err != nil {
+					return err
+				}
+				served[path] = true
+				for _, method := range methods {
+					if _, ok := paths[path][strings.ToLower(method)]; !ok {
+						t.Errorf("Route %s %s is missing in OpenAPI spec", method, path)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		default:
+			served[pattern] = true
+			if _, ok := paths[pattern]; !ok {
+				t.Errorf("Endpoint %s is missing in OpenAPI spec", pattern)
+			}
+		}
+	}
+
+	for path, operations := range paths {
+		if !served[path] {
+			t.Errorf("Path %s from OpenAPI spec is not served", path)
+		}
+		for method, operation := range operations {
+			if operation.(schema)["summary"] == "" {
+				t.Errorf("Operation %s %s has no summary", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISpecDescribesAllEvents(t *testing.T) {
+	for _, eventType := range eventTypes() {
+		if events.NotificationDataType(eventType) == nil {
+			t.Errorf("Data type of %s event is unknown: no unmarshaler "+
+				"registered for it", eventType)
+		}
+	}
+}
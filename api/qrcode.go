@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/onederx/bitcoin-processing/util/qrcode"
+)
+
+// Formats of QR code images
+const (
+	QRCodeFormatPNG = "png"
+	QRCodeFormatSVG = "svg"
+)
+
+const (
+	defaultQRCodeScale = 8
+	maxQRCodeScale     = 32
+)
+
+var qrCodeContentTypes = map[string]string{
+	QRCodeFormatPNG: "image/png",
+	QRCodeFormatSVG: "image/svg+xml",
+}
+
+// QRCodeParams are query parameters of QR code endpoints. Format is "png"
+// (default) or "svg", Scale is a size of QR code module in pixels (default
+// is 8)
+type QRCodeParams struct {
+	Format string `json:"format,omitempty"`
+	Scale  int    `json:"scale,omitempty"`
+}
+
+func qrCodeParamsFromRequest(request *http.Request) (*QRCodeParams, error) {
+	params := &QRCodeParams{Format: QRCodeFormatPNG, Scale: defaultQRCodeScale}
+	query := request.URL.Query()
+
+	if value := query.Get("format"); value != "" {
+		params.Format = value
+	}
+	if _, ok := qrCodeContentTypes[params.Format]; !ok {
+		return nil, invalidRequestError(errors.New("unknown QR code format " + params.Format))
+	}
+	if value := query.Get("scale"); value != "" {
+		scale, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidRequestError(err)
+		}
+		if scale < 1 || scale > maxQRCodeScale {
+			return nil, invalidRequestError(errors.New(
+				"QR code scale should be in range 1-" + strconv.Itoa(maxQRCodeScale),
+			))
+		}
+		params.Scale = scale
+	}
+	return params, nil
+}
+
+// respondQRCode sends image of QR code with BIP 21 payment URI. Errors are
+// sent as usual v2 JSON response
+func (s *Server) respondQRCode(response http.ResponseWriter, request *http.Request, uri string) {
+	params, err := qrCodeParamsFromRequest(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	code, err := qrcode.Encode([]byte(uri))
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+
+	var image []byte
+	switch params.Format {
+	case QRCodeFormatSVG:
+		image = code.SVG(params.Scale)
+	default:
+		if image, err = code.PNG(params.Scale); err != nil {
+			s.respondV2(response, http.StatusOK, nil, err)
+			return
+		}
+	}
+	response.Header().Set("Content-Type", qrCodeContentTypes[params.Format])
+	response.WriteHeader(http.StatusOK)
+	response.Write(image)
+}
+
+func (s *Server) v2GetAccountQRCode(response http.ResponseWriter, request *http.Request) {
+	account, err := s.wallet.GetAccount(mux.Vars(request)["address"])
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	s.respondQRCode(response, request, account.URI)
+}
+
+func (s *Server) v2GetInvoiceQRCode(response http.ResponseWriter, request *http.Request) {
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	invoice, err := s.wallet.GetInvoice(id)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	s.respondQRCode(response, request, invoice.URI)
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Role is a permission that can be granted to API key. Each API endpoint
+// requires a certain role, request is allowed if API key used to sign it has
+// this role or AdminRole
+type Role string
+
+// Possible roles of API keys.
+// ReaderRole allows to get information: transactions, balance, events etc.
+// DepositorRole allows to create new accounts (addresses to receive payments)
+// WithdrawerRole allows to request withdrawals
+// ApproverRole allows to confirm or cancel pending withdrawals. Withdrawal
+// can't be confirmed with the same API key that requested it
+// AdminRole allows everything, including withdrawals to cold storage and
+// muting events
+const (
+	ReaderRole     Role = "reader"
+	DepositorRole  Role = "depositor"
+	WithdrawerRole Role = "withdrawer"
+	ApproverRole   Role = "approver"
+	AdminRole      Role = "admin"
+)
+
+var knownRoles = map[Role]bool{
+	ReaderRole:     true,
+	DepositorRole:  true,
+	WithdrawerRole: true,
+	ApproverRole:   true,
+	AdminRole:      true,
+}
+
+// PermissionDeniedError is returned when API key used to sign request does
+// not have a role required to make it
+type PermissionDeniedError struct {
+	KeyID        string
+	Path         string
+	RequiredRole Role
+}
+
+func (e *PermissionDeniedError) Error() string {
+	return fmt.Sprintf(
+		"Permission denied: API key %s does not have role %s required by %s",
+		e.KeyID,
+		e.RequiredRole,
+		e.Path,
+	)
+}
+
+type apiKeyContextKey struct{}
+
+// HasRole tells whether API key has given role. Key with AdminRole has all
+// roles
+func (key *APIKey) HasRole(role Role) bool {
+	for _, keyRole := range key.Roles {
+		if keyRole == role || keyRole == AdminRole {
+			return true
+		}
+	}
+	return false
+}
+
+func checkRoles(keys []*APIKey) {
+	for _, key := range keys {
+		if len(key.Roles) == 0 {
+			log.Printf("Warning: API key %s has no roles, all requests "+
+				"signed with it will be denied", key.ID)
+		}
+		for _, role := range key.Roles {
+			if !knownRoles[role] {
+				log.Fatalf("Error: API key %s has unknown role %q", key.ID, role)
+			}
+		}
+	}
+}
+
+// apiKeyFromRequest returns API key request was signed with. It returns nil
+// if authentication is disabled
+func apiKeyFromRequest(request *http.Request) *APIKey {
+	key, _ := request.Context().Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// apiKeyIDFromRequest returns id of API key request was signed with or empty
+// string if authentication is disabled
+func apiKeyIDFromRequest(request *http.Request) string {
+	key := apiKeyFromRequest(request)
+	if key == nil {
+		return ""
+	}
+	return key.ID
+}
+
+// authorized wraps handler so that it is only called for requests signed
+// with a valid API key that has given role. Requests that fail authentication
+// get response with HTTP status 401, requests signed with key lacking the role
+// get response with HTTP status 403
+func (s *Server) authorized(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	return s.authenticated(func(response http.ResponseWriter, request *http.Request) {
+		key := apiKeyFromRequest(request)
+		if key != nil && !key.HasRole(role) {
+			log.Printf(
+				"Denying request to %s signed with API key %s: role %s is "+
+					"required",
+				request.URL.Path,
+				key.ID,
+				role,
+			)
+			response.WriteHeader(http.StatusForbidden)
+			s.respond(response, nil, &PermissionDeniedError{
+				KeyID:        key.ID,
+				Path:         request.URL.Path,
+				RequiredRole: role,
+			})
+			return
+		}
+		handler(response, request)
+	})
+}
+
+func withAPIKey(request *http.Request, key *APIKey) *http.Request {
+	return request.WithContext(
+		context.WithValue(request.Context(), apiKeyContextKey{}, key),
+	)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthorizedChecksRole(t *testing.T) {
+	s := &Server{
+		authenticator: newRequestAuthenticator(
+			[]*APIKey{
+				{ID: "reader", Secret: testKeySecret, Roles: []Role{ReaderRole}},
+				{ID: "withdrawer", Secret: testKeySecret, Roles: []Role{WithdrawerRole}},
+				{ID: "admin", Secret: testKeySecret, Roles: []Role{AdminRole}},
+			},
+			time.Minute,
+		),
+	}
+	handler := s.authorized(WithdrawerRole, func(response http.ResponseWriter, request *http.Request) {
+		s.respond(response, apiKeyIDFromRequest(request), nil)
+	})
+
+	tests := []struct {
+		keyID      string
+		wantStatus int
+		wantCode   HTTPAPIErrorCode
+	}{
+		{keyID: "reader", wantStatus: http.StatusForbidden, wantCode: ErrorCodePermissionDenied},
+		{keyID: "withdrawer", wantStatus: http.StatusOK},
+		{keyID: "admin", wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler(recorder, newSignedTestRequest(t, test.keyID, testKeySecret))
+
+		if got := recorder.Code; got != test.wantStatus {
+			t.Errorf("Key %s: expected status %d, got %d",
+				test.keyID, test.wantStatus, got)
+		}
+		var response GenericHTTPAPIResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if got := response.ErrorCode; got != test.wantCode {
+			t.Errorf("Key %s: expected error code %q, got %q",
+				test.keyID, test.wantCode, got)
+		}
+	}
+}
@@ -3,10 +3,12 @@ package api
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/settings"
 	"github.com/onederx/bitcoin-processing/wallet"
 )
 
@@ -20,10 +22,16 @@ type Server struct {
 	listenAddress            string
 	allowWithdrawalWithoutID bool
 	httpServer               *http.Server
+	authenticator            *requestAuthenticator
+	walletNotifyNetworks     []*net.IPNet
+	tlsConfigReloader        *tlsConfigReloader
 }
 
-// NewServer creates new instance of API server
-func NewServer(listenAddress string, btcWallet *wallet.Wallet, eventBroker events.EventBroker, allowWithdrawalWithoutID bool) *Server {
+// NewServer creates new instance of API server. Listen address, API keys, TLS
+// certificates and other parameters are read from settings
+func NewServer(s settings.Settings, btcWallet *wallet.Wallet, eventBroker events.EventBroker) *Server {
+	listenAddress := s.GetString("api.http.address")
+	maxClockSkew := time.Duration(s.GetInt("api.auth.max_clock_skew")) * time.Second
 	httpServer := &http.Server{
 		Addr:    listenAddress,
 		Handler: http.NewServeMux(),
@@ -32,18 +40,36 @@ func NewServer(listenAddress string, btcWallet *wallet.Wallet, eventBroker event
 		wallet:                   btcWallet,
 		eventBroker:              eventBroker,
 		listenAddress:            listenAddress,
-		allowWithdrawalWithoutID: allowWithdrawalWithoutID,
+		allowWithdrawalWithoutID: s.GetBool("wallet.allow_withdrawal_without_id"),
 		httpServer:               httpServer,
+		authenticator:            newRequestAuthenticator(loadAPIKeys(s), maxClockSkew),
+		walletNotifyNetworks:     loadWalletNotifyNetworks(s),
+	}
+	if tlsSettings := loadTLSSettings(s); tlsSettings != nil {
+		reloader, err := newTLSConfigReloader(tlsSettings)
+		if err != nil {
+			log.Fatalf("Error: failed to set up TLS for API server: %v", err)
+		}
+		server.tlsConfigReloader = reloader
+		httpServer.TLSConfig = reloader.serverConfig()
 	}
 	server.initHTTPAPIServer()
 	server.initWebsocketAPIServer()
 	return server
 }
 
-// Run starts HTTP and websocket server
+// Run starts HTTP and websocket server. If TLS certificate is set in config,
+// server accepts only HTTPS (and secure websocket) connections
 func (s *Server) Run() error {
-	log.Printf("Starting API server on %s", s.listenAddress)
-	err := s.httpServer.ListenAndServe()
+	var err error
+
+	if s.tlsConfigReloader != nil {
+		log.Printf("Starting API server with TLS on %s", s.listenAddress)
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("Starting API server on %s", s.listenAddress)
+		err = s.httpServer.ListenAndServe()
+	}
 	if err == http.ErrServerClosed {
 		return nil
 	}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+
+	"github.com/onederx/bitcoin-processing/settings"
+)
+
+// tlsSettings describe certificate and key API server uses to serve TLS and
+// (optional) CA bundle used to verify client certificates
+type tlsSettings struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+}
+
+func loadTLSSettings(s settings.Settings) *tlsSettings {
+	tlsSettings := &tlsSettings{
+		certFile:     s.GetString("api.http.tls.cert_file"),
+		keyFile:      s.GetString("api.http.tls.key_file"),
+		clientCAFile: s.GetString("api.http.tls.client_ca_file"),
+	}
+	if tlsSettings.certFile == "" && tlsSettings.keyFile == "" {
+		if tlsSettings.clientCAFile != "" {
+			log.Fatal("Error: api.http.tls.client_ca_file is set, but " +
+				"api.http.tls.cert_file and api.http.tls.key_file are not. " +
+				"Client certificates can only be verified if TLS is enabled")
+		}
+		return nil
+	}
+	if tlsSettings.certFile == "" || tlsSettings.keyFile == "" {
+		log.Fatal("Error: both api.http.tls.cert_file and " +
+			"api.http.tls.key_file should be set to enable TLS")
+	}
+	return tlsSettings
+}
+
+// tlsConfigReloader holds TLS config of API server built from certificate,
+// key and client CA files. Files are re-read by reload, so certificates can be
+// renewed without restarting the server. Connections established before reload
+// keep using old certificates
+type tlsConfigReloader struct {
+	settings *tlsSettings
+
+	mutex  sync.RWMutex
+	config *tls.Config
+}
+
+func newTLSConfigReloader(settings *tlsSettings) (*tlsConfigReloader, error) {
+	r := &tlsConfigReloader{settings: settings}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No PEM certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+func (r *tlsConfigReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.settings.certFile, r.settings.keyFile)
+	if err != nil {
+		return fmt.Errorf("Failed to load TLS certificate and key: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if r.settings.clientCAFile != "" {
+		config.ClientCAs, err = loadCertPool(r.settings.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load client CA bundle: %v", err)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.config = config
+	return nil
+}
+
+func (r *tlsConfigReloader) currentConfig() *tls.Config {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.config
+}
+
+// serverConfig returns TLS config for http.Server that always uses most
+// recently loaded certificates
+func (r *tlsConfigReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.currentConfig().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.currentConfig(), nil
+		},
+	}
+}
+
+// ReloadTLS re-reads TLS certificate, key and client CA bundle from files
+// set in config. It is called by processing app on SIGHUP. If new files can't
+// be loaded, error is returned and server continues to use old ones
+func (s *Server) ReloadTLS() error {
+	if s.tlsConfigReloader == nil {
+		return errors.New("TLS is not enabled for API server")
+	}
+	if err := s.tlsConfigReloader.reload(); err != nil {
+		return err
+	}
+	log.Print("Reloaded TLS certificates of API server")
+	return nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCertificate generates self-signed certificate valid for 127.0.0.1
+// and writes it and its key to given dir. It returns parsed certificate
+func writeTestCertificate(t *testing.T, dir, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestTLSConfigReloaderWithClientCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bitcoin-processing-tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	serverCert := writeTestCertificate(t, dir, "server")
+	writeTestCertificate(t, dir, "client")
+
+	reloader, err := newTLSConfigReloader(&tlsSettings{
+		certFile:     filepath.Join(dir, "server.crt"),
+		keyFile:      filepath.Join(dir, "server.key"),
+		clientCAFile: filepath.Join(dir, "client.crt"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(response http.ResponseWriter, request *http.Request) {},
+	))
+	server.TLS = reloader.serverConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	newClient := func(serverCert *x509.Certificate, certificates []tls.Certificate) *http.Client {
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(serverCert)
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      rootCAs,
+				Certificates: certificates,
+			},
+		}}
+	}
+	clientCert, err := tls.LoadX509KeyPair(
+		filepath.Join(dir, "client.crt"),
+		filepath.Join(dir, "client.key"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newClient(serverCert, nil).Get(server.URL); err == nil {
+		t.Error("Request without client certificate succeeded")
+	}
+	if _, err := newClient(serverCert, []tls.Certificate{clientCert}).Get(server.URL); err != nil {
+		t.Errorf("Request with client certificate failed: %v", err)
+	}
+
+	newServerCert := writeTestCertificate(t, dir, "server")
+	if err := reloader.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newClient(serverCert, []tls.Certificate{clientCert}).Get(server.URL); err == nil {
+		t.Error("Server still uses old certificate after reload")
+	}
+	if _, err := newClient(newServerCert, []tls.Certificate{clientCert}).Get(server.URL); err != nil {
+		t.Errorf("Request to server with reloaded certificate failed: %v", err)
+	}
+}
@@ -0,0 +1,716 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const v2Prefix = "/v2"
+
+// Paths of v2 API resources. Unlike v1 API, v2 API uses HTTP methods and
+// status codes according to their meaning: resources are read with GET,
+// changed with POST, errors are reported with 4xx and 5xx status codes.
+// Response body has same format as in v1 API
+const (
+	V2AccountsURL                 = v2Prefix + "/accounts"
+	V2AccountURL                  = v2Prefix + "/accounts/{address}"
+	V2AccountMetainfoURL          = v2Prefix + "/accounts/{address}/metainfo"
+	V2AccountQRCodeURL            = v2Prefix + "/accounts/{address}/qr"
+	V2InvoicesURL                 = v2Prefix + "/invoices"
+	V2InvoiceURL                  = v2Prefix + "/invoices/{id}"
+	V2InvoiceQRCodeURL            = v2Prefix + "/invoices/{id}/qr"
+	V2NotifyWalletURL             = v2Prefix + "/notify_wallet"
+	V2HotStorageAddressURL        = v2Prefix + "/hot_storage_address"
+	V2BalanceURL                  = v2Prefix + "/balance"
+	V2RequiredFromColdStorageURL  = v2Prefix + "/required_from_cold_storage"
+	V2TransactionsURL             = v2Prefix + "/transactions"
+	V2TransactionURL              = v2Prefix + "/transactions/{id}"
+	V2TransactionsByHashURL       = v2Prefix + "/transactions/by_hash/{hash}"
+	V2AccelerateDepositURL        = v2Prefix + "/transactions/{id}/accelerate"
+	V2WithdrawalsURL              = v2Prefix + "/withdrawals"
+	V2WithdrawalBatchesURL        = v2Prefix + "/withdrawal_batches"
+	V2ColdStorageWithdrawalsURL   = v2Prefix + "/cold_storage_withdrawals"
+	V2ConfirmWithdrawalURL        = v2Prefix + "/withdrawals/{id}/confirm"
+	V2CancelWithdrawalURL         = v2Prefix + "/withdrawals/{id}/cancel"
+	V2BumpFeeURL                  = v2Prefix + "/withdrawals/{id}/bump_fee"
+	V2EventsURL                   = v2Prefix + "/events"
+	V2MuteEventsURL               = v2Prefix + "/events/mute/{id}"
+	V2MuteCurrentProblematicTxURL = v2Prefix + "/events/mute/current_problematic"
+)
+
+// route describes API endpoint: HTTP method and path (which can contain
+// variables like {id}), role API key needs to call it and handler. Other
+// fields are only used to generate OpenAPI spec: request and response hold
+// values of types of request and response body ('result' field of response),
+// query holds value of struct type which fields are query parameters, paged
+// tells that response has next_cursor field
+type route struct {
+	method  string
+	path    string
+	role    Role
+	handler http.HandlerFunc
+
+	summary  string
+	request  interface{}
+	response interface{}
+	query    interface{}
+	paged    bool
+
+	// images lists content types of image returned instead of JSON
+	images []string
+
+	// walletNotify marks endpoint called by walletnotify hook of Bitcoin
+	// node, see walletNotifyAuthorized
+	walletNotify bool
+
+	// websocket marks endpoint that switches connection to websocket
+	websocket bool
+}
+
+func (s *Server) v2Routes() []route {
+	return []route{
+		{
+			method:   http.MethodPost,
+			path:     V2AccountsURL,
+			role:     DepositorRole,
+			handler:  s.v2NewAccount,
+			summary:  "Create new account (generate new address to receive payments)",
+			request:  map[string]interface{}{},
+			query:    NewAccountParams{},
+			response: wallet.Account{},
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2AccountsURL,
+			role:     ReaderRole,
+			handler:  s.v2GetAccounts,
+			summary:  "Get page of accounts, optionally filtered by metainfo",
+			query:    GetAccountsFilter{},
+			response: []*wallet.Account{},
+			paged:    true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     V2AccountMetainfoURL,
+			role:     AdminRole,
+			handler:  s.v2UpdateAccountMetainfo,
+			summary:  "Replace metainfo of account",
+			request:  map[string]interface{}{},
+			response: wallet.Account{},
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2AccountURL,
+			role:     ReaderRole,
+			handler:  s.v2GetAccount,
+			summary:  "Get account by address",
+			response: wallet.Account{},
+		},
+		{
+			method:  http.MethodGet,
+			path:    V2AccountQRCodeURL,
+			role:    ReaderRole,
+			handler: s.v2GetAccountQRCode,
+			summary: "Get QR code with payment URI of account",
+			query:   QRCodeParams{},
+			images:  []string{"image/png", "image/svg+xml"},
+		},
+		{
+			method:   http.MethodPost,
+			path:     V2InvoicesURL,
+			role:     DepositorRole,
+			handler:  s.v2CreateInvoice,
+			summary:  "Create invoice: request to pay expected amount to new address before given time",
+			request:  wallet.CreateInvoiceRequest{},
+			response: wallet.Invoice{},
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2InvoiceURL,
+			role:     ReaderRole,
+			handler:  s.v2GetInvoice,
+			summary:  "Get invoice by id",
+			response: wallet.Invoice{},
+		},
+		{
+			method:  http.MethodGet,
+			path:    V2InvoiceQRCodeURL,
+			role:    ReaderRole,
+			handler: s.v2GetInvoiceQRCode,
+			summary: "Get QR code with payment URI of invoice",
+			query:   QRCodeParams{},
+			images:  []string{"image/png", "image/svg+xml"},
+		},
+		{
+			method:  http.MethodPost,
+			path:    V2NotifyWalletURL,
+			role:    DepositorRole,
+			handler: s.v2NotifyWallet,
+			summary: "Notify processing that wallet has updates (called by walletnotify)",
+
+			walletNotify: true,
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2HotStorageAddressURL,
+			role:     ReaderRole,
+			handler:  s.v2GetHotStorageAddress,
+			summary:  "Get hot wallet address",
+			response: "",
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2BalanceURL,
+			role:     ReaderRole,
+			handler:  s.v2GetBalance,
+			summary:  "Get wallet balance",
+			response: BalanceInfo{},
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2RequiredFromColdStorageURL,
+			role:     ReaderRole,
+			handler:  s.v2GetRequiredFromColdStorage,
+			summary:  "Get amount that should be transferred from cold storage to fund pending withdrawals",
+			response: bitcoin.BTCAmount(0),
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2TransactionsURL,
+			role:     ReaderRole,
+			handler:  s.v2GetTransactions,
+			summary:  "Get page of transactions",
+			query:    GetTransactionsFilter{},
+			response: []*types.Transaction{},
+			paged:    true,
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2TransactionURL,
+			role:     ReaderRole,
+			handler:  s.v2GetTransaction,
+			summary:  "Get transaction by id",
+			response: types.Transaction{},
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2TransactionsByHashURL,
+			role:     ReaderRole,
+			handler:  s.v2GetTransactionsByHash,
+			summary:  "Get all transactions corresponding to bitcoin tx with given hash",
+			response: []*types.Transaction{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     V2WithdrawalsURL,
+			role:     WithdrawerRole,
+			handler:  s.v2Withdraw,
+			summary:  "Request withdrawal",
+			request:  wallet.WithdrawRequest{},
+			response: wallet.WithdrawRequest{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     V2WithdrawalBatchesURL,
+			role:     WithdrawerRole,
+			handler:  s.v2WithdrawBatch,
+			summary:  "Request batch withdrawal paid by one bitcoin transaction",
+			request:  wallet.BatchWithdrawRequest{},
+			response: wallet.BatchWithdrawRequest{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     V2ColdStorageWithdrawalsURL,
+			role:     AdminRole,
+			handler:  s.v2WithdrawToColdStorage,
+			summary:  "Request withdrawal to cold storage",
+			request:  wallet.WithdrawRequest{},
+			response: wallet.WithdrawRequest{},
+		},
+		{
+			method:  http.MethodPost,
+			path:    V2ConfirmWithdrawalURL,
+			role:    ApproverRole,
+			handler: s.v2ConfirmWithdrawal,
+			summary: "Approve withdrawal pending manual confirmation",
+		},
+		{
+			method:  http.MethodPost,
+			path:    V2CancelWithdrawalURL,
+			role:    ApproverRole,
+			handler: s.v2CancelWithdrawal,
+			summary: "Cancel pending withdrawal",
+		},
+		{
+			method:   http.MethodPost,
+			path:     V2BumpFeeURL,
+			role:     WithdrawerRole,
+			handler:  s.v2BumpFee,
+			summary:  "Bump fee of unconfirmed or dropped withdrawal (replace-by-fee)",
+			request:  wallet.BumpFeeRequest{},
+			response: types.Transaction{},
+		},
+		{
+			method:   http.MethodPost,
+			path:     V2AccelerateDepositURL,
+			role:     AdminRole,
+			handler:  s.v2AccelerateDeposit,
+			summary:  "Accelerate unconfirmed deposit with Child-Pays-For-Parent tx",
+			request:  wallet.AccelerateDepositRequest{},
+			response: types.Transaction{},
+		},
+		{
+			method:   http.MethodGet,
+			path:     V2EventsURL,
+			role:     ReaderRole,
+			handler:  s.v2GetEvents,
+			summary:  "Get events starting from given sequence number",
+			query:    SubscribeMessage{},
+			response: []*events.NotificationWithSeq{},
+		},
+		{
+			method:  http.MethodPost,
+			path:    V2MuteCurrentProblematicTxURL,
+			role:    AdminRole,
+			handler: s.v2MuteCurrentProblematicTx,
+			summary: "Stop sending events about tx which callbacks currently fail",
+		},
+		{
+			method:  http.MethodPost,
+			path:    V2MuteEventsURL,
+			role:    AdminRole,
+			handler: s.v2MuteEvents,
+			summary: "Stop sending events about tx with given id",
+		},
+	}
+}
+
+func (s *Server) newV2Router() *mux.Router {
+	router := mux.NewRouter()
+
+	for _, r := range s.v2Routes() {
+		router.HandleFunc(r.path, s.routeHandler(r)).Methods(r.method)
+	}
+	router.NotFoundHandler = http.HandlerFunc(
+		func(response http.ResponseWriter, request *http.Request) {
+			s.respondV2(response, http.StatusOK, nil, &APIError{
+				Code:    ErrorCodeNotFound,
+				Message: "No such API resource: " + request.URL.Path,
+			})
+		},
+	)
+	router.MethodNotAllowedHandler = http.HandlerFunc(
+		func(response http.ResponseWriter, request *http.Request) {
+			s.respondV2(response, http.StatusOK, nil, &APIError{
+				Code: ErrorCodeMethodNotAllowed,
+				Message: "Method " + request.Method + " is not allowed for " +
+					request.URL.Path,
+			})
+		},
+	)
+	return router
+}
+
+// errorCodeHTTPStatuses maps error codes to HTTP statuses of v2 API
+// responses. It is also the list of error codes in OpenAPI spec, so every
+// error code should be here
+var errorCodeHTTPStatuses = map[HTTPAPIErrorCode]int{
+	ErrorCodeInvalidRequest:     http.StatusBadRequest,
+	ErrorCodeUnauthenticated:    http.StatusUnauthorized,
+	ErrorCodePermissionDenied:   http.StatusForbidden,
+	ErrorCodeSelfApproval:       http.StatusForbidden,
+	ErrorCodeNotFound:           http.StatusNotFound,
+	ErrorCodeMethodNotAllowed:   http.StatusMethodNotAllowed,
+	ErrorCodeDuplicateID:        http.StatusConflict,
+	ErrorCodeNotPending:         http.StatusConflict,
+	ErrorCodeDuplicateApproval:  http.StatusConflict,
+	ErrorCodeNotReplaceable:     http.StatusConflict,
+	ErrorCodeNotAcceleratable:   http.StatusConflict,
+	ErrorCodeInsufficientFunds:  http.StatusUnprocessableEntity,
+	ErrorCodeAmountBelowMinimum: http.StatusUnprocessableEntity,
+	ErrorCodeFeeBelowMinimum:    http.StatusUnprocessableEntity,
+	ErrorCodeHotWalletAddress:   http.StatusUnprocessableEntity,
+}
+
+// httpStatus chooses HTTP status of v2 API response with given error
+func httpStatus(err error) int {
+	if status, ok := errorCodeHTTPStatuses[errorCode(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// respondV2 sends response of v2 API. If err is nil, response has given
+// status, otherwise status is chosen by error code
+func (s *Server) respondV2(response http.ResponseWriter, status int, data interface{}, err error) {
+	s.respondV2Page(response, status, data, "", err)
+}
+
+func (s *Server) respondV2Page(response http.ResponseWriter, status int, data interface{}, nextCursor string, err error) {
+	if err != nil {
+		status = httpStatus(err)
+	}
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	s.respondPage(response, data, nextCursor, err)
+}
+
+func idFromPath(request *http.Request) (uuid.UUID, error) {
+	id, err := uuid.FromString(mux.Vars(request)["id"])
+	if err != nil {
+		return uuid.Nil, invalidRequestError(err)
+	}
+	return id, nil
+}
+
+func (s *Server) v2NewAccount(response http.ResponseWriter, request *http.Request) {
+	var metainfo map[string]interface{}
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &metainfo); err != nil {
+			s.respondV2(response, http.StatusOK, nil, invalidRequestError(err))
+			return
+		}
+	}
+	addressType, err := addressTypeFromRequest(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	account, err := s.wallet.CreateAccount(metainfo, addressType)
+	s.respondV2(response, http.StatusCreated, account, err)
+}
+
+func (s *Server) v2NotifyWallet(response http.ResponseWriter, request *http.Request) {
+	s.wallet.TriggerWalletUpdate()
+	s.respondV2(response, http.StatusAccepted, nil, nil)
+}
+
+func (s *Server) v2GetHotStorageAddress(response http.ResponseWriter, request *http.Request) {
+	s.respondV2(response, http.StatusOK, s.wallet.GetHotWalletAddress(), nil)
+}
+
+func (s *Server) v2GetBalance(response http.ResponseWriter, request *http.Request) {
+	var respData BalanceInfo
+	var err error
+	respData.Balance, respData.BalanceWithUnconf, err = s.wallet.GetBalance()
+
+	s.respondV2(response, http.StatusOK, respData, err)
+}
+
+func (s *Server) v2GetRequiredFromColdStorage(response http.ResponseWriter, request *http.Request) {
+	amount, err := s.wallet.GetMoneyRequiredFromColdStorage()
+	s.respondV2(response, http.StatusOK, amount, err)
+}
+
+// transactionsFilterFromQuery parses GetTransactionsFilter from query
+// parameters named same as its fields in JSON. Times are given in RFC 3339
+// format, metainfo is given as JSON
+func transactionsFilterFromQuery(query url.Values) (*GetTransactionsFilter, error) {
+	filter := &GetTransactionsFilter{
+		Direction: query.Get("direction"),
+		Status:    query.Get("status"),
+		Address:   query.Get("address"),
+		Hash:      query.Get("hash"),
+		Order:     query.Get("order"),
+		Cursor:    query.Get("cursor"),
+	}
+	var err error
+
+	if value := query.Get("batch_id"); value != "" {
+		batchID, err := uuid.FromString(value)
+		if err != nil {
+			return nil, err
+		}
+		filter.BatchID = &batchID
+	}
+	if value := query.Get("parent_id"); value != "" {
+		parentID, err := uuid.FromString(value)
+		if err != nil {
+			return nil, err
+		}
+		filter.ParentID = &parentID
+	}
+
+	parseTime := func(name string) *time.Time {
+		value := query.Get(name)
+		if value == "" || err != nil {
+			return nil
+		}
+		var t time.Time
+		t, err = time.Parse(time.RFC3339Nano, value)
+		return &t
+	}
+	parseAmount := func(name string) bitcoin.BTCAmount {
+		value := query.Get(name)
+		if value == "" || err != nil {
+			return 0
+		}
+		var amount bitcoin.BTCAmount
+		amount, err = bitcoin.BTCAmountFromStringedFloat(value)
+		return amount
+	}
+
+	filter.CreatedAfter = parseTime("created_after")
+	filter.CreatedBefore = parseTime("created_before")
+	filter.UpdatedAfter = parseTime("updated_after")
+	filter.UpdatedBefore = parseTime("updated_before")
+	filter.MinAmount = parseAmount("min_amount")
+	filter.MaxAmount = parseAmount("max_amount")
+	if err != nil {
+		return nil, err
+	}
+	if value := query.Get("cold_storage"); value != "" {
+		coldStorage, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		filter.ColdStorage = &coldStorage
+	}
+	if value := query.Get("metainfo"); value != "" {
+		if err = json.Unmarshal([]byte(value), &filter.Metainfo); err != nil {
+			return nil, err
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+// v2GetTransactions returns page of transactions filtered by query parameters
+// (same as fields of GetTransactionsFilter). Pagination is controlled by
+// parameters 'order', 'limit' and 'cursor'
+func (s *Server) v2GetTransactions(response http.ResponseWriter, request *http.Request) {
+	filter, err := transactionsFilterFromQuery(request.URL.Query())
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, invalidRequestError(err))
+		return
+	}
+	txns, nextCursor, err := s.wallet.GetTransactions(
+		filter.walletFilter(),
+		filter.Cursor,
+	)
+	s.respondV2Page(response, http.StatusOK, txns, nextCursor, err)
+}
+
+func (s *Server) v2GetTransaction(response http.ResponseWriter, request *http.Request) {
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	tx, err := s.wallet.GetTransactionByID(id)
+	s.respondV2(response, http.StatusOK, tx, err)
+}
+
+func (s *Server) v2GetTransactionsByHash(response http.ResponseWriter, request *http.Request) {
+	txns, err := s.wallet.GetTransactionsByHash(mux.Vars(request)["hash"])
+	s.respondV2(response, http.StatusOK, txns, err)
+}
+
+func (s *Server) v2GetAccount(response http.ResponseWriter, request *http.Request) {
+	account, err := s.wallet.GetAccount(mux.Vars(request)["address"])
+	s.respondV2(response, http.StatusOK, account, err)
+}
+
+// v2GetAccounts returns page of accounts filtered by query parameters (same
+// as fields of GetAccountsFilter, metainfo is given as JSON)
+func (s *Server) v2GetAccounts(response http.ResponseWriter, request *http.Request) {
+	var filter wallet.AccountsFilter
+	var err error
+
+	query := request.URL.Query()
+	if value := query.Get("metainfo"); value != "" {
+		if err = json.Unmarshal([]byte(value), &filter.Metainfo); err != nil {
+			s.respondV2(response, http.StatusOK, nil, invalidRequestError(err))
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			s.respondV2(response, http.StatusOK, nil, invalidRequestError(err))
+			return
+		}
+	}
+	accounts, nextCursor, err := s.wallet.GetAccounts(filter, query.Get("cursor"))
+	s.respondV2Page(response, http.StatusOK, accounts, nextCursor, err)
+}
+
+func (s *Server) v2UpdateAccountMetainfo(response http.ResponseWriter, request *http.Request) {
+	var metainfo map[string]interface{}
+
+	if err := decodeRequestBody(request, &metainfo); err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	account, err := s.wallet.UpdateAccountMetainfo(
+		mux.Vars(request)["address"],
+		metainfo,
+		apiKeyIDFromRequest(request),
+	)
+	s.respondV2(response, http.StatusOK, account, err)
+}
+
+func (s *Server) v2CreateInvoice(response http.ResponseWriter, request *http.Request) {
+	var req wallet.CreateInvoiceRequest
+
+	if err := decodeRequestBody(request, &req); err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	req.CreatedBy = apiKeyIDFromRequest(request)
+	invoice, err := s.wallet.CreateInvoice(&req)
+	s.respondV2(response, http.StatusCreated, invoice, err)
+}
+
+func (s *Server) v2GetInvoice(response http.ResponseWriter, request *http.Request) {
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	invoice, err := s.wallet.GetInvoice(id)
+	s.respondV2(response, http.StatusOK, invoice, err)
+}
+
+func (s *Server) v2WithdrawWithDestination(toColdStorage bool, response http.ResponseWriter, request *http.Request) {
+	req, err := s.decodeWithdrawRequest(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	err = s.wallet.Withdraw(req, toColdStorage)
+	s.respondV2(response, http.StatusCreated, req, err)
+}
+
+func (s *Server) v2Withdraw(response http.ResponseWriter, request *http.Request) {
+	s.v2WithdrawWithDestination(false, response, request)
+}
+
+func (s *Server) v2WithdrawBatch(response http.ResponseWriter, request *http.Request) {
+	req, err := s.decodeBatchWithdrawRequest(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	err = s.wallet.WithdrawBatch(req)
+	s.respondV2(response, http.StatusCreated, req, err)
+}
+
+func (s *Server) v2WithdrawToColdStorage(response http.ResponseWriter, request *http.Request) {
+	s.v2WithdrawWithDestination(true, response, request)
+}
+
+func (s *Server) v2ConfirmWithdrawal(response http.ResponseWriter, request *http.Request) {
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	err = s.wallet.ConfirmPendingTransaction(id, apiKeyIDFromRequest(request))
+	s.respondV2(response, http.StatusOK, nil, err)
+}
+
+func (s *Server) v2CancelWithdrawal(response http.ResponseWriter, request *http.Request) {
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	err = s.wallet.CancelPendingTx(id)
+	s.respondV2(response, http.StatusOK, nil, err)
+}
+
+// v2BumpFee bumps fee of withdrawal with id from path, request body has new
+// fee and fee type (id in body is ignored)
+func (s *Server) v2BumpFee(response http.ResponseWriter, request *http.Request) {
+	var req wallet.BumpFeeRequest
+
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	if err = decodeRequestBody(request, &req); err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	req.ID = id
+	if err = s.wallet.BumpFee(&req); err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	tx, err := s.wallet.GetTransactionByID(id)
+	s.respondV2(response, http.StatusOK, tx, err)
+}
+
+// v2AccelerateDeposit accelerates incoming tx with id from path, request body
+// has fee rate (id in body is ignored). Response is created CPFP tx
+func (s *Server) v2AccelerateDeposit(response http.ResponseWriter, request *http.Request) {
+	var req wallet.AccelerateDepositRequest
+
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	if err = decodeRequestBody(request, &req); err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	req.ID = id
+	tx, err := s.wallet.AccelerateDeposit(&req)
+	s.respondV2(response, http.StatusCreated, tx, err)
+}
+
+// v2GetEvents returns events starting from sequence number given in query
+// parameter 'seq' (0 by default)
+func (s *Server) v2GetEvents(response http.ResponseWriter, request *http.Request) {
+	var seq int
+	var err error
+
+	if seqStr := request.URL.Query().Get("seq"); seqStr != "" {
+		if seq, err = strconv.Atoi(seqStr); err != nil {
+			s.respondV2(response, http.StatusOK, nil, invalidRequestError(err))
+			return
+		}
+	}
+	var notifications []*events.NotificationWithSeq
+	notifications, err = s.eventBroker.GetEventsFromSeq(seq)
+	s.respondV2(response, http.StatusOK, notifications, err)
+}
+
+func (s *Server) v2MuteEvents(response http.ResponseWriter, request *http.Request) {
+	id, err := idFromPath(request)
+	if err != nil {
+		s.respondV2(response, http.StatusOK, nil, err)
+		return
+	}
+	err = s.eventBroker.MuteEventsWithTxID(id)
+	s.respondV2(response, http.StatusOK, nil, err)
+}
+
+func (s *Server) v2MuteCurrentProblematicTx(response http.ResponseWriter, request *http.Request) {
+	err := s.eventBroker.MuteEventsWithTxID(uuid.Nil)
+	s.respondV2(response, http.StatusOK, nil, err)
+}
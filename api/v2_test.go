@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/wallet"
+)
+
+func TestV2RouterErrors(t *testing.T) {
+	s := &Server{authenticator: newRequestAuthenticator(nil, time.Minute)}
+	router := s.newV2Router()
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantCode   HTTPAPIErrorCode
+	}{
+		{http.MethodGet, v2Prefix + "/no_such_resource", http.StatusNotFound, ErrorCodeNotFound},
+		{http.MethodPost, V2BalanceURL, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodGet, V2WithdrawalsURL, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+		{http.MethodGet, V2TransactionsURL + "/not-a-uuid", http.StatusBadRequest, ErrorCodeInvalidRequest},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+
+		if got := recorder.Code; got != test.wantStatus {
+			t.Errorf("%s %s: expected status %d, got %d",
+				test.method, test.path, test.wantStatus, got)
+		}
+		var response GenericHTTPAPIResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s: %v", test.method, test.path, err)
+		}
+		if got := response.ErrorCode; got != test.wantCode {
+			t.Errorf("%s %s: expected error code %q, got %q",
+				test.method, test.path, test.wantCode, got)
+		}
+	}
+}
+
+func TestHTTPStatusOfWalletErrors(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{&wallet.Error{Code: wallet.ErrorCodeInsufficientFunds}, http.StatusUnprocessableEntity},
+		{&wallet.Error{Code: wallet.ErrorCodeDuplicateID}, http.StatusConflict},
+		{&wallet.Error{Code: wallet.ErrorCodeAmountBelowMinimum}, http.StatusUnprocessableEntity},
+		{&wallet.Error{Code: wallet.ErrorCodeFeeBelowMinimum}, http.StatusUnprocessableEntity},
+		{&wallet.Error{Code: wallet.ErrorCodeNotPending}, http.StatusConflict},
+		{&wallet.Error{Code: wallet.ErrorCodeHotWalletAddress}, http.StatusUnprocessableEntity},
+		{&wallet.Error{Code: wallet.ErrorCodeNotFound}, http.StatusNotFound},
+		{wallet.ErrSelfApproval, http.StatusForbidden},
+		{wallet.ErrDuplicateApproval, http.StatusConflict},
+		{&wallet.Error{Code: wallet.ErrorCodeNotReplaceable}, http.StatusConflict},
+		{&wallet.Error{Code: wallet.ErrorCodeNotAcceleratable}, http.StatusConflict},
+		{errors.New("Failed to connect to Bitcoin node"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		if got := httpStatus(test.err); got != test.wantStatus {
+			t.Errorf("Error %q (code %q): expected status %d, got %d",
+				test.err, errorCode(test.err), test.wantStatus, got)
+		}
+	}
+}
+
+// TestAllWalletErrorCodesHaveHTTPStatus reads error codes declared in wallet
+// package source, so that a new code can't be forgotten in
+// errorCodeHTTPStatuses (and in OpenAPI spec, which lists codes from it)
+func TestAllWalletErrorCodesHaveHTTPStatus(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../wallet/errors.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			ident, ok := valueSpec.Type.(*ast.Ident)
+			if !ok || ident.Name != "ErrorCode" {
+				continue
+			}
+			for _, value := range valueSpec.Values {
+				code, err := strconv.Unquote(value.(*ast.BasicLit).Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				found++
+				if _, ok := errorCodeHTTPStatuses[HTTPAPIErrorCode(code)]; !ok {
+					t.Errorf("Wallet error code %q has no HTTP status in "+
+						"errorCodeHTTPStatuses", code)
+				}
+			}
+		}
+	}
+	if found == 0 {
+		t.Error("No error codes found in wallet/errors.go")
+	}
+}
+
+func TestTransactionsFilterFromQuery(t *testing.T) {
+	query, _ := url.ParseQuery("address=addr&min_amount=0.1&cold_storage=false" +
+		"&created_after=2019-01-01T00:00:00Z&metainfo=%7B%22user_id%22%3A42%7D&limit=10" +
+		"&batch_id=8e70c722-45fe-445c-93c6-262f49bbc710&parent_id=2e0b7a3c-52a4-4d0c-a3f5-1a7e5c9d4b61")
+
+	filter, err := transactionsFilterFromQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletFilter := filter.walletFilter()
+	if got, want := walletFilter.Address, "addr"; got != want {
+		t.Errorf("Expected address %q, got %q", want, got)
+	}
+	if got, want := walletFilter.MinAmount.String(), "0.1"; got != want {
+		t.Errorf("Expected min amount %s, got %s", want, got)
+	}
+	if walletFilter.ColdStorage == nil || *walletFilter.ColdStorage {
+		t.Errorf("Expected cold storage filter to be false, got %v", walletFilter.ColdStorage)
+	}
+	if got, want := walletFilter.CreatedAfter, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected created_after %s, got %s", want, got)
+	}
+	if !walletFilter.CreatedBefore.IsZero() {
+		t.Errorf("Expected created_before to be unset, got %s", walletFilter.CreatedBefore)
+	}
+	if got, ok := walletFilter.Metainfo.(map[string]interface{}); !ok || got["user_id"] != 42.0 {
+		t.Errorf("Expected metainfo {\"user_id\": 42}, got %v", walletFilter.Metainfo)
+	}
+	if got, want := walletFilter.Limit, 10; got != want {
+		t.Errorf("Expected limit %d, got %d", want, got)
+	}
+	if got, want := walletFilter.BatchID.String(), "8e70c722-45fe-445c-93c6-262f49bbc710"; got != want {
+		t.Errorf("Expected batch id %s, got %s", want, got)
+	}
+	if got, want := walletFilter.ParentID.String(), "2e0b7a3c-52a4-4d0c-a3f5-1a7e5c9d4b61"; got != want {
+		t.Errorf("Expected parent id %s, got %s", want, got)
+	}
+
+	for _, invalid := range []string{
+		"min_amount=lots", "created_after=yesterday", "cold_storage=maybe",
+		"metainfo=%7B", "limit=ten", "batch_id=42",
+	} {
+		query, _ := url.ParseQuery(invalid)
+		if _, err := transactionsFilterFromQuery(query); err == nil {
+			t.Errorf("Expected error for query %s", invalid)
+		}
+	}
+}
+
+func TestQRCodeParamsFromRequest(t *testing.T) {
+	params, err := qrCodeParamsFromRequest(httptest.NewRequest(http.MethodGet, "/qr", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Format != QRCodeFormatPNG || params.Scale != defaultQRCodeScale {
+		t.Errorf("Expected default params, got %+v", params)
+	}
+
+	params, err = qrCodeParamsFromRequest(httptest.NewRequest(http.MethodGet, "/qr?format=svg&scale=4", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Format != QRCodeFormatSVG || params.Scale != 4 {
+		t.Errorf("Expected svg with scale 4, got %+v", params)
+	}
+
+	for _, invalid := range []string{"format=gif", "scale=big", "scale=0", "scale=100"} {
+		if _, err := qrCodeParamsFromRequest(httptest.NewRequest(http.MethodGet, "/qr?"+invalid, nil)); err == nil {
+			t.Errorf("Expected error for query %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/onederx/bitcoin-processing/settings"
+)
+
+// loadWalletNotifyNetworks reads networks unsigned wallet notifications are
+// accepted from. Entries of api.auth.wallet_notify_from are CIDRs or single
+// IP addresses
+func loadWalletNotifyNetworks(s settings.Settings) []*net.IPNet {
+	var networks []*net.IPNet
+
+	for _, entry := range s.GetViper().GetStringSlice("api.auth.wallet_notify_from") {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Fatalf("Error: invalid address %q in api.auth.wallet_notify_from", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Fatalf("Error: invalid network %q in api.auth.wallet_notify_from: %v", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isTrustedWalletNotifier tells whether request was sent from one of networks
+// listed in api.auth.wallet_notify_from
+func (s *Server) isTrustedWalletNotifier(request *http.Request) bool {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.walletNotifyNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// walletNotifyAuthorized wraps handler of wallet notification endpoint.
+// Bitcoin node calls it from walletnotify hook and can't sign requests, so
+// unsigned requests are accepted from trusted networks (loopback by default).
+// Signed requests and requests from other addresses are checked as usual by
+// authorized
+func (s *Server) walletNotifyAuthorized(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	checked := s.authorized(role, handler)
+	return func(response http.ResponseWriter, request *http.Request) {
+		if request.Header.Get(APIKeyHeader) == "" && s.isTrustedWalletNotifier(request) {
+			handler(response, request)
+			return
+		}
+		checked(response, request)
+	}
+}
+
+// routeHandler wraps route handler with authentication and authorization
+func (s *Server) routeHandler(r route) http.HandlerFunc {
+	if r.walletNotify {
+		return s.walletNotifyAuthorized(r.role, r.handler)
+	}
+	return s.authorized(r.role, r.handler)
+}
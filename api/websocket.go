@@ -14,6 +14,8 @@ type SubscribeMessage struct {
 	Seq int `json:"seq"`
 }
 
+const websocketURL = "/ws"
+
 var upgrader = websocket.Upgrader{} // use default options
 
 func shutdownConnection(conn *websocket.Conn) {
@@ -102,7 +104,19 @@ func (s *Server) handleWebsocketConnection(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+func (s *Server) websocketRoute() route {
+	return route{
+		method:    http.MethodGet,
+		path:      websocketURL,
+		role:      ReaderRole,
+		handler:   s.handleWebsocketConnection,
+		summary:   "Subscribe to events over websocket",
+		websocket: true,
+	}
+}
+
 func (s *Server) initWebsocketAPIServer() {
-	requestDispatcher := s.httpServer.Handler.(*http.ServeMux)
-	requestDispatcher.HandleFunc("/ws", s.handleWebsocketConnection)
+	r := s.websocketRoute()
+	requestDispatcher := s.httpServer.Handler.(handlerRegistrar)
+	requestDispatcher.HandleFunc(r.path, s.routeHandler(r))
 }
@@ -0,0 +1,219 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// AddressType is an enum of Bitcoin address types, named as in Bitcoin Core
+// (and accepted by getnewaddress): "legacy" (P2PKH), "p2sh-segwit" (P2SH, for
+// new addresses it wraps segwit v0 script), "bech32" (segwit v0) and
+// "bech32m" (segwit v1 and later, like Taproot)
+type AddressType int
+
+// Possible address types.
+// InvalidAddressType is used for unknown, uninitialized values and
+// conversions from invalid strings. When generating address it means that
+// type is not set and default of Bitcoin node is used
+const (
+	InvalidAddressType AddressType = iota
+	LegacyAddress
+	P2SHSegwitAddress
+	Bech32Address
+	Bech32mAddress
+)
+
+var addressTypeToStringMap = map[AddressType]string{
+	LegacyAddress:      "legacy",
+	P2SHSegwitAddress:  "p2sh-segwit",
+	Bech32Address:      "bech32",
+	Bech32mAddress:     "bech32m",
+	InvalidAddressType: "invalid",
+}
+
+var stringToAddressTypeMap = make(map[string]AddressType)
+
+// version bytes of base58 addresses for mainnet and testnet/regtest
+var (
+	p2pkhVersions = map[byte]bool{0x00: true, 0x6f: true}
+	p2shVersions  = map[byte]bool{0x05: true, 0xc4: true}
+)
+
+// human-readable parts of segwit addresses for mainnet, testnet and regtest
+var segwitHRPs = map[string]bool{"bc": true, "tb": true, "bcrt": true}
+
+const (
+	bech32Charset        = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32Constant       = 1
+	bech32mConstant      = 0x2bc830a3
+	bech32ChecksumLength = 6
+)
+
+func init() {
+	for addressType, addressTypeStr := range addressTypeToStringMap {
+		stringToAddressTypeMap[addressTypeStr] = addressType
+	}
+}
+
+func (at AddressType) String() string {
+	addressTypeStr, ok := addressTypeToStringMap[at]
+	if !ok {
+		return "invalid"
+	}
+	return addressTypeStr
+}
+
+// AddressTypeFromString converts string to AddressType. Empty string and
+// "invalid" are converted to InvalidAddressType without producing an error
+// because it is used when type is not set, all other unknown values produce
+// InvalidAddressType and an error
+func AddressTypeFromString(addressTypeStr string) (AddressType, error) {
+	if addressTypeStr == "" {
+		return InvalidAddressType, nil
+	}
+	at, ok := stringToAddressTypeMap[addressTypeStr]
+	if !ok {
+		return InvalidAddressType, errors.New(
+			"Failed to convert string '" + addressTypeStr + "' to address " +
+				"type: should be 'legacy', 'p2sh-segwit', 'bech32' or 'bech32m'",
+		)
+	}
+	return at, nil
+}
+
+// MarshalJSON serializes AddressType to JSON and simply returns string
+// representation of given AddressType
+func (at AddressType) MarshalJSON() ([]byte, error) {
+	return []byte("\"" + at.String() + "\""), nil
+}
+
+// UnmarshalJSON deserializes AddressType from JSON. Resulting value is
+// mapped from string representation of address type
+func (at *AddressType) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+	*at, err = AddressTypeFromString(j)
+	return err
+}
+
+// AddressTypeOf validates given address and tells its type. Addresses of
+// mainnet, testnet and regtest are accepted; it is up to Bitcoin node to
+// reject address of other network. All P2SH addresses are reported as
+// "p2sh-segwit" because address itself does not tell what script it wraps
+func AddressTypeOf(address string) (AddressType, error) {
+	if pos := strings.LastIndexByte(address, '1'); pos > 0 && segwitHRPs[strings.ToLower(address[:pos])] {
+		return segwitAddressType(address)
+	}
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return InvalidAddressType, fmt.Errorf("invalid address %q: %v", address, err)
+	}
+	if len(payload) != 20 {
+		return InvalidAddressType, fmt.Errorf("invalid address %q: wrong length", address)
+	}
+	switch {
+	case p2pkhVersions[version]:
+		return LegacyAddress, nil
+	case p2shVersions[version]:
+		return P2SHSegwitAddress, nil
+	}
+	return InvalidAddressType, fmt.Errorf("invalid address %q: unknown version %#x", address, version)
+}
+
+// segwitAddressType decodes bech32 or bech32m segwit address (BIP 173, BIP
+// 350). Witness version 0 requires bech32 checksum, later versions require
+// bech32m
+func segwitAddressType(address string) (AddressType, error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return InvalidAddressType, fmt.Errorf("invalid address %q: mixed case", address)
+	}
+	address = strings.ToLower(address)
+	pos := strings.LastIndexByte(address, '1')
+	hrp, data := address[:pos], address[pos+1:]
+	if len(address) > 90 || len(data) < bech32ChecksumLength+1 {
+		return InvalidAddressType, fmt.Errorf("invalid address %q: wrong length", address)
+	}
+
+	values := make([]byte, len(data))
+	for i := range data {
+		value := strings.IndexByte(bech32Charset, data[i])
+		if value < 0 {
+			return InvalidAddressType, fmt.Errorf("invalid address %q: invalid character %q", address, data[i])
+		}
+		values[i] = byte(value)
+	}
+
+	witnessVersion := values[0]
+	wantConstant := uint32(bech32Constant)
+	addressType := Bech32Address
+	if witnessVersion > 0 {
+		wantConstant, addressType = bech32mConstant, Bech32mAddress
+	}
+	if bech32Polymod(append(bech32ExpandHRP(hrp), values...)) != wantConstant {
+		return InvalidAddressType, fmt.Errorf("invalid address %q: wrong checksum", address)
+	}
+
+	program, ok := convertBits(values[1:len(values)-bech32ChecksumLength], 5, 8)
+	switch {
+	case !ok || witnessVersion > 16:
+		return InvalidAddressType, fmt.Errorf("invalid address %q: invalid witness program", address)
+	case len(program) < 2 || len(program) > 40:
+		return InvalidAddressType, fmt.Errorf("invalid address %q: wrong witness program length", address)
+	case witnessVersion == 0 && len(program) != 20 && len(program) != 32:
+		return InvalidAddressType, fmt.Errorf("invalid address %q: wrong witness program length", address)
+	}
+	return addressType, nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, value := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(value)
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 != 0 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, 2*len(hrp)+1)
+	for i := range hrp {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := range hrp {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// convertBits regroups data from groups of fromBits bits to groups of toBits
+// bits without padding, as required for decoding of witness program
+func convertBits(data []byte, fromBits, toBits uint) ([]byte, bool) {
+	var result []byte
+	acc, bits := uint32(0), uint(0)
+	for _, value := range data {
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&(1<<toBits-1)))
+		}
+	}
+	if bits >= fromBits || acc&(1<<bits-1) != 0 {
+		return nil, false
+	}
+	return result, true
+}
@@ -0,0 +1,109 @@
+package bitcoin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddressTypeOfValid(t *testing.T) {
+	tests := []struct {
+		address  string
+		wantType AddressType
+	}{
+		// base58check, mainnet and testnet/regtest
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", LegacyAddress},
+		{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", LegacyAddress},
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", P2SHSegwitAddress},
+		{"2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", P2SHSegwitAddress},
+
+		// valid segwit addresses from BIP 350 (which supersedes BIP 173 for
+		// witness versions 1 and later)
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", Bech32Address},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", Bech32Address},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", Bech32mAddress},
+		{"BC1SW50QGDZ25J", Bech32mAddress},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", Bech32mAddress},
+		{"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", Bech32Address},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", Bech32mAddress},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", Bech32mAddress},
+
+		// regtest
+		{"bcrt1qqqqsyqcyq5rqwzqfpg9scrgwpugpzysnard0ew", Bech32Address},
+		{"bcrt1pqqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0sj9hjuh", Bech32mAddress},
+	}
+
+	for _, test := range tests {
+		got, err := AddressTypeOf(test.address)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.address, err)
+			continue
+		}
+		if got != test.wantType {
+			t.Errorf("%s: expected address type %s, got %s", test.address,
+				test.wantType, got)
+		}
+	}
+}
+
+func TestAddressTypeOfInvalid(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr string
+	}{
+		// base58check
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", "checksum error"},
+		{"112D2adLM3UKy4Z4giRbReR6gjWuvHUqC", "checksum error"},
+		{"QLdC8sv3XWM9QCe673k5YvCjBzTceRHcX", "unknown version 0x1"},
+		{"116L5yRNPTuciSgXGHqYwn9N6NeoGU45ux", "wrong length"},
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN0", "invalid format"},
+		{"", "invalid format"},
+
+		// addresses of other networks: Litecoin version byte and
+		// human-readable parts
+		{"LKDyUEtTR1HXamkiEphisSiBJu6o3ZPE34", "unknown version 0x30"},
+		{"ltc1qqqqsyqcyq5rqwzqfpg9scrgwpugpzysn3s44dy", "invalid format"},
+		{"tc1qw508d6qejxtdg4y5r3zarvary0c5xw7kg3g4ty", "invalid format"},
+		{"tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", "invalid format"},
+
+		// invalid segwit addresses from BIP 173
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", "wrong checksum"},
+		{"BC13W508D6QEJXTDG4Y5R3ZARVARY0C5XW7KN40WF2", "wrong checksum"},
+		{"bc1rw5uspcuh", "wrong checksum"},
+		{"bc10w508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kw5rljs90", "wrong checksum"},
+		{"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", "wrong witness program length"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sL5k7", "mixed case"},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvqyzf3du", "wrong checksum"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3pjxtptv", "invalid witness program"},
+		{"bc1gmk9yu", "wrong length"},
+
+		// invalid segwit addresses from BIP 350
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", "wrong checksum"},
+		{"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf", "wrong checksum"},
+		{"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", "wrong checksum"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", "wrong checksum"},
+		{"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47", "wrong checksum"},
+		{"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", "invalid character"},
+		{"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", "invalid witness program"},
+		{"bc1pw5dgrnzv", "wrong witness program length"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", "wrong witness program length"},
+		{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq", "mixed case"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf", "invalid witness program"},
+		{"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j", "invalid witness program"},
+	}
+
+	for _, test := range tests {
+		got, err := AddressTypeOf(test.address)
+		if err == nil {
+			t.Errorf("%s: expected error, got address type %s", test.address, got)
+			continue
+		}
+		if got != InvalidAddressType {
+			t.Errorf("%s: expected address type %s with error, got %s",
+				test.address, InvalidAddressType, got)
+		}
+		if !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v",
+				test.address, test.wantErr, err)
+		}
+	}
+}
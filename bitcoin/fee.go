@@ -16,26 +16,31 @@ const MinimalFeeRate = 1000 // 1 satoshi per byte
 var MinimalFeeRateBTC = btcutil.Amount(MinimalFeeRate).ToBTC()
 
 // FeeType is an enum for setting how fee should be calculated for outgoing
-// transactions (withdrawals). There are two possible types: rate per kilobyte
-// (which is traditional and used by Bitcoin Core by default, with it given
-// fee value will be multiplied by tx size in kilobytes) and fixed (resulting
-// fee value will be equal to given fee value)
+// transactions (withdrawals). There are three possible types: rate per
+// kilobyte (which is traditional and used by Bitcoin Core by default, with it
+// given fee value will be multiplied by tx size in kilobytes), fixed (resulting
+// fee value will be equal to given fee value) and smart (rate per kilobyte is
+// estimated by Bitcoin node for given confirmation target)
 type FeeType int
 
 // Possible fee types.
 // PerKBRateFee means fee paid will equal given fee value times tx size in KB
 // FixedFee means fee paid will be exatly equal to given fee value
+// SmartFee means fee rate per KB is estimated by Bitcoin node so that tx is
+// likely to be confirmed within given number of blocks
 // InvalidFee means fee type is invalid and is used for unknown, uninitialized
 // values, and conversions from other types when source value is invalid
 const (
 	InvalidFee FeeType = iota
 	PerKBRateFee
 	FixedFee
+	SmartFee
 )
 
 var feeTypeToStringMap = map[FeeType]string{
 	FixedFee:     "fixed",
 	PerKBRateFee: "per-kb-rate",
+	SmartFee:     "smart",
 	InvalidFee:   "invalid",
 }
 
@@ -56,7 +61,8 @@ func (ft FeeType) String() string {
 }
 
 // FeeTypeFromString converts string to FeeType. "fixed" is converted to
-// FixedFee, "per-kb-rate" is converted to PerKBRateFee
+// FixedFee, "per-kb-rate" is converted to PerKBRateFee, "smart" is converted
+// to SmartFee
 // Value "invalid" is converted to InvalidFee without producing an error
 // because InvalidFee is used in normal conditions when we don't know real
 // fee type (for incoming payments for example)
@@ -88,3 +94,70 @@ func (ft *FeeType) UnmarshalJSON(b []byte) error {
 	*ft, err = FeeTypeFromString(j)
 	return err
 }
+
+// FeePayer is an enum for setting who pays fee of outgoing transaction
+// (withdrawal): recipient (fee is subtracted from amount sent, so recipient
+// gets less than requested) or sender (recipient gets exactly the amount
+// requested and fee is paid by wallet on top of it)
+type FeePayer int
+
+// Possible fee payers.
+// InvalidFeePayer is used for unknown, uninitialized values (for incoming
+// payments for example) and conversions from invalid strings
+const (
+	InvalidFeePayer FeePayer = iota
+	RecipientPaysFee
+	SenderPaysFee
+)
+
+var feePayerToStringMap = map[FeePayer]string{
+	RecipientPaysFee: "recipient",
+	SenderPaysFee:    "sender",
+	InvalidFeePayer:  "invalid",
+}
+
+var stringToFeePayerMap = make(map[string]FeePayer)
+
+func init() {
+	for feePayer, feePayerStr := range feePayerToStringMap {
+		stringToFeePayerMap[feePayerStr] = feePayer
+	}
+}
+
+func (fp FeePayer) String() string {
+	feePayerStr, ok := feePayerToStringMap[fp]
+	if !ok {
+		return "invalid"
+	}
+	return feePayerStr
+}
+
+// FeePayerFromString converts string to FeePayer. "recipient" is converted to
+// RecipientPaysFee, "sender" is converted to SenderPaysFee, "invalid" is
+// converted to InvalidFeePayer without producing an error, all other values
+// produce InvalidFeePayer and an error
+func FeePayerFromString(feePayerStr string) (FeePayer, error) {
+	fp, ok := stringToFeePayerMap[feePayerStr]
+	if !ok {
+		return InvalidFeePayer, errors.New(
+			"Failed to convert string '" + feePayerStr + "' to fee payer",
+		)
+	}
+	return fp, nil
+}
+
+// MarshalJSON serializes FeePayer to JSON as its string representation
+func (fp FeePayer) MarshalJSON() ([]byte, error) {
+	return []byte("\"" + fp.String() + "\""), nil
+}
+
+// UnmarshalJSON deserializes FeePayer from its string representation in JSON
+func (fp *FeePayer) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+	*fp, err = FeePayerFromString(j)
+	return err
+}
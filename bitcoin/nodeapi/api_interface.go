@@ -8,14 +8,21 @@ import (
 
 // NodeAPI is responsible for communication with Bitcoin node
 type NodeAPI interface {
-	CreateNewAddress() (string, error)
+	CreateNewAddress(addressType bitcoin.AddressType) (string, error)
 	CreateWallet(name string) error
 	ListTransactionsSinceBlock(blockHash string) (*btcjson.ListSinceBlockResult, error)
 	GetTransaction(hash string) (*btcjson.GetTransactionResult, error)
 	GetRawTransaction(hash string) (*btcjson.TxRawResult, error)
+	GetBestBlockHash() (string, error)
+	IsInMempool(hash string) (bool, error)
+	RebroadcastTransaction(hash string) error
 	SendWithPerKBFee(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (hash string, err error)
 	SendWithFixedFee(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (hash string, err error)
 	SendToMultipleAddresses(addresses map[string]bitcoin.BTCAmount) (hash string, err error)
+	SendManyWithPerKBFee(addresses map[string]bitcoin.BTCAmount, fee bitcoin.BTCAmount, recipientsPayFee bool) (hash string, err error)
+	BumpFee(hash string, fee bitcoin.BTCAmount, feeType bitcoin.FeeType, recipientsPayFee bool) (newHash string, err error)
+	ChildPaysForParent(parentHash, parentAddress, destination string, feeRate bitcoin.BTCAmount) (*CPFPTransaction, error)
+	EstimateSmartFee(confTarget int, estimateMode string) (*SmartFeeEstimate, error)
 	GetAddressInfo(address string) (*AddressInfo, error)
 	GetConfirmedAndUnconfirmedBalance() (uint64, uint64, error)
 
@@ -79,6 +79,36 @@ type AddressInfo struct {
 	Labels        []string
 }
 
+// SmartFeeEstimate is a structure returned by Bitcoin node RPC API in response
+// to estimatesmartfee call. FeeRate is estimated fee rate in BTC per kilobyte,
+// it is nil if node was unable to make an estimate (for example, because it
+// has not seen enough blocks yet), in this case Errors describe the reason.
+// Blocks is a number of blocks for which estimate is valid
+type SmartFeeEstimate struct {
+	FeeRate *float64 `json:"feerate"`
+	Errors  []string `json:"errors"`
+	Blocks  int64    `json:"blocks"`
+}
+
+// CPFPTransaction describes Child-Pays-For-Parent tx made by
+// ChildPaysForParent: its hash, amount it sends and fee it pays
+type CPFPTransaction struct {
+	Hash   string
+	Amount bitcoin.BTCAmount
+	Fee    bitcoin.BTCAmount
+}
+
+// mempoolEntry is a part of getmempoolentry response. AncestorSize is a total
+// virtual size of tx and its unconfirmed ancestors, Fees.Ancestor is their
+// total fee in BTC
+type mempoolEntry struct {
+	Vsize        int64 `json:"vsize"`
+	AncestorSize int64 `json:"ancestorsize"`
+	Fees         struct {
+		Ancestor float64 `json:"ancestor"`
+	} `json:"fees"`
+}
+
 type jsonRPCRequest struct {
 	JSONRPCVersion string        `json:"jsonrpc"`
 	Method         string        `json:"method"`
@@ -100,8 +130,13 @@ type fundRawTransactionOptions struct {
 	ChangePosition         int     `json:"changePosition"`
 	FeeRate                float64 `json:"feeRate"`
 	SubtractFeeFromOutputs []int   `json:"subtractFeeFromOutputs"`
+	Replaceable            bool    `json:"replaceable"`
 }
 
+// dustThreshold is a minimal value of output (in satoshis) Bitcoin node
+// relays. Fee bump refuses to make outputs smaller than this
+const dustThreshold = 546
+
 type fundRawTransactionResult struct {
 	Changepos int
 	Fee       float64
@@ -116,11 +151,17 @@ func (err JSONRPCError) Error() string {
 	)
 }
 
-// CreateNewAddress creates new Bitcoin address belonging to current wallet
-// Address is returned as a string
-func (n *bitcoinNodeRPCAPI) CreateNewAddress() (string, error) {
+// CreateNewAddress creates new Bitcoin address of given type belonging to
+// current wallet. If type is bitcoin.InvalidAddressType, node default
+// ("-addresstype" option) is used. Address is returned as a string
+func (n *bitcoinNodeRPCAPI) CreateNewAddress(addressType bitcoin.AddressType) (string, error) {
+	var params []interface{}
+	if addressType != bitcoin.InvalidAddressType {
+		// first param is label
+		params = []interface{}{"", addressType.String()}
+	}
 	// there is GetNewAddress in btcd/rpcclient, but they have broken support for regtest
-	responseJSON, err := n.SendRequestToNode("getnewaddress", nil)
+	responseJSON, err := n.SendRequestToNode("getnewaddress", params)
 	if err != nil {
 		return "", err
 	}
@@ -194,6 +235,16 @@ func (n *bitcoinNodeRPCAPI) GetRawTransaction(hash string) (*btcjson.TxRawResult
 	return n.decodeRawTransaction(transaction.Hex)
 }
 
+// GetBestBlockHash returns hash of the tip of the most-work chain known to
+// node
+func (n *bitcoinNodeRPCAPI) GetBestBlockHash() (string, error) {
+	hash, err := n.btcrpc.GetBestBlockHash()
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
 func (n *bitcoinNodeRPCAPI) SendRequestToNode(method string, params []interface{}) ([]byte, error) {
 	rpcRequest := jsonRPCRequest{
 		JSONRPCVersion: "1.0",
@@ -288,6 +339,7 @@ func (n *bitcoinNodeRPCAPI) sendToAddress(address string, amount uint64, recipie
 			"",
 			"",
 			recipientPaysFee,
+			true, // replaceable: signal BIP 125 so that fee can be bumped
 		},
 	)
 	if err != nil {
@@ -306,10 +358,87 @@ func (n *bitcoinNodeRPCAPI) sendToAddress(address string, amount uint64, recipie
 }
 
 // SendToMultipleAddresses sends bitcoins to multiple receiving addresses as
-// specified by map 'addresses' in 1 bitcoin transaction. For now this is used
-// by tests only, so sender pays the fee which is not configurable.
+// specified by map 'addresses' in 1 bitcoin transaction. Sender pays the fee
+// which is calculated by node. This is used for batch withdrawals.
 // On success, resulting bitcoin tx hash is returned as first return value
 func (n *bitcoinNodeRPCAPI) SendToMultipleAddresses(addresses map[string]bitcoin.BTCAmount) (hash string, err error) {
+	return n.sendMany(addresses, nil)
+}
+
+// getPayTxFee returns per kilobyte fee rate currently set in wallet of
+// Bitcoin node by settxfee (0 means node estimates fee itself)
+func (n *bitcoinNodeRPCAPI) getPayTxFee() (btcutil.Amount, error) {
+	// there is no GetWalletInfo in btcd/rpcclient
+	walletInfoJSONResp, err := n.SendRequestToNode(
+		"getwalletinfo",
+		[]interface{}{},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Result *struct {
+			PayTxFee float64 `json:"paytxfee"`
+		}
+		Error *JSONRPCError
+	}
+	err = json.Unmarshal(walletInfoJSONResp, &response)
+	if err != nil {
+		return 0, err
+	}
+	if response.Error != nil {
+		return 0, response.Error
+	}
+	if response.Result == nil {
+		return 0, errors.New("getwalletinfo returned empty result")
+	}
+	return btcutil.NewAmount(response.Result.PayTxFee)
+}
+
+// SendManyWithPerKBFee sends bitcoins to multiple receiving addresses in 1
+// bitcoin transaction with per kilobyte fee. Boolean argument
+// recipientsPayFee determines if fee is paid by recipients (in which case it
+// is split equally between them and subtracted from amounts they get) or by
+// sender.
+// Fee rate is set in node wallet with settxfee only for this call: rate that
+// was set before is restored afterwards, so that transactions created by node
+// itself (for example, with bitcoin-cli) do not silently get it.
+func (n *bitcoinNodeRPCAPI) SendManyWithPerKBFee(addresses map[string]bitcoin.BTCAmount, fee bitcoin.BTCAmount,
+	recipientsPayFee bool) (hash string, err error) {
+	n.moneySendLock.Lock()
+	defer n.moneySendLock.Unlock()
+
+	previousFee, err := n.getPayTxFee()
+	if err != nil {
+		return "", err
+	}
+
+	err = n.btcrpc.SetTxFee(btcutil.Amount(fee))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if restoreErr := n.btcrpc.SetTxFee(previousFee); restoreErr != nil {
+			log.Printf(
+				"Warning: failed to restore fee rate %s in node wallet "+
+					"after sendmany: %v",
+				previousFee,
+				restoreErr,
+			)
+		}
+	}()
+
+	var subtractFeeFrom []string
+	if recipientsPayFee {
+		for address := range addresses {
+			subtractFeeFrom = append(subtractFeeFrom, address)
+		}
+	}
+	return n.sendMany(addresses, subtractFeeFrom)
+}
+
+func (n *bitcoinNodeRPCAPI) sendMany(addresses map[string]bitcoin.BTCAmount, subtractFeeFrom []string) (hash string, err error) {
 	if len(addresses) == 0 {
 		return "", errors.New("SendToMultipleAddresses got empty list of addresses")
 	}
@@ -320,13 +449,20 @@ func (n *bitcoinNodeRPCAPI) SendToMultipleAddresses(addresses map[string]bitcoin
 		amountSpec[address] = json.Number(amount.ToStringedFloat())
 	}
 
-	responseJSON, err := n.SendRequestToNode(
-		"sendmany",
-		[]interface{}{
-			"", // by convention, first arg in an empty string (see docs https://bitcoin-rpc.github.io/en/doc/0.17.99/rpc/wallet/sendmany/)
-			amountSpec,
-		},
-	)
+	if subtractFeeFrom == nil {
+		subtractFeeFrom = []string{}
+	}
+
+	params := []interface{}{
+		"", // by convention, first arg in an empty string (see docs https://bitcoin-rpc.github.io/en/doc/0.17.99/rpc/wallet/sendmany/)
+		amountSpec,
+		1,  // minconf
+		"", // comment
+		subtractFeeFrom,
+		true, // replaceable: signal BIP 125 so that fee can be bumped
+	}
+
+	responseJSON, err := n.SendRequestToNode("sendmany", params)
 	if err != nil {
 		return "", err
 	}
@@ -368,7 +504,12 @@ func (n *bitcoinNodeRPCAPI) createRawTransaction(inputs []btcjson.TransactionInp
 	// but rpcclient later fails on parsing the result and returns error
 	createRawTxJSONResp, err := n.SendRequestToNode(
 		"createrawtransaction",
-		[]interface{}{inputs, outputs},
+		[]interface{}{
+			inputs,
+			outputs,
+			0,    // locktime
+			true, // replaceable: signal BIP 125 so that fee can be bumped
+		},
 	)
 	if err != nil {
 		return "", err
@@ -583,6 +724,7 @@ func (n *bitcoinNodeRPCAPI) SendWithFixedFee(address string, amount, fee bitcoin
 		FeeRate:                bitcoin.MinimalFeeRateBTC,
 		SubtractFeeFromOutputs: []int{0},
 		ChangePosition:         0,
+		Replaceable:            true,
 	})
 	if err != nil {
 		return "", err
@@ -606,6 +748,317 @@ func (n *bitcoinNodeRPCAPI) SendWithFixedFee(address string, amount, fee bitcoin
 	return n.sendRawTransaction(signedTx)
 }
 
+// BumpFee replaces unconfirmed tx with given hash by a copy that pays higher
+// fee, which makes miners more likely to include it in a block. Original tx
+// must signal replaceability as defined by BIP 125 (all txns made by this app
+// do). New fee is either exactly "fee" or, for per KB fee type, "fee" times
+// tx size in kilobytes. Fee increase is subtracted from recipient outputs
+// (split equally between them) if recipientsPayFee is true and from change
+// output otherwise.
+// Like in SendWithFixedFee, replacement is made "manually": outputs of
+// original tx are changed, then it is signed and broadcasted again. Hash of
+// replacement tx is returned
+func (n *bitcoinNodeRPCAPI) BumpFee(hash string, fee bitcoin.BTCAmount, feeType bitcoin.FeeType,
+	recipientsPayFee bool) (newHash string, err error) {
+	n.moneySendLock.Lock()
+	defer n.moneySendLock.Unlock()
+
+	walletTx, err := n.GetTransaction(hash)
+	if err != nil {
+		return "", err
+	}
+	if walletTx.Confirmations != 0 {
+		return "", fmt.Errorf(
+			"Can't bump fee of tx %s: it has %d confirmations",
+			hash, walletTx.Confirmations,
+		)
+	}
+	tx, err := n.decodeRawTransaction(walletTx.Hex)
+	if err != nil {
+		return "", err
+	}
+
+	// fee in wallet tx info is negative because it is spent by us
+	oldFee, err := btcutil.NewAmount(-walletTx.Fee)
+	if err != nil {
+		return "", err
+	}
+	var newFee btcutil.Amount
+	switch feeType {
+	case bitcoin.FixedFee:
+		newFee = btcutil.Amount(fee)
+	case bitcoin.PerKBRateFee:
+		newFee = btcutil.Amount(int64(fee) * int64(tx.Vsize) / 1000)
+	default:
+		return "", errors.New("Fee type not supported: " + feeType.String())
+	}
+	if newFee <= oldFee {
+		return "", fmt.Errorf(
+			"Can't bump fee of tx %s: new fee %s is not higher than current "+
+				"fee %s", hash, newFee, oldFee,
+		)
+	}
+
+	var recipientOuts, changeOuts []*btcjson.Vout
+	for i := range tx.Vout {
+		out := &tx.Vout[i]
+		if len(out.ScriptPubKey.Addresses) != 1 {
+			return "", fmt.Errorf("Expected that tx outputs will have 1 "+
+				"destination address, but %#v has %d. Tx %#v",
+				out, len(out.ScriptPubKey.Addresses), tx)
+		}
+		info, err := n.GetAddressInfo(out.ScriptPubKey.Addresses[0])
+		if err != nil {
+			return "", err
+		}
+		if info.IsMine {
+			changeOuts = append(changeOuts, out)
+		} else {
+			recipientOuts = append(recipientOuts, out)
+		}
+	}
+
+	payingOuts, payer := changeOuts, "change"
+	if recipientsPayFee {
+		payingOuts, payer = recipientOuts, "recipient"
+	}
+	if len(payingOuts) == 0 {
+		return "", fmt.Errorf(
+			"Can't bump fee of tx %s: it has no %s output to take fee "+
+				"increase from", hash, payer,
+		)
+	}
+
+	increase := newFee - oldFee
+	share := increase / btcutil.Amount(len(payingOuts))
+	for i, out := range payingOuts {
+		value, err := btcutil.NewAmount(out.Value)
+		if err != nil {
+			return "", err
+		}
+		value -= share
+		if i == 0 {
+			value -= increase % btcutil.Amount(len(payingOuts))
+		}
+		if value < dustThreshold {
+			return "", fmt.Errorf(
+				"Can't bump fee of tx %s to %s: %s output would be too small",
+				hash, newFee, payer,
+			)
+		}
+		out.Value = value.ToBTC()
+	}
+
+	replacementEncoded, err := n.encodeTransformedTransaction(tx)
+	if err != nil {
+		return "", err
+	}
+
+	signedTx, err := n.signRawTransactionWithWallet(replacementEncoded)
+	if err != nil {
+		return "", err
+	}
+
+	return n.sendRawTransaction(signedTx)
+}
+
+func (n *bitcoinNodeRPCAPI) getMempoolEntry(hash string) (*mempoolEntry, error) {
+	getMempoolEntryJSONResp, err := n.SendRequestToNode(
+		"getmempoolentry",
+		[]interface{}{hash},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result *mempoolEntry
+		Error  *JSONRPCError
+	}
+	err = json.Unmarshal(getMempoolEntryJSONResp, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
+
+// errCodeNotInMempool is a code of error Bitcoin node returns when requested
+// tx is not in its mempool
+const errCodeNotInMempool = -5
+
+// IsInMempool tells whether tx with given hash is in mempool of Bitcoin node
+func (n *bitcoinNodeRPCAPI) IsInMempool(hash string) (bool, error) {
+	_, err := n.getMempoolEntry(hash)
+	if rpcErr, ok := err.(*JSONRPCError); ok && rpcErr.Code == errCodeNotInMempool {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RebroadcastTransaction sends wallet tx with given hash to Bitcoin network
+// again. This is needed if tx was dropped from mempool, for example after node
+// restart. Node refuses to accept tx if its fee is too low for current mempool
+// or its inputs are already spent, in this case *JSONRPCError returned by
+// sendrawtransaction is returned
+func (n *bitcoinNodeRPCAPI) RebroadcastTransaction(hash string) error {
+	walletTx, err := n.GetTransaction(hash)
+	if err != nil {
+		return err
+	}
+	_, err = n.sendRawTransaction(walletTx.Hex)
+	return err
+}
+
+// createSignedTransaction creates tx spending given inputs to given outputs,
+// signs it and returns signed tx both encoded and decoded
+func (n *bitcoinNodeRPCAPI) createSignedTransaction(inputs []btcjson.TransactionInput,
+	outputs map[string]float64) (string, *btcjson.TxRawResult, error) {
+	rawTx, err := n.createRawTransaction(inputs, outputs)
+	if err != nil {
+		return "", nil, err
+	}
+	signedTx, err := n.signRawTransactionWithWallet(rawTx)
+	if err != nil {
+		return "", nil, err
+	}
+	tx, err := n.decodeRawTransaction(signedTx)
+	if err != nil {
+		return "", nil, err
+	}
+	return signedTx, tx, nil
+}
+
+// ChildPaysForParent accelerates unconfirmed tx with given hash which pays to
+// address of this wallet (parentAddress): output paying to parentAddress is
+// spent to destination address by a new (child) tx. Child tx pays fee high
+// enough to make fee rate of the whole package (child and its unconfirmed
+// ancestors) equal to feeRate, which is a rate per kilobyte. Miners select
+// txns by package fee rate, so parent is mined together with child.
+// Fee is subtracted from the output being spent. Info about ancestors is
+// taken from node mempool, so parent must be there
+func (n *bitcoinNodeRPCAPI) ChildPaysForParent(parentHash, parentAddress, destination string,
+	feeRate bitcoin.BTCAmount) (*CPFPTransaction, error) {
+	n.moneySendLock.Lock()
+	defer n.moneySendLock.Unlock()
+
+	walletTx, err := n.GetTransaction(parentHash)
+	if err != nil {
+		return nil, err
+	}
+	if walletTx.Confirmations != 0 {
+		return nil, fmt.Errorf(
+			"Can't accelerate tx %s: it has %d confirmations",
+			parentHash, walletTx.Confirmations,
+		)
+	}
+	parent, err := n.decodeRawTransaction(walletTx.Hex)
+	if err != nil {
+		return nil, err
+	}
+
+	var spentOut *btcjson.Vout
+	for i := range parent.Vout {
+		addresses := parent.Vout[i].ScriptPubKey.Addresses
+		if len(addresses) == 1 && addresses[0] == parentAddress {
+			spentOut = &parent.Vout[i]
+			break
+		}
+	}
+	if spentOut == nil {
+		return nil, fmt.Errorf(
+			"Can't accelerate tx %s: it has no output paying to %s",
+			parentHash, parentAddress,
+		)
+	}
+	value, err := btcutil.NewAmount(spentOut.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := n.getMempoolEntry(parentHash)
+	if err != nil {
+		return nil, err
+	}
+	ancestorFees, err := btcutil.NewAmount(entry.Fees.Ancestor)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := []btcjson.TransactionInput{{Txid: parentHash, Vout: spentOut.N}}
+
+	// make child without fee first to learn its size
+	_, child, err := n.createSignedTransaction(
+		inputs,
+		map[string]float64{destination: value.ToBTC()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := int64(feeRate)
+	fee := btcutil.Amount(rate*(entry.AncestorSize+int64(child.Vsize))/1000) - ancestorFees
+	if ownFee := btcutil.Amount(rate * int64(child.Vsize) / 1000); fee < ownFee {
+		// ancestors already pay more than requested rate, child should
+		// not lower rate of the package
+		fee = ownFee
+	}
+	if value-fee < dustThreshold {
+		return nil, fmt.Errorf(
+			"Can't accelerate tx %s: output of %s is too small to pay fee %s",
+			parentHash, value, fee,
+		)
+	}
+
+	signedTx, _, err := n.createSignedTransaction(
+		inputs,
+		map[string]float64{destination: (value - fee).ToBTC()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := n.sendRawTransaction(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	return &CPFPTransaction{
+		Hash:   hash,
+		Amount: bitcoin.BTCAmount(value - fee),
+		Fee:    bitcoin.BTCAmount(fee),
+	}, nil
+}
+
+// EstimateSmartFee asks Bitcoin node to estimate fee rate per kilobyte needed
+// for tx to be confirmed within confTarget blocks. estimateMode is either
+// "economical" or "conservative" (the latter considers longer history of
+// blocks and is less likely to underpay).
+// Absence of estimate is not an error: check FeeRate of the result
+func (n *bitcoinNodeRPCAPI) EstimateSmartFee(confTarget int, estimateMode string) (*SmartFeeEstimate, error) {
+	estimateSmartFeeJSONResp, err := n.SendRequestToNode(
+		"estimatesmartfee",
+		[]interface{}{confTarget, estimateMode},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Result *SmartFeeEstimate
+		Error  *JSONRPCError
+	}
+	err = json.Unmarshal(estimateSmartFeeJSONResp, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
+
 // GetAddressInfo gets verbose info about Bitcoin address. This can be used to
 // check if given address belongs to current wallet, which is a primary usage
 // of this function for now.
@@ -0,0 +1,35 @@
+package bitcoin
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PaymentURI makes BIP 21 URI requesting payment to given address, like
+// "bitcoin:mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB?amount=0.1&label=Shop".
+// Zero amount and empty label or message are omitted. Wallets usually open
+// such URIs (and QR codes containing them) with payment form filled
+func PaymentURI(address string, amount BTCAmount, label, message string) string {
+	var params []string
+
+	if amount > 0 {
+		params = append(params, "amount="+amount.ToStringedFloat())
+	}
+	if label != "" {
+		params = append(params, "label="+escapeURIParam(label))
+	}
+	if message != "" {
+		params = append(params, "message="+escapeURIParam(message))
+	}
+	uri := "bitcoin:" + address
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+// escapeURIParam percent-encodes value of URI parameter. Spaces are encoded
+// as %20 because BIP 21 follows RFC 3986 which does not treat '+' as space
+func escapeURIParam(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
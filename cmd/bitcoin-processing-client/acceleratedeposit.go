@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet"
+)
+
+func init() {
+	var cmdAccelerateDeposit = &cobra.Command{
+		Use:     "accelerate_deposit TX_ID FEE_RATE",
+		Example: "accelerate_deposit aec79cbf-79c4-46ef-a54f-63a0cf451fe2 0.0002",
+		Short:   "Speed up unconfirmed deposit with Child-Pays-For-Parent tx",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			txID, err := uuid.FromString(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			feeRate, err := bitcoin.BTCAmountFromStringedFloat(args[1])
+			if err != nil {
+				log.Fatalf(
+					"Failed to convert given fee rate value %q to bitcoin amount",
+					args[1])
+			}
+			showResponse(newClient().AccelerateDeposit(&wallet.AccelerateDepositRequest{
+				ID:      txID,
+				FeeRate: feeRate,
+			}))
+		},
+	}
+	cli.AddCommand(cmdAccelerateDeposit)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/onederx/bitcoin-processing/api"
+	"github.com/onederx/bitcoin-processing/wallet"
+)
+
+func init() {
+	var metainfoFilter string
+	var pageSize int
+	var cursor string
+	var singlePage bool
+
+	var cmdGetAccounts = &cobra.Command{
+		Use:   "get_accounts",
+		Short: "Get list of accounts, optionally filtered by metainfo",
+		Long: "Get list of accounts sorted by address, optionally filtered by " +
+			"metainfo. Accounts are requested from server page by page until " +
+			"all of them are fetched. With --single-page only one page is " +
+			"fetched and cursor of next page is printed to stderr",
+		Run: func(cmd *cobra.Command, args []string) {
+			filter := api.GetAccountsFilter{Limit: pageSize, Cursor: cursor}
+			if metainfoFilter != "" {
+				err := json.Unmarshal([]byte(metainfoFilter), &filter.Metainfo)
+				if err != nil {
+					log.Fatalf("Checking that metainfo is a valid JSON failed: %s", err)
+				}
+			}
+			cli := newClient()
+			if !singlePage {
+				showResponse(cli.GetAccounts(&filter))
+				return
+			}
+			accounts, nextCursor, err := cli.GetAccountsPage(&filter)
+			showResponse(accounts, err)
+			if nextCursor != "" {
+				log.Printf("Next page cursor: %s", nextCursor)
+			}
+		},
+	}
+
+	cmdGetAccounts.Flags().StringVarP(&metainfoFilter, "metainfo", "m", "", "only accounts which metainfo contains given JSON, e.g. '{\"user_id\": 42}'")
+	cmdGetAccounts.Flags().IntVarP(&pageSize, "page-size", "l", wallet.DefaultPageSize, "number of accounts requested at once")
+	cmdGetAccounts.Flags().StringVarP(&cursor, "cursor", "c", "", "cursor of page to start from")
+	cmdGetAccounts.Flags().BoolVar(&singlePage, "single-page", false, "fetch only one page")
+
+	cli.AddCommand(cmdGetAccounts)
+
+	cli.AddCommand(&cobra.Command{
+		Use:     "update_account_metainfo ADDRESS METAINFO",
+		Example: `update_account_metainfo 2N2wS8ZfiJXEAS5DCCEtKcHtB1EeXk7kgjV '{"user_id": 42}'`,
+		Short:   "Replace metainfo of account with given address",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var metainfo map[string]interface{}
+
+			if err := json.Unmarshal([]byte(args[1]), &metainfo); err != nil {
+				log.Fatalf("Checking that metainfo is a valid JSON object failed: %s", err)
+			}
+			showResponse(newClient().UpdateAccountMetainfo(args[0], metainfo))
+		},
+	})
+}
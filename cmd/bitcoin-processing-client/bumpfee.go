@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet"
+)
+
+func init() {
+	var bumpFeeType string
+
+	var cmdBumpFee = &cobra.Command{
+		Use:     "bump_fee TX_ID FEE",
+		Example: "bump_fee aec79cbf-79c4-46ef-a54f-63a0cf451fe2 0.0005",
+		Short:   "Replace unconfirmed withdrawal by tx paying higher fee",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			txID, err := uuid.FromString(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			fee, err := bitcoin.BTCAmountFromStringedFloat(args[1])
+			if err != nil {
+				log.Fatalf(
+					"Failed to convert given fee value %q to bitcoin amount",
+					args[1])
+			}
+			showResponse(newClient().BumpFee(&wallet.BumpFeeRequest{
+				ID:      txID,
+				Fee:     fee,
+				FeeType: bumpFeeType,
+			}))
+		},
+	}
+	cmdBumpFee.Flags().StringVarP(&bumpFeeType, "fee-type", "t", bitcoin.PerKBRateFee.String(), "new fee type: per-kb-rate or fixed")
+	cli.AddCommand(cmdBumpFee)
+}
@@ -5,8 +5,6 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/spf13/cobra"
-
-	"github.com/onederx/bitcoin-processing/api/client"
 )
 
 func init() {
@@ -25,7 +23,7 @@ func init() {
 				if err != nil {
 					log.Fatal(err)
 				}
-				cli := client.NewClient(apiURL)
+				cli := newClient()
 				switch command {
 				case "confirm":
 					err = cli.Confirm(txID)
@@ -2,8 +2,6 @@ package main
 
 import (
 	"github.com/spf13/cobra"
-
-	"github.com/onederx/bitcoin-processing/api/client"
 )
 
 func init() {
@@ -13,7 +11,7 @@ func init() {
 		Use:   "get_events",
 		Short: "Request events (optionally starting with given seq)",
 		Run: func(cmd *cobra.Command, args []string) {
-			showResponse(client.NewClient(apiURL).GetEvents(startSeq))
+			showResponse(newClient().GetEvents(startSeq))
 		},
 	}
 
@@ -1,20 +1,45 @@
 package main
 
 import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofrs/uuid"
 	"github.com/spf13/cobra"
 
 	"github.com/onederx/bitcoin-processing/api"
-	"github.com/onederx/bitcoin-processing/api/client"
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet"
 	"github.com/onederx/bitcoin-processing/wallet/types"
 )
 
 func init() {
 	var directionFilter string
 	var statusFilter string
+	var addressFilter, hashFilter string
+	var batchIDFilter string
+	var parentIDFilter string
+	var coldStorageFilter string
+	var minAmountFilter, maxAmountFilter string
+	var createdAfterFilter, createdBeforeFilter string
+	var updatedAfterFilter, updatedBeforeFilter string
+	var metainfoFilter string
+	var order string
+	var pageSize int
+	var cursor string
+	var singlePage bool
+
+	filter := api.GetTransactionsFilter{}
 
 	var cmdGetTransactions = &cobra.Command{
 		Use:   "get_transactions",
 		Short: "Get list of transactions, optionally filtered by status or direction",
+		Long: "Get list of transactions, optionally filtered by status or " +
+			"direction. Transactions are requested from server page by page " +
+			"until all of them are fetched. With --single-page only one page " +
+			"is fetched and cursor of next page is printed to stderr",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if directionFilter != "" {
 				_, err := types.TransactionDirectionFromString(directionFilter)
@@ -28,20 +53,105 @@ func init() {
 					return err
 				}
 			}
+			filter = api.GetTransactionsFilter{
+				Direction: directionFilter,
+				Status:    statusFilter,
+				Address:   addressFilter,
+				Hash:      hashFilter,
+				Order:     order,
+				Limit:     pageSize,
+				Cursor:    cursor,
+			}
+			var err error
+			if batchIDFilter != "" {
+				batchID, err := uuid.FromString(batchIDFilter)
+				if err != nil {
+					return err
+				}
+				filter.BatchID = &batchID
+			}
+			if parentIDFilter != "" {
+				parentID, err := uuid.FromString(parentIDFilter)
+				if err != nil {
+					return err
+				}
+				filter.ParentID = &parentID
+			}
+			if coldStorageFilter != "" {
+				coldStorage, err := strconv.ParseBool(coldStorageFilter)
+				if err != nil {
+					return err
+				}
+				filter.ColdStorage = &coldStorage
+			}
+			if minAmountFilter != "" {
+				if filter.MinAmount, err = bitcoin.BTCAmountFromStringedFloat(minAmountFilter); err != nil {
+					return err
+				}
+			}
+			if maxAmountFilter != "" {
+				if filter.MaxAmount, err = bitcoin.BTCAmountFromStringedFloat(maxAmountFilter); err != nil {
+					return err
+				}
+			}
+			timeFilters := []struct {
+				value  string
+				target **time.Time
+			}{
+				{createdAfterFilter, &filter.CreatedAfter},
+				{createdBeforeFilter, &filter.CreatedBefore},
+				{updatedAfterFilter, &filter.UpdatedAfter},
+				{updatedBeforeFilter, &filter.UpdatedBefore},
+			}
+			for _, timeFilter := range timeFilters {
+				if timeFilter.value == "" {
+					continue
+				}
+				t, err := time.Parse(time.RFC3339Nano, timeFilter.value)
+				if err != nil {
+					return err
+				}
+				*timeFilter.target = &t
+			}
+			if metainfoFilter != "" {
+				if err = json.Unmarshal([]byte(metainfoFilter), &filter.Metainfo); err != nil {
+					return err
+				}
+			}
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			filter := api.GetTransactionsFilter{
-				Direction: directionFilter,
-				Status:    statusFilter,
+			cli := newClient()
+			if !singlePage {
+				showResponse(cli.GetTransactions(&filter))
+				return
+			}
+			txns, nextCursor, err := cli.GetTransactionsPage(&filter)
+			showResponse(txns, err)
+			if nextCursor != "" {
+				log.Printf("Next page cursor: %s", nextCursor)
 			}
-			cli := client.NewClient(apiURL)
-			showResponse(cli.GetTransactions(&filter))
 		},
 	}
 
 	cmdGetTransactions.Flags().StringVarP(&directionFilter, "direction", "d", "", "tx direction filter")
 	cmdGetTransactions.Flags().StringVarP(&statusFilter, "status", "s", "", "tx status filter")
+	cmdGetTransactions.Flags().StringVarP(&addressFilter, "address", "a", "", "tx address filter")
+	cmdGetTransactions.Flags().StringVar(&hashFilter, "hash", "", "bitcoin tx hash filter")
+	cmdGetTransactions.Flags().StringVar(&batchIDFilter, "batch-id", "", "only entries of batch withdrawal with given id")
+	cmdGetTransactions.Flags().StringVar(&parentIDFilter, "parent-id", "", "only Child-Pays-For-Parent txns accelerating deposit with given id")
+	cmdGetTransactions.Flags().StringVar(&coldStorageFilter, "cold-storage", "", "only withdrawals to cold storage (true) or only other txns (false)")
+	cmdGetTransactions.Flags().StringVar(&minAmountFilter, "min-amount", "", "minimal tx amount (inclusive)")
+	cmdGetTransactions.Flags().StringVar(&maxAmountFilter, "max-amount", "", "maximal tx amount (inclusive)")
+	cmdGetTransactions.Flags().StringVar(&createdAfterFilter, "created-after", "", "only txns created at or after this time (RFC 3339)")
+	cmdGetTransactions.Flags().StringVar(&createdBeforeFilter, "created-before", "", "only txns created before this time (RFC 3339)")
+	cmdGetTransactions.Flags().StringVar(&updatedAfterFilter, "updated-after", "", "only txns updated at or after this time (RFC 3339)")
+	cmdGetTransactions.Flags().StringVar(&updatedBeforeFilter, "updated-before", "", "only txns updated before this time (RFC 3339)")
+	cmdGetTransactions.Flags().StringVarP(&metainfoFilter, "metainfo", "m", "", "only txns which metainfo contains given JSON, e.g. '{\"user_id\": 42}'")
+	cmdGetTransactions.Flags().StringVarP(&order, "order", "o", string(wallet.SortAscending), "sort order by creation time: asc or desc")
+	cmdGetTransactions.Flags().IntVarP(&pageSize, "page-size", "l", wallet.DefaultPageSize, "number of transactions requested at once")
+	cmdGetTransactions.Flags().StringVarP(&cursor, "cursor", "c", "", "cursor of page to start from")
+	cmdGetTransactions.Flags().BoolVar(&singlePage, "single-page", false, "fetch only one page")
 
 	cli.AddCommand(cmdGetTransactions)
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet"
+)
+
+func init() {
+	var invoiceID string
+	var invoiceMetainfo string
+
+	var cmdCreateInvoice = &cobra.Command{
+		Use:     "create_invoice AMOUNT EXPIRES_AT",
+		Example: "create_invoice 0.05 2019-06-01T12:00:00Z -m '{\"order_id\": 42}'",
+		Short:   "Request payment of given amount to new address before given time (RFC 3339)",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var request wallet.CreateInvoiceRequest
+			var err error
+
+			request.Amount, err = bitcoin.BTCAmountFromStringedFloat(args[0])
+			if err != nil {
+				log.Fatalf(
+					"Failed to convert given amount value %q to bitcoin amount",
+					args[0])
+			}
+			request.ExpiresAt, err = time.Parse(time.RFC3339, args[1])
+			if err != nil {
+				log.Fatalf("Failed to parse expiration time %q as RFC 3339 time: %s", args[1], err)
+			}
+			if invoiceID != "" {
+				if request.ID, err = uuid.FromString(invoiceID); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if invoiceMetainfo != "" {
+				err = json.Unmarshal([]byte(invoiceMetainfo), &request.Metainfo)
+				if err != nil {
+					log.Fatalf("Checking that metainfo is a valid JSON object failed: %s", err)
+				}
+			}
+			showResponse(newClient().CreateInvoice(&request))
+		},
+	}
+	cmdCreateInvoice.Flags().StringVar(&invoiceID, "id", "", "id of invoice, generated by server if not set")
+	cmdCreateInvoice.Flags().StringVarP(&invoiceMetainfo, "metainfo", "m", "", "metainfo to attach to invoice and its address")
+	cli.AddCommand(cmdCreateInvoice)
+
+	cli.AddCommand(&cobra.Command{
+		Use:     "get_invoice ID",
+		Example: "get_invoice aec79cbf-79c4-46ef-a54f-63a0cf451fe2",
+		Short:   "Get invoice with given id",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := uuid.FromString(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			showResponse(newClient().GetInvoice(id))
+		},
+	})
+}
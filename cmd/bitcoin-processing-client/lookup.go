@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cli.AddCommand(&cobra.Command{
+		Use:     "get_transaction TX_ID",
+		Example: "get_transaction aec79cbf-79c4-46ef-a54f-63a0cf451fe2",
+		Short:   "Get transaction by id",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			txID, err := uuid.FromString(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			showResponse(newClient().GetTransaction(txID))
+		},
+	})
+	cli.AddCommand(&cobra.Command{
+		Use:   "get_transactions_by_hash HASH",
+		Short: "Get all transactions corresponding to bitcoin tx with given hash",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			showResponse(newClient().GetTransactionsByHash(args[0]))
+		},
+	})
+	cli.AddCommand(&cobra.Command{
+		Use:   "get_account ADDRESS",
+		Short: "Get account by address along with its metainfo",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			showResponse(newClient().GetAccount(args[0]))
+		},
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/onederx/bitcoin-processing/api/client"
 	"github.com/onederx/bitcoin-processing/settings"
 )
 
@@ -26,6 +27,16 @@ var cli = &cobra.Command{
 	},
 }
 
+// newClient creates API client using API URL and credentials given in command
+// line or in config file
+func newClient() *client.Client {
+	c, err := client.NewClientFromSettings(apiURL, serverSettings)
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+	return c
+}
+
 func main() {
 	cobra.OnInitialize(func() {
 		var err error
@@ -38,9 +49,11 @@ func main() {
 			)
 		}
 		serverSettings.GetViper().BindPFlag("api.http.address", cli.PersistentFlags().Lookup("api-url"))
+		client.BindFlags(cli.PersistentFlags(), serverSettings)
 	})
 
 	cli.PersistentFlags().StringVarP(&apiURLArg, "api-url", "u", "http://localhost:8000", "url of bitcoin-processing API")
+	client.AddFlags(cli.PersistentFlags())
 
 	if err := cli.Execute(); err != nil {
 		log.Println(err)
@@ -5,8 +5,6 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/spf13/cobra"
-
-	"github.com/onederx/bitcoin-processing/api/client"
 )
 
 func init() {
@@ -21,7 +19,7 @@ func init() {
 					log.Fatal(err)
 				}
 			}
-			err := client.NewClient(apiURL).MuteEventsForTxID(args[0])
+			err := newClient().MuteEventsForTxID(args[0])
 
 			if err != nil {
 				log.Fatal(err)
@@ -5,12 +5,11 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-
-	"github.com/onederx/bitcoin-processing/api/client"
 )
 
 func init() {
 	var newWalletMetainfoString string
+	var newWalletAddressType string
 
 	var cmdNewWallet = &cobra.Command{
 		Use:   "new_wallet",
@@ -29,11 +28,15 @@ func init() {
 					)
 				}
 			}
-			showResponse(client.NewClient(apiURL).NewWallet(newWalletMetainfo))
+			showResponse(newClient().NewWalletWithAddressType(
+				newWalletMetainfo,
+				newWalletAddressType,
+			))
 		},
 	}
 
 	cmdNewWallet.Flags().StringVarP(&newWalletMetainfoString, "metainfo", "m", "", "wallet metainfo")
+	cmdNewWallet.Flags().StringVarP(&newWalletAddressType, "address-type", "t", "", "address type: legacy, p2sh-segwit, bech32 or bech32m (default is set in processing config)")
 
 	cli.AddCommand(cmdNewWallet)
 }
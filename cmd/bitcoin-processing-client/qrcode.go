@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/onederx/bitcoin-processing/api"
+)
+
+func init() {
+	var params api.QRCodeParams
+	var output string
+
+	var cmdGetQRCode = &cobra.Command{
+		Use:     "get_qr_code ADDRESS_OR_INVOICE_ID",
+		Example: "get_qr_code aec79cbf-79c4-46ef-a54f-63a0cf451fe2 --format svg -o invoice.svg",
+		Short:   "Get QR code with payment URI of account or invoice",
+		Long: "Get image of QR code with BIP 21 payment URI of account with " +
+			"given address or of invoice with given id. Image is written to " +
+			"file given by --output or to stdout",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var image []byte
+			var err error
+
+			cli := newClient()
+			if id, parseErr := uuid.FromString(args[0]); parseErr == nil {
+				image, err = cli.GetInvoiceQRCode(id, &params)
+			} else {
+				image, err = cli.GetAccountQRCode(args[0], &params)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if output == "" {
+				os.Stdout.Write(image)
+				return
+			}
+			if err = ioutil.WriteFile(output, image, 0644); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmdGetQRCode.Flags().StringVar(&params.Format, "format", "", "image format: png (default) or svg")
+	cmdGetQRCode.Flags().IntVar(&params.Scale, "scale", 0, "size of QR code module in pixels (default 8)")
+	cmdGetQRCode.Flags().StringVarP(&output, "output", "o", "", "file to write image to")
+	cli.AddCommand(cmdGetQRCode)
+}
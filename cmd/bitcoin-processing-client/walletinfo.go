@@ -2,12 +2,10 @@ package main
 
 import (
 	"github.com/spf13/cobra"
-
-	"github.com/onederx/bitcoin-processing/api/client"
 )
 
 func runWalletInfoCommand(cmd *cobra.Command, args []string) {
-	cli := client.NewClient(apiURL)
+	cli := newClient()
 
 	switch cmd.Use {
 	case "get_hot_storage_address":
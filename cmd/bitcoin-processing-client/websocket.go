@@ -7,7 +7,6 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/onederx/bitcoin-processing/api/client"
 	"github.com/onederx/bitcoin-processing/events"
 	"github.com/onederx/bitcoin-processing/util"
 )
@@ -19,7 +18,7 @@ func init() {
 		Use:   "websocket",
 		Short: "Subscribe to events via websocket",
 		Run: func(cmd *cobra.Command, args []string) {
-			cli := client.NewClient(apiURL)
+			cli := newClient()
 			wsClient, err := cli.NewWebsocketClient(startSeq, func(message *events.NotificationWithSeq) {
 				util.MustPrettyPrint(message)
 			})
@@ -2,12 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"log"
+	"os"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/spf13/cobra"
 
-	"github.com/onederx/bitcoin-processing/api/client"
 	"github.com/onederx/bitcoin-processing/bitcoin"
 	"github.com/onederx/bitcoin-processing/wallet"
 )
@@ -15,7 +17,23 @@ import (
 func init() {
 	var withdrawID string
 	var withdrawFeeType string
+	var withdrawFeePayer string
+	var withdrawConfTarget int
+	var withdrawEstimateMode string
 	var withdrawMetainfoString string
+	var withdrawExecuteAfter string
+	var withdrawExpiresAt string
+
+	parseTime := func(name, value string) *time.Time {
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			log.Fatalf("Failed to parse %s %q as RFC 3339 time: %s", name, value, err)
+		}
+		return &t
+	}
 
 	makeWithdrawCommmandRunner := func(url string, toColdStorage bool) func(cmd *cobra.Command, args []string) {
 		return func(cmd *cobra.Command, args []string) {
@@ -50,11 +68,18 @@ func init() {
 			}
 
 			var requestData = wallet.WithdrawRequest{
-				ID:      withdrawIDParsed,
-				Address: address,
-				Amount:  amount,
-				Fee:     fee,
-				FeeType: withdrawFeeType,
+				ID:       withdrawIDParsed,
+				Address:  address,
+				Amount:   amount,
+				Fee:      fee,
+				FeeType:  withdrawFeeType,
+				FeePayer: withdrawFeePayer,
+
+				ConfTarget:   withdrawConfTarget,
+				EstimateMode: withdrawEstimateMode,
+
+				ExecuteAfter: parseTime("execute-after", withdrawExecuteAfter),
+				ExpiresAt:    parseTime("expires-at", withdrawExpiresAt),
 			}
 			if withdrawMetainfoString != "" {
 				err := json.Unmarshal(
@@ -70,7 +95,7 @@ func init() {
 				requestData.Metainfo = withdrawMetainfo
 			}
 
-			cli := client.NewClient(apiURL)
+			cli := newClient()
 
 			if toColdStorage {
 				showResponse(cli.WithdrawToColdStorage(&requestData))
@@ -99,7 +124,49 @@ func init() {
 	for _, cmd := range []*cobra.Command{cmdWithdraw, cmdWithdrawToColdStorage} {
 		cmd.Flags().StringVarP(&withdrawID, "id", "i", "", "id of withdraw transaction")
 		cmd.Flags().StringVarP(&withdrawFeeType, "fee-type", "t", "", "transaction fee type")
+		cmd.Flags().StringVar(&withdrawFeePayer, "fee-payer", "", "who pays transaction fee: recipient or sender")
+		cmd.Flags().IntVar(&withdrawConfTarget, "conf-target", 0, "confirmation target in blocks for 'smart' fee type")
+		cmd.Flags().StringVar(&withdrawEstimateMode, "estimate-mode", "", "estimate mode for 'smart' fee type: economical or conservative")
 		cmd.Flags().StringVarP(&withdrawMetainfoString, "metainfo", "m", "", "metainfo to attach to withdraw")
+		cmd.Flags().StringVar(&withdrawExecuteAfter, "execute-after", "", "don't send withdrawal before this time (RFC 3339)")
+		cmd.Flags().StringVar(&withdrawExpiresAt, "expires-at", "", "cancel withdrawal if it is still scheduled or pending at this time (RFC 3339)")
 		cli.AddCommand(cmd)
 	}
+
+	var batchID string
+
+	var cmdWithdrawBatch = &cobra.Command{
+		Use:     "withdraw_batch FILE",
+		Example: "withdraw_batch payouts.json",
+		Short:   "Make batch withdrawal paid by one bitcoin transaction",
+		Long: "Make batch withdrawal paid by one bitcoin transaction. FILE " +
+			"(or stdin if FILE is '-') should contain JSON list of entries " +
+			"like {\"id\": \"...\", \"address\": \"...\", \"amount\": " +
+			"\"0.1\", \"metainfo\": {...}}",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var entriesJSON []byte
+			var err error
+
+			if args[0] == "-" {
+				entriesJSON, err = ioutil.ReadAll(os.Stdin)
+			} else {
+				entriesJSON, err = ioutil.ReadFile(args[0])
+			}
+			if err != nil {
+				log.Fatalf("Failed to read batch entries: %s", err)
+			}
+
+			batchIDParsed, _ := uuid.FromString(batchID)
+			requestData := wallet.BatchWithdrawRequest{ID: batchIDParsed}
+			err = json.Unmarshal(entriesJSON, &requestData.Withdrawals)
+			if err != nil {
+				log.Fatalf("Failed to parse batch entries: %s", err)
+			}
+
+			showResponse(newClient().WithdrawBatch(&requestData))
+		},
+	}
+	cmdWithdrawBatch.Flags().StringVarP(&batchID, "id", "i", "", "id of batch")
+	cli.AddCommand(cmdWithdrawBatch)
 }
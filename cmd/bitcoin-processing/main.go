@@ -29,12 +29,7 @@ func main() {
 			eventBroker,
 			wallet.NewStorage(db),
 		)
-		apiServer := api.NewServer(
-			loadedSettings.GetString("api.http.address"),
-			bitcoinWallet,
-			eventBroker,
-			loadedSettings.GetBool("wallet.allow_withdrawal_without_id"),
-		)
+		apiServer := api.NewServer(loadedSettings, bitcoinWallet, eventBroker)
 
 		bitcoinWallet.Check()
 		eventBroker.Check()
@@ -48,6 +43,18 @@ func main() {
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
+		reloadSignals := make(chan os.Signal, 1)
+		signal.Notify(reloadSignals, syscall.SIGHUP)
+
+		go func() {
+			for range reloadSignals {
+				log.Printf("Received SIGHUP, reloading TLS certificates")
+				if err := apiServer.ReloadTLS(); err != nil {
+					log.Printf("Failed to reload TLS certificates: %v", err)
+				}
+			}
+		}()
+
 		go func() {
 			select {
 			case <-eventBrokerStopped:
@@ -55,6 +55,15 @@ func marshalFlattenedEvent(event *NotificationWithSeq) []byte {
 	return flatNotificationJSON
 }
 
+// IsSentToHTTPCallback tells whether events of given type are reported via
+// HTTP callback. NewAddressEvent is not reported because new addresses are
+// requested via HTTP API - so, caller already knows that address was
+// generated (and which address) from HTTP API response. Same is true for
+// account metainfo updates. All events are sent to websocket subscribers
+func IsSentToHTTPCallback(et EventType) bool {
+	return et != NewAddressEvent && et != AccountMetainfoUpdatedEvent
+}
+
 func (e *eventBroker) sendDataToHTTPCallback(event *NotificationWithSeq) error {
 	data := marshalFlattenedEvent(event)
 	resp, err := http.Post(
@@ -142,11 +151,7 @@ func (e *eventBroker) sendHTTPCallbackNotifications(isRetry bool) {
 		return
 	}
 	for _, event := range events {
-		if event.Type == NewAddressEvent {
-			// NewAddressEvent is not reported via HTTP callback because new
-			// addresses are requested via HTTP API - so, caller already knows
-			// that address was generated (and which address) from HTTP API
-			// response
+		if !IsSentToHTTPCallback(event.Type) {
 			continue
 		}
 		err = e.MakeTransactIfAvailable(func(currBroker *eventBroker) error {
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"reflect"
 
 	"github.com/gofrs/uuid"
 )
@@ -78,19 +79,110 @@ const (
 	// PendingTxCancelledEvent is emitted when pending tx is cancelled
 	PendingTxCancelledEvent
 
+	// WithdrawalApprovedEvent is emitted when withdrawal pending manual
+	// confirmation is approved by one of approvers. Withdrawal is sent when
+	// it collects required number of approvals
+	WithdrawalApprovedEvent
+
+	// AccountMetainfoUpdatedEvent is emitted when metainfo of account is
+	// changed via API. Like NewAddressEvent, it is not reported via HTTP
+	// callback because it is caused by HTTP API request
+	AccountMetainfoUpdatedEvent
+
+	// WithdrawalFeeBumpedEvent is emitted when bitcoin tx paying withdrawal
+	// is replaced by one with higher fee (this is also how dropped withdrawal
+	// is re-issued). Withdrawal gets new hash and fee
+	WithdrawalFeeBumpedEvent
+
+	// IncomingTxReorgedEvent is emitted when block including incoming tx is
+	// removed from blockchain by reorganization: tx either returned to
+	// mempool or was mined in another block. Event has current number of
+	// confirmations, which can be less than reported before
+	IncomingTxReorgedEvent
+
+	// OutgoingTxReorgedEvent is same as IncomingTxReorgedEvent for outgoing
+	// txns
+	OutgoingTxReorgedEvent
+
+	// IncomingTxConflictedEvent is emitted when incoming tx becomes
+	// conflicted (another tx spending the same inputs appeared, so tx may
+	// never be confirmed) or double-spent (conflicting tx was mined). Status
+	// of tx in event tells which of these happened
+	IncomingTxConflictedEvent
+
+	// OutgoingTxConflictedEvent is same as IncomingTxConflictedEvent for
+	// outgoing txns
+	OutgoingTxConflictedEvent
+
+	// WithdrawalDroppedEvent is emitted when bitcoin tx paying withdrawal
+	// is neither in mempool nor in blockchain and could not be broadcasted
+	// again. Withdrawal gets status "dropped"
+	WithdrawalDroppedEvent
+
+	// InvoicePartiallyPaidEvent is emitted when invoice receives some money,
+	// but less than expected amount. Event data is invoice, not tx
+	InvoicePartiallyPaidEvent
+
+	// InvoicePaidEvent is emitted when invoice receives exactly expected
+	// amount before it expires
+	InvoicePaidEvent
+
+	// InvoiceOverpaidEvent is emitted when invoice receives more than
+	// expected amount
+	InvoiceOverpaidEvent
+
+	// InvoiceExpiredEvent is emitted when invoice expires without receiving
+	// expected amount
+	InvoiceExpiredEvent
+
+	// InvoicePaidLateEvent is emitted when expired invoice receives the rest
+	// of expected amount
+	InvoicePaidLateEvent
+
+	// ColdStorageSweepEvent is emitted when excess of hot wallet balance is
+	// automatically sent to cold storage. Event data is sweep description
+	// with id and hash of cold storage withdrawal
+	ColdStorageSweepEvent
+
+	// RequiredFromColdStorageChangedEvent is emitted when amount of money
+	// required from cold storage to fund pending withdrawals changes,
+	// including when it becomes zero
+	RequiredFromColdStorageChangedEvent
+
+	// WithdrawalReplacementRevertedEvent is emitted when bitcoin tx replaced
+	// by fee bump gets into blockchain instead of its replacement. Withdrawal
+	// gets hash, fee and fee type of this tx back
+	WithdrawalReplacementRevertedEvent
+
 	// InvalidEvent is for convertion from other types when value of source type
 	// is invalid
 	InvalidEvent
 )
 
 var eventTypeToStringMap = map[EventType]string{
-	NewAddressEvent:           "new-address",
-	NewIncomingTxEvent:        "new-incoming-tx",
-	IncomingTxConfirmedEvent:  "incoming-tx-confirmed",
-	NewOutgoingTxEvent:        "new-outgoing-tx",
-	OutgoingTxConfirmedEvent:  "outgoing-tx-confirmed",
-	PendingStatusUpdatedEvent: "tx-pending-status-updated",
-	PendingTxCancelledEvent:   "pending-tx-cancelled",
+	NewAddressEvent:                     "new-address",
+	NewIncomingTxEvent:                  "new-incoming-tx",
+	IncomingTxConfirmedEvent:            "incoming-tx-confirmed",
+	NewOutgoingTxEvent:                  "new-outgoing-tx",
+	OutgoingTxConfirmedEvent:            "outgoing-tx-confirmed",
+	PendingStatusUpdatedEvent:           "tx-pending-status-updated",
+	PendingTxCancelledEvent:             "pending-tx-cancelled",
+	WithdrawalApprovedEvent:             "withdrawal-approved",
+	AccountMetainfoUpdatedEvent:         "account-metainfo-updated",
+	WithdrawalFeeBumpedEvent:            "withdrawal-fee-bumped",
+	IncomingTxReorgedEvent:              "incoming-tx-reorged",
+	OutgoingTxReorgedEvent:              "outgoing-tx-reorged",
+	IncomingTxConflictedEvent:           "incoming-tx-conflicted",
+	OutgoingTxConflictedEvent:           "outgoing-tx-conflicted",
+	WithdrawalDroppedEvent:              "withdrawal-dropped",
+	InvoicePartiallyPaidEvent:           "invoice-partially-paid",
+	InvoicePaidEvent:                    "invoice-paid",
+	InvoiceOverpaidEvent:                "invoice-overpaid",
+	InvoiceExpiredEvent:                 "invoice-expired",
+	InvoicePaidLateEvent:                "invoice-paid-late",
+	ColdStorageSweepEvent:               "cold-storage-sweep",
+	RequiredFromColdStorageChangedEvent: "required-from-cold-storage-changed",
+	WithdrawalReplacementRevertedEvent:  "withdrawal-replacement-reverted",
 }
 
 var stringToEventTypeMap = make(map[string]EventType)
@@ -144,6 +236,25 @@ func RegisterNotificationUnmarshaler(et EventType, unmarshaler func([]byte) (int
 	notificationUnmarshalers[et] = unmarshaler
 }
 
+// NotificationDataType returns type of data attached to events of given type.
+// It is learned from unmarshaler registered for this event type, nil is
+// returned if there is none
+func NotificationDataType(et EventType) reflect.Type {
+	unmarshaler, ok := notificationUnmarshalers[et]
+	if !ok {
+		return nil
+	}
+	data, err := unmarshaler([]byte("{}"))
+	if err != nil {
+		return nil
+	}
+	t := reflect.TypeOf(data)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
 type genericNodificationData struct {
 	eventType  EventType
 	resultData interface{}
@@ -13,7 +13,7 @@ require (
 	github.com/docker/go-connections v0.4.0 // indirect
 	github.com/docker/go-units v0.4.0 // indirect
 	github.com/gofrs/uuid v3.2.0+incompatible
-	github.com/gorilla/mux v1.7.3 // indirect
+	github.com/gorilla/mux v1.7.3
 	github.com/gorilla/websocket v1.4.0
 	github.com/jackc/pgx v3.5.0+incompatible
 	github.com/lib/pq v1.1.1
@@ -23,6 +23,7 @@ require (
 	github.com/prometheus/client_golang v0.9.3
 	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
 	github.com/spf13/cobra v0.0.5
+	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.4.0
 	gotest.tools v2.2.0+incompatible // indirect
 )
@@ -29,6 +29,8 @@ const (
 api:
   http:
     address: 0.0.0.0:8000
+  auth:
+    disabled: true
 storage:
   type: postgres
   dsn: >
@@ -79,7 +79,7 @@ func getStableBalanceOrFail(t *testing.T, env *testenv.TestEnvironment) bitcoin.
 }
 
 func getNewAddressForWithdrawOrFail(t *testing.T, env *testenv.TestEnvironment) string {
-	addressDecoded, err := env.Regtest["node-client"].NodeAPI.CreateNewAddress()
+	addressDecoded, err := env.Regtest["node-client"].NodeAPI.CreateNewAddress(bitcoin.InvalidAddressType)
 
 	if err != nil {
 		t.Helper()
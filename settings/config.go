@@ -20,12 +20,30 @@ func (s *settings) initConfig(cli *cobra.Command) {
 	s.viper.SetDefault("bitcoin.node.tls", false)
 	s.viper.SetDefault("bitcoin.poll_interval", 3000)
 	s.viper.SetDefault("transaction.max_confirmations", 6)
+	s.viper.SetDefault("transaction.reorg_safety_depth", 6)
+	s.viper.SetDefault("transaction.rebroadcast_after", 3600)
 	s.viper.SetDefault("wallet.min_withdraw", 0.000006)
 	s.viper.SetDefault("wallet.min_fee.per_kb", bitcoin.MinimalFeeRateBTC)
 	s.viper.SetDefault("wallet.min_fee.fixed", 0.000005)
+	s.viper.SetDefault("wallet.fee_payer", "recipient")
 	s.viper.SetDefault("wallet.min_withdraw_without_manual_confirmation", 0.0)
 	s.viper.SetDefault("transaction.callback.backoff", 100)
 	s.viper.SetDefault("wallet.allow_withdrawal_without_id", true)
+	s.viper.SetDefault("api.auth.disabled", false)
+	s.viper.SetDefault("api.auth.max_clock_skew", 300)
+	s.viper.SetDefault("api.auth.wallet_notify_from", []string{"127.0.0.0/8", "::1"})
+	s.viper.SetDefault("wallet.batching.window", 0)
+	s.viper.SetDefault("wallet.batching.max_size", 100)
+	s.viper.SetDefault("wallet.smart_fee.conf_target", 6)
+	s.viper.SetDefault("wallet.smart_fee.estimate_mode", "conservative")
+	s.viper.SetDefault("wallet.smart_fee.min_rate", bitcoin.MinimalFeeRateBTC)
+	s.viper.SetDefault("wallet.smart_fee.max_rate", 0.001)
+	s.viper.SetDefault("wallet.smart_fee.fallback_rate", 0.0002)
+	s.viper.SetDefault("wallet.cold_storage_sweep.high_water_mark", 0.0)
+	s.viper.SetDefault("wallet.cold_storage_sweep.target", 0.0)
+	s.viper.SetDefault("wallet.cold_storage_sweep.min_amount", 0.001)
+	s.viper.SetDefault("wallet.cold_storage_sweep.fee_type", "smart")
+	s.viper.SetDefault("wallet.cold_storage_sweep.fee", 0.0)
 }
 
 // GetString takes a string value from config. It simply calls viper.GetString.
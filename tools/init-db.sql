@@ -8,9 +8,26 @@ CREATE TABLE IF NOT EXISTS events (
 
 CREATE TABLE IF NOT EXISTS accounts (
     address TEXT PRIMARY KEY,
+    address_type TEXT NOT NULL DEFAULT '',
     metainfo JSONB
 );
 
+-- databases created by older versions lack columns added later, add them
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS address_type TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS accounts_metainfo_idx ON accounts USING GIN (metainfo jsonb_path_ops);
+
+CREATE TABLE IF NOT EXISTS account_metainfo_updates (
+    id SERIAL PRIMARY KEY,
+    address TEXT NOT NULL REFERENCES accounts (address),
+    old_metainfo JSONB,
+    metainfo JSONB,
+    updated_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS account_metainfo_updates_address_idx ON account_metainfo_updates (address);
+
 CREATE TABLE IF NOT EXISTS transactions (
     id uuid PRIMARY KEY,
     hash TEXT,
@@ -23,10 +40,68 @@ CREATE TABLE IF NOT EXISTS transactions (
     metainfo JSONB,
     fee BIGINT,
     fee_type TEXT,
+    fee_payer TEXT NOT NULL DEFAULT 'recipient',
+    conf_target INT NOT NULL DEFAULT 0,
+    estimate_mode TEXT NOT NULL DEFAULT '',
     cold_storage BOOLEAN,
-    reported_confirmations BIGINT
+    reported_confirmations BIGINT,
+    created_by TEXT NOT NULL DEFAULT '',
+    required_approvals INT NOT NULL DEFAULT 0,
+    approvals JSONB,
+    batch_id uuid,
+    replaced_hashes JSONB,
+    replaced_fees JSONB,
+    wallet_conflicts JSONB,
+    parent_id uuid,
+    execute_after TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
+-- databases created by older versions lack columns added later, add them
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS required_approvals INT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS approvals JSONB;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS batch_id uuid;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_payer TEXT NOT NULL DEFAULT 'recipient';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS replaced_hashes JSONB;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS parent_id uuid;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS wallet_conflicts JSONB;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS execute_after TIMESTAMPTZ;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS conf_target INT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS estimate_mode TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS replaced_fees JSONB;
+
+CREATE INDEX IF NOT EXISTS transactions_created_at_id_idx ON transactions (created_at, id);
+CREATE INDEX IF NOT EXISTS transactions_updated_at_idx ON transactions (updated_at);
+CREATE INDEX IF NOT EXISTS transactions_address_idx ON transactions (address);
+CREATE INDEX IF NOT EXISTS transactions_hash_idx ON transactions (hash);
+CREATE INDEX IF NOT EXISTS transactions_batch_id_idx ON transactions (batch_id);
+CREATE INDEX IF NOT EXISTS transactions_parent_id_idx ON transactions (parent_id);
+CREATE INDEX IF NOT EXISTS transactions_replaced_hashes_idx ON transactions USING GIN (replaced_hashes);
+CREATE INDEX IF NOT EXISTS transactions_amount_idx ON transactions (amount);
+CREATE INDEX IF NOT EXISTS transactions_status_direction_idx ON transactions (status, direction);
+CREATE INDEX IF NOT EXISTS transactions_metainfo_idx ON transactions USING GIN (metainfo jsonb_path_ops);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id uuid PRIMARY KEY,
+    address TEXT NOT NULL UNIQUE,
+    amount BIGINT NOT NULL,
+    received BIGINT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    metainfo JSONB,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS invoices_status_expires_at_idx ON invoices (status, expires_at);
+
 CREATE TABLE IF NOT EXISTS metadata (
     key TEXT PRIMARY KEY,
     value TEXT
@@ -197,8 +197,16 @@ func genBlocks(c *client.Client) {
 	}
 }
 
+func newClient() *client.Client {
+	c, err := client.NewClientFromSettings(apiURL, serverSettings)
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+	return c
+}
+
 func race() {
-	c := client.NewClient(apiURL)
+	c := newClient()
 
 	actions := []func(c *client.Client){
 		makeAndStoreWallet,
@@ -225,11 +233,13 @@ func main() {
 			)
 		}
 		serverSettings.GetViper().BindPFlag("api.http.address", cli.PersistentFlags().Lookup("api-url"))
+		client.BindFlags(cli.PersistentFlags(), serverSettings)
 	})
 
 	rand.Seed(time.Now().Unix())
 
 	cli.PersistentFlags().StringVarP(&apiURLArg, "api-url", "u", "http://localhost:8000", "url of bitcoin-processing API")
+	client.AddFlags(cli.PersistentFlags())
 
 	nCPU := runtime.NumCPU()
 
@@ -0,0 +1,211 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" encoded in version 1-M, from the standard tutorial example
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected error correction codewords %v, got %v", want, got)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	formats := []int{
+		0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0,
+	}
+	for mask, want := range formats {
+		if got := formatBits(mask); got != want {
+			t.Errorf("Expected format bits of mask %d to be %015b, got %015b", mask, want, got)
+		}
+	}
+	versions := map[int]int{7: 0x07C94, 8: 0x085BC, 40: 0x28C69}
+	for version, want := range versions {
+		if got := versionBits(version); got != want {
+			t.Errorf("Expected version bits of version %d to be %018b, got %018b", version, want, got)
+		}
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		length   int
+		wantSize int
+	}{
+		{14, 21},
+		{15, 25},
+		{2331, 177},
+	}
+	for _, test := range tests {
+		c, err := Encode(bytes.Repeat([]byte{'a'}, test.length))
+		if err != nil {
+			t.Errorf("Encoding %d bytes: unexpected error %v", test.length, err)
+			continue
+		}
+		if c.Size != test.wantSize {
+			t.Errorf("Expected %d bytes to be encoded as code of size %d, got %d",
+				test.length, test.wantSize, c.Size)
+		}
+	}
+	if _, err := Encode(make([]byte, 2332)); err != ErrDataTooLong {
+		t.Errorf("Expected too long data to be rejected, got %v", err)
+	}
+}
+
+// decode reads data back from code, checking format information and error
+// correction codewords on the way
+func decode(t *testing.T, c *Code) []byte {
+	var format int
+	for i := 14; i >= 9; i-- {
+		format = format<<1 | boolToInt(c.Dark(14-i, 8))
+	}
+	format = format<<1 | boolToInt(c.Dark(7, 8))
+	format = format<<1 | boolToInt(c.Dark(8, 8))
+	format = format<<1 | boolToInt(c.Dark(8, 7))
+	for i := 5; i >= 0; i-- {
+		format = format<<1 | boolToInt(c.Dark(8, i))
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("Invalid format bits %015b", format)
+	}
+
+	version := (c.Size - 17) / 4
+	reference := newCode(version)
+	reference.drawFunctionPatterns()
+	unmasked := newCode(version)
+	for y := 0; y < c.Size; y++ {
+		copy(unmasked.modules[y], c.modules[y])
+		copy(unmasked.isFunction[y], reference.isFunction[y])
+	}
+	unmasked.applyMask(mask)
+
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !reference.isFunction[y][x] {
+					bits = append(bits, unmasked.modules[y][x])
+				}
+			}
+		}
+	}
+	codewords := bits.bytes()
+
+	numBlocks := numErrorCorrectionBlocks[version]
+	eccLen := eccCodewordsPerBlock[version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortDataLen := rawCodewords/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i < shortDataLen+1; i++ {
+		for j := range blocks {
+			if i < shortDataLen || j >= numShortBlocks {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	divisor := reedSolomonDivisor(eccLen)
+	for j, block := range blocks {
+		var blockECC []byte
+		for i := 0; i < eccLen; i++ {
+			blockECC = append(blockECC, codewords[k+i*numBlocks+j])
+		}
+		if got := reedSolomonRemainder(block, divisor); !bytes.Equal(got, blockECC) {
+			t.Fatalf("Invalid error correction codewords in block %d", j)
+		}
+		data = append(data, block...)
+	}
+
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("Expected byte mode, got mode %x", data[0]>>4)
+	}
+	var length int
+	var payload bitBuffer
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			payload = append(payload, (b>>uint(i))&1 != 0)
+		}
+	}
+	payload = payload[4:]
+	for i := 0; i < charCountBits(version); i++ {
+		length = length<<1 | boolToInt(payload[i])
+	}
+	payload = payload[charCountBits(version):]
+	return payload[:8*length].bytes()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestEncodeDecode(t *testing.T) {
+	uri := "bitcoin:2N2wS8ZfiJXEAS5DCCEtKcHtB1EeXk7kgjV?amount=0.05&label=Order%2042"
+	for _, data := range []string{"", "HELLO WORLD", uri, strings.Repeat(uri, 20)} {
+		c, err := Encode([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(decode(t, c)); got != data {
+			t.Errorf("Expected to decode %q, got %q", data, got)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode([]byte("HELLO WORLD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pngData, err := c.PNG(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSize := (21 + 2*QuietZone) * 2
+	if bounds := img.Bounds(); bounds.Dx() != wantSize || bounds.Dy() != wantSize {
+		t.Errorf("Expected %dx%d image, got %v", wantSize, wantSize, bounds)
+	}
+	// top left module of finder pattern is dark, quiet zone is light
+	if r, _, _, _ := img.At(2*QuietZone, 2*QuietZone).RGBA(); r != 0 {
+		t.Errorf("Expected finder pattern to be dark")
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Errorf("Expected quiet zone to be light")
+	}
+
+	svg := string(c.SVG(2))
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `width="58"`) {
+		t.Errorf("Unexpected SVG %s", svg)
+	}
+}
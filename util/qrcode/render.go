@@ -0,0 +1,65 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QuietZone is a width of light border around the code in modules, required
+// by standard for code to be recognized reliably
+const QuietZone = 4
+
+// PNG renders code as PNG image where every module is a square of scale x
+// scale pixels
+func (c *Code) PNG(scale int) ([]byte, error) {
+	size := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(
+		image.Rect(0, 0, size, size),
+		color.Palette{color.White, color.Black},
+	)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if c.Dark(x/scale-QuietZone, y/scale-QuietZone) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders code as SVG image where every module is a square of scale x
+// scale pixels. Dark modules are drawn as a single path
+func (c *Code) SVG(scale int) []byte {
+	var path bytes.Buffer
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+
+	size := c.Size + 2*QuietZone
+	var buf bytes.Buffer
+	fmt.Fprintf(
+		&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" `+
+			`viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+
+			`<rect width="100%%" height="100%%" fill="#fff"/>`+
+			`<path d="%s" fill="#000"/></svg>`,
+		size,
+		size,
+		size*scale,
+		size*scale,
+		path.String(),
+	)
+	return buf.Bytes()
+}
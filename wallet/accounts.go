@@ -1,16 +1,62 @@
 package wallet
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"log"
+	"time"
 
+	"github.com/onederx/bitcoin-processing/bitcoin"
 	"github.com/onederx/bitcoin-processing/events"
 )
 
+var errNoAccountWithSuchAddress = errors.New("Account with such address is not in db")
+
 // Account describes user account. It consists of bitcoin address and metainfo
-// supplied when account was created
+// supplied when account was created. AddressType is a type of address, it is
+// empty for accounts created before address types were stored. URI is a BIP
+// 21 payment URI for the address, it is not stored and is filled when account
+// is returned to client
 type Account struct {
-	Address  string                 `json:"address"`
-	Metainfo map[string]interface{} `json:"metainfo"`
+	Address     string                 `json:"address"`
+	AddressType bitcoin.AddressType    `json:"address_type,omitempty"`
+	Metainfo    map[string]interface{} `json:"metainfo"`
+	URI         string                 `json:"uri,omitempty"`
+}
+
+// AccountMetainfoUpdate is a record of change of account metainfo. Such
+// records are stored for audit and sent as data of account-metainfo-updated
+// event. UpdatedBy is an id of API key used to make the change
+type AccountMetainfoUpdate struct {
+	Address     string                 `json:"address"`
+	OldMetainfo map[string]interface{} `json:"old_metainfo"`
+	Metainfo    map[string]interface{} `json:"metainfo"`
+	UpdatedBy   string                 `json:"updated_by,omitempty"`
+	Time        time.Time              `json:"time"`
+}
+
+// AccountsFilter describes which accounts should be fetched from storage by
+// GetAccountsWithFilter. Metainfo, if not nil, selects accounts which
+// metainfo contains it (see TransactionsFilter.Metainfo). Accounts are sorted
+// by address, After is an address of last account on previous page. Zero
+// Limit means no limit
+type AccountsFilter struct {
+	Metainfo interface{}
+	After    string
+	Limit    int
+}
+
+// matches tells whether account passes filter. It is used by in-memory
+// storage, Postgres storage implements same checks in SQL
+func (f *AccountsFilter) matches(account *Account) bool {
+	if f.After != "" && account.Address <= f.After {
+		return false
+	}
+	return f.Metainfo == nil || jsonContains(
+		normalizeJSON(account.Metainfo),
+		normalizeJSON(f.Metainfo),
+	)
 }
 
 func init() {
@@ -20,23 +66,69 @@ func init() {
 		err := json.Unmarshal(b, &account)
 		return &account, err
 	})
+	events.RegisterNotificationUnmarshaler(events.AccountMetainfoUpdatedEvent, func(b []byte) (interface{}, error) {
+		var update AccountMetainfoUpdate
+
+		err := json.Unmarshal(b, &update)
+		return &update, err
+	})
+}
+
+// paymentURI makes BIP 21 URI requesting payment of given amount (zero means
+// any amount) to address. Label and message are taken from "label" and
+// "message" fields of metainfo if they are strings
+func paymentURI(address string, amount bitcoin.BTCAmount, metainfo map[string]interface{}) string {
+	label, _ := metainfo["label"].(string)
+	message, _ := metainfo["message"].(string)
+	return bitcoin.PaymentURI(address, amount, label, message)
+}
+
+func (a *Account) setPaymentURI() {
+	a.URI = paymentURI(a.Address, 0, a.Metainfo)
+}
+
+func (w *Wallet) initDefaultAddressType() {
+	addressTypeStr := w.settings.GetString("wallet.address_type")
+	addressType, err := bitcoin.AddressTypeFromString(addressTypeStr)
+	if err != nil {
+		log.Fatalf("Invalid wallet.address_type %q in config: %v", addressTypeStr, err)
+	}
+	w.defaultAddressType = addressType
 }
 
-func (w *Wallet) generateNewAddress() (string, error) {
-	return w.nodeAPI.CreateNewAddress()
+// generateNewAddress generates new address of given type. If type is not set
+// (bitcoin.InvalidAddressType), "wallet.address_type" from config is used and
+// if it is not set either, type is chosen by Bitcoin node. Actual type of
+// generated address is returned along with it
+func (w *Wallet) generateNewAddress(addressType bitcoin.AddressType) (string, bitcoin.AddressType, error) {
+	if addressType == bitcoin.InvalidAddressType {
+		addressType = w.defaultAddressType
+	}
+	address, err := w.nodeAPI.CreateNewAddress(addressType)
+	if err != nil {
+		return "", bitcoin.InvalidAddressType, err
+	}
+	generatedType, err := bitcoin.AddressTypeOf(address)
+	if err != nil {
+		log.Printf("Warning: failed to get type of generated address: %v", err)
+	}
+	return address, generatedType, nil
 }
 
-// CreateAccount creates new Account: generates new bitcoin address and stores
-// it in DB along with given assosiated metainfo
-func (w *Wallet) CreateAccount(metainfo map[string]interface{}) (*Account, error) {
-	address, err := w.generateNewAddress()
+// CreateAccount creates new Account: generates new bitcoin address of given
+// type (see generateNewAddress) and stores it in DB along with given
+// assosiated metainfo
+func (w *Wallet) CreateAccount(metainfo map[string]interface{}, addressType bitcoin.AddressType) (*Account, error) {
+	address, addressType, err := w.generateNewAddress(addressType)
 	if err != nil {
 		return nil, err
 	}
 	account := &Account{
-		Address:  address,
-		Metainfo: metainfo,
+		Address:     address,
+		AddressType: addressType,
+		Metainfo:    metainfo,
 	}
+	account.setPaymentURI()
 
 	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
 		err := currWallet.storage.StoreAccount(account)
@@ -54,3 +146,91 @@ func (w *Wallet) CreateAccount(metainfo map[string]interface{}) (*Account, error
 
 	return account, nil
 }
+
+// GetAccount fetches Account with given address from storage. If there is no
+// such account, *Error with code ErrorCodeNotFound is returned
+func (w *Wallet) GetAccount(address string) (*Account, error) {
+	account, err := w.storage.GetAccountByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, newError(ErrorCodeNotFound, "Account with address %s not found", address)
+	}
+	account.setPaymentURI()
+	return account, nil
+}
+
+// GetAccounts fetches one page of accounts sorted by address, optionally
+// filtered by metainfo (see AccountsFilter). cursor is returned by previous
+// call, empty value means get first page. Besides accounts, cursor for next
+// page is returned, it is empty if there are no more accounts. If
+// filter.Limit is 0, DefaultPageSize is used
+func (w *Wallet) GetAccounts(filterParams AccountsFilter, cursor string) ([]*Account, string, error) {
+	filter := &filterParams
+
+	pageSize, err := checkPageSize(filter.Limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, "", newError(ErrorCodeInvalidRequest, "Invalid cursor %q", cursor)
+		}
+		filter.After = string(after)
+	}
+
+	// one more account is requested to find out if there is next page
+	filter.Limit = pageSize + 1
+	accounts, err := w.storage.GetAccountsWithFilter(filter)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, account := range accounts {
+		account.setPaymentURI()
+	}
+	if len(accounts) <= pageSize {
+		return accounts, "", nil
+	}
+	accounts = accounts[:pageSize]
+	nextCursor := base64.RawURLEncoding.EncodeToString(
+		[]byte(accounts[pageSize-1].Address),
+	)
+	return accounts, nextCursor, nil
+}
+
+// UpdateAccountMetainfo replaces metainfo of account with given address.
+// updatedBy is an id of API key that requested update. Update is recorded
+// in storage for audit and account-metainfo-updated event is emitted. Txns
+// that are already stored keep old metainfo, new incoming txns will get new
+// one
+func (w *Wallet) UpdateAccountMetainfo(address string, metainfo map[string]interface{}, updatedBy string) (*Account, error) {
+	update := &AccountMetainfoUpdate{
+		Address:   address,
+		Metainfo:  metainfo,
+		UpdatedBy: updatedBy,
+		Time:      time.Now().UTC(),
+	}
+
+	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		err := currWallet.storage.updateAccountMetainfo(update)
+		if err != nil {
+			return err
+		}
+		return currWallet.eventBroker.Notify(events.AccountMetainfoUpdatedEvent, update)
+	})
+
+	if err == errNoAccountWithSuchAddress {
+		return nil, newError(ErrorCodeNotFound, "Account with address %s not found", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w.eventBroker.SendNotifications()
+
+	account := &Account{Address: address, Metainfo: metainfo}
+	account.setPaymentURI()
+	return account, nil
+}
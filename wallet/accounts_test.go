@@ -6,9 +6,11 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/onederx/bitcoin-processing/bitcoin"
 	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
 	"github.com/onederx/bitcoin-processing/events"
 	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
 )
 
 const testAddress = "1MirQ9bwyQcGVJPwKUgapu5ouK2E2Ey4gX"
@@ -23,10 +25,12 @@ var testMetainfo = map[string]interface{}{
 
 type nodeAPICreateNewAddressMock struct {
 	nodeapi.NodeAPI
-	address string
+	address       string
+	requestedType bitcoin.AddressType
 }
 
-func (n *nodeAPICreateNewAddressMock) CreateNewAddress() (string, error) {
+func (n *nodeAPICreateNewAddressMock) CreateNewAddress(addressType bitcoin.AddressType) (string, error) {
+	n.requestedType = addressType
 	address := n.address
 	if address == "" {
 		address = testAddress
@@ -38,7 +42,7 @@ type nodeAPICreateNewAddressErrorMock struct {
 	nodeapi.NodeAPI
 }
 
-func (n *nodeAPICreateNewAddressErrorMock) CreateNewAddress() (string, error) {
+func (n *nodeAPICreateNewAddressErrorMock) CreateNewAddress(addressType bitcoin.AddressType) (string, error) {
 	return "", errors.New(createAddressFailedErrorMsg)
 }
 
@@ -74,7 +78,7 @@ func TestCreateAccountSuccessful(t *testing.T) {
 		NewStorage(nil),
 	)
 
-	account, err := w.CreateAccount(testMetainfo)
+	account, err := w.CreateAccount(testMetainfo, bitcoin.InvalidAddressType)
 	if err != nil {
 		t.Errorf("CreateAccount returned error %s", err)
 	}
@@ -99,7 +103,7 @@ func TestCreateAccountAddressGenerationFailure(t *testing.T) {
 		NewStorage(nil),
 	)
 
-	_, err := w.CreateAccount(testMetainfo)
+	_, err := w.CreateAccount(testMetainfo, bitcoin.InvalidAddressType)
 	if err == nil {
 		t.Errorf(
 			"CreateAccount did not return error in case of address " +
@@ -121,7 +125,7 @@ func TestCreateAccountStorageFailure(t *testing.T) {
 		&accountStoreFailureMock{},
 	)
 
-	_, err := w.CreateAccount(testMetainfo)
+	_, err := w.CreateAccount(testMetainfo, bitcoin.InvalidAddressType)
 	if err == nil {
 		t.Errorf(
 			"CreateAccount did not return error in case of storage failure")
@@ -130,3 +134,189 @@ func TestCreateAccountStorageFailure(t *testing.T) {
 		t.Errorf("Unexpected error message %s", got)
 	}
 }
+
+func TestGetAccount(t *testing.T) {
+	w := NewWallet(
+		&settingstestutil.SettingsMock{},
+		&nodeAPICreateNewAddressMock{},
+		&eventBrokerMock{},
+		NewStorage(nil),
+	)
+
+	if _, err := w.CreateAccount(testMetainfo, bitcoin.InvalidAddressType); err != nil {
+		t.Fatal(err)
+	}
+	account, err := w.GetAccount(testAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(account.Metainfo, testMetainfo) {
+		t.Errorf("GetAccount returned unexpected metainfo %v", account.Metainfo)
+	}
+
+	_, err = w.GetAccount("unknown")
+	if e, ok := err.(*Error); !ok || e.Code != ErrorCodeNotFound {
+		t.Errorf("Expected %s error for unknown address, got %v",
+			ErrorCodeNotFound, err)
+	}
+}
+
+func TestGetAccountsWithPagination(t *testing.T) {
+	w := &Wallet{storage: NewStorage(nil)}
+
+	for _, address := range []string{"addr3", "addr1", "addr4", "addr2"} {
+		err := w.storage.StoreAccount(&Account{
+			Address:  address,
+			Metainfo: map[string]interface{}{"vip": address != "addr2"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var addresses []string
+	cursor := ""
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatal("Pagination does not stop")
+		}
+		accounts, nextCursor, err := w.GetAccounts(AccountsFilter{
+			Metainfo: map[string]interface{}{"vip": true},
+			Limit:    2,
+		}, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, account := range accounts {
+			addresses = append(addresses, account.Address)
+		}
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+	if got, want := addresses, []string{"addr1", "addr3", "addr4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected accounts %v, got %v", want, got)
+	}
+}
+
+func TestUpdateAccountMetainfo(t *testing.T) {
+	e := &loggingEventBrokerMock{}
+	w := NewWallet(
+		&settingstestutil.SettingsMock{},
+		&nodeAPICreateNewAddressMock{},
+		e,
+		NewStorage(nil),
+	)
+	newMetainfo := map[string]interface{}{"user_id": 43}
+
+	if _, err := w.CreateAccount(testMetainfo, bitcoin.InvalidAddressType); err != nil {
+		t.Fatal(err)
+	}
+	e.flushEvents()
+
+	if _, err := w.UpdateAccountMetainfo(testAddress, newMetainfo, "admin-key"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(e.log), 1; got != want {
+		t.Fatalf("Expected %d events, got %d", want, got)
+	}
+	if got, want := e.log[0].Type, events.AccountMetainfoUpdatedEvent; got != want {
+		t.Errorf("Expected event %s, got %s", want, got)
+	}
+	update := e.log[0].Data.(*AccountMetainfoUpdate)
+	if !reflect.DeepEqual(update.OldMetainfo, testMetainfo) ||
+		!reflect.DeepEqual(update.Metainfo, newMetainfo) ||
+		update.UpdatedBy != "admin-key" {
+		t.Errorf("Unexpected metainfo update record %+v", update)
+	}
+
+	// new incoming txns get updated metainfo
+	metainfo, err := w.getAccountMetainfo(&types.Transaction{Address: testAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(metainfo, newMetainfo) {
+		t.Errorf("Expected new tx to get metainfo %v, got %v", newMetainfo, metainfo)
+	}
+
+	_, err = w.UpdateAccountMetainfo("unknown", newMetainfo, "")
+	if e, ok := err.(*Error); !ok || e.Code != ErrorCodeNotFound {
+		t.Errorf("Expected %s error for unknown address, got %v",
+			ErrorCodeNotFound, err)
+	}
+}
+
+func TestPaymentURI(t *testing.T) {
+	tests := []struct {
+		amount   string
+		metainfo map[string]interface{}
+		wantURI  string
+	}{
+		{"0", nil, "bitcoin:" + testAddress},
+		{"0", map[string]interface{}{"label": 42}, "bitcoin:" + testAddress},
+		{
+			"0.05",
+			map[string]interface{}{"label": "Order #42", "message": "Thanks & bye"},
+			"bitcoin:" + testAddress + "?amount=0.05&label=Order%20%2342&message=Thanks%20%26%20bye",
+		},
+	}
+	for _, test := range tests {
+		amount := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(test.amount))
+		if got := paymentURI(testAddress, amount, test.metainfo); got != test.wantURI {
+			t.Errorf("Expected payment URI %s, got %s", test.wantURI, got)
+		}
+	}
+
+	w := NewWallet(&settingstestutil.SettingsMock{}, &nodeAPICreateNewAddressMock{}, &loggingEventBrokerMock{}, NewStorage(nil))
+	account, err := w.CreateAccount(map[string]interface{}{"label": "Alice"}, bitcoin.InvalidAddressType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := account.URI, "bitcoin:"+testAddress+"?label=Alice"; got != want {
+		t.Errorf("Expected account URI %s, got %s", want, got)
+	}
+}
+
+func TestCreateAccountAddressType(t *testing.T) {
+	const bech32Address = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+
+	tests := []struct {
+		name          string
+		defaultType   string
+		requestedType bitcoin.AddressType
+		wantNodeType  bitcoin.AddressType
+	}{
+		{"no type", "", bitcoin.InvalidAddressType, bitcoin.InvalidAddressType},
+		{"type from config", "bech32", bitcoin.InvalidAddressType, bitcoin.Bech32Address},
+		{"type from request", "legacy", bitcoin.Bech32Address, bitcoin.Bech32Address},
+	}
+	for _, test := range tests {
+		n := &nodeAPICreateNewAddressMock{address: bech32Address}
+		s := &settingstestutil.SettingsMock{
+			Data: map[string]interface{}{"wallet.address_type": test.defaultType},
+		}
+		ws := NewStorage(nil)
+		w := NewWallet(s, n, &loggingEventBrokerMock{}, ws)
+		w.initDefaultAddressType()
+
+		account, err := w.CreateAccount(nil, test.requestedType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.requestedType != test.wantNodeType {
+			t.Errorf("%s: expected node to be asked for address type %s, got %s",
+				test.name, test.wantNodeType, n.requestedType)
+		}
+		stored, err := ws.GetAccountByAddress(bech32Address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, got := range []*Account{account, stored} {
+			if got.AddressType != bitcoin.Bech32Address {
+				t.Errorf("%s: expected account to have address type %s, got %s",
+					test.name, bitcoin.Bech32Address, got.AddressType)
+			}
+		}
+	}
+}
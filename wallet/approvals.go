@@ -0,0 +1,138 @@
+package wallet
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// ErrDuplicateApproval is returned when the same approver tries to confirm
+// withdrawal more than once
+var ErrDuplicateApproval = &Error{
+	Code:    ErrorCodeDuplicateApproval,
+	Message: "Withdrawal was already confirmed by this approver",
+}
+
+// ApprovalTier sets number of distinct approvals required to confirm
+// withdrawal which amount is not less than Amount
+type ApprovalTier struct {
+	Amount    bitcoin.BTCAmount
+	Approvals int
+}
+
+func (w *Wallet) initApprovalTiers() {
+	var tiers []struct {
+		Amount    string `mapstructure:"amount"`
+		Approvals int    `mapstructure:"approvals"`
+	}
+
+	err := w.settings.GetViper().UnmarshalKey("wallet.approval_tiers", &tiers)
+	if err != nil {
+		log.Fatalf("Failed to read wallet.approval_tiers from config: %v", err)
+	}
+
+	w.approvalTiers = make([]ApprovalTier, 0, len(tiers))
+	for _, tier := range tiers {
+		amount, err := bitcoin.BTCAmountFromStringedFloat(tier.Amount)
+		if err != nil {
+			log.Fatalf("Error converting amount %q of approval tier to "+
+				"bitcoin amount: %v", tier.Amount, err)
+		}
+		if tier.Approvals < 1 {
+			log.Fatalf("Error: approval tier for amount %s should require "+
+				"at least 1 approval", amount)
+		}
+		w.approvalTiers = append(w.approvalTiers, ApprovalTier{
+			Amount:    amount,
+			Approvals: tier.Approvals,
+		})
+	}
+	sort.Slice(w.approvalTiers, func(i, j int) bool {
+		return w.approvalTiers[i].Amount < w.approvalTiers[j].Amount
+	})
+}
+
+// tierApprovals returns number of approvals required by approval tiers for
+// withdrawal of given amount or 0 if no tier applies to it
+func (w *Wallet) tierApprovals(amount bitcoin.BTCAmount) int {
+	approvals := 0
+	for _, tier := range w.approvalTiers {
+		if amount >= tier.Amount && tier.Approvals > approvals {
+			approvals = tier.Approvals
+		}
+	}
+	return approvals
+}
+
+// requiredApprovals returns number of approvals withdrawal of given amount
+// needs to be sent if it is held for manual confirmation
+func (w *Wallet) requiredApprovals(amount bitcoin.BTCAmount) int {
+	if approvals := w.tierApprovals(amount); approvals > 0 {
+		return approvals
+	}
+	return 1
+}
+
+func hasApprovalFrom(tx *types.Transaction, approver string) bool {
+	for _, approval := range tx.Approvals {
+		if approval.Approver == approver {
+			return true
+		}
+	}
+	return false
+}
+
+// addApproval records approval of withdrawal by given approver and emits
+// WithdrawalApprovedEvent. Withdrawal is a single tx or all entries of a batch
+// withdrawal, which are approved together. It returns true if withdrawal has
+// now collected required number of approvals and should be sent. Anonymous
+// approvals (made when API authentication is disabled) all come from the same
+// empty approver, so withdrawal can get only one of them
+func (w *Wallet) addApproval(txns []*types.Transaction, approver string) (bool, error) {
+	tx := txns[0]
+	if hasApprovalFrom(tx, approver) {
+		return false, ErrDuplicateApproval
+	}
+
+	approval := types.Approval{
+		Approver: approver,
+		Time:     time.Now().UTC(),
+	}
+
+	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		for _, tx := range txns {
+			approvals := append(tx.Approvals[:len(tx.Approvals):len(tx.Approvals)], approval)
+			err := currWallet.storage.updateApprovals(tx, approvals)
+			if err != nil {
+				return err
+			}
+			err = currWallet.NotifyTransaction(events.WithdrawalApprovedEvent, *tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	requiredApprovals := tx.RequiredApprovals
+	if requiredApprovals < 1 {
+		// txns held before approval tiers were introduced need one approval
+		requiredApprovals = 1
+	}
+
+	log.Printf(
+		"Tx %s approved by %q, it now has %d of %d required approvals",
+		tx.ID,
+		approver,
+		len(tx.Approvals),
+		requiredApprovals,
+	)
+	return len(tx.Approvals) >= requiredApprovals, nil
+}
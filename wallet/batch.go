@@ -0,0 +1,299 @@
+package wallet
+
+import (
+	"log"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// BatchWithdrawEntry is a single payment of batch withdrawal. ID is required
+// (api package generates it if client did not set it and withdrawals without
+// id are allowed), Metainfo is optional. AddressType is not sent by client:
+// it is set to type of destination address (see WithdrawRequest)
+type BatchWithdrawEntry struct {
+	ID          uuid.UUID           `json:"id,omitempty"`
+	Address     string              `json:"address"`
+	AddressType bitcoin.AddressType `json:"address_type,omitempty"`
+	Amount      bitcoin.BTCAmount   `json:"amount"`
+	Metainfo    interface{}         `json:"metainfo"`
+}
+
+// BatchWithdrawRequest is a structure with parameters of batch withdrawal:
+// many payments sent by one Bitcoin transaction, which is much cheaper than
+// sending them one by one. Each entry becomes a separate outgoing tx with its
+// own id, events and metainfo. All of them get BatchID equal to ID of this
+// request (it is generated if not set) and, once sent, the same hash.
+// Fee of batch tx is paid by the wallet, so recipients get exactly the amounts
+// set in entries. Its rate is estimated by Bitcoin node with default
+// parameters of 'smart' fee type and, like any smart fee rate, must not be
+// less than "wallet.min_fee.per_kb". Entries are stored with fee type 'smart',
+// estimated rate as fee and fee payer 'sender'.
+// CreatedBy is not sent by client: it is set by API server to id of API key
+// that requested withdrawal
+type BatchWithdrawRequest struct {
+	ID          uuid.UUID             `json:"id,omitempty"`
+	Withdrawals []*BatchWithdrawEntry `json:"withdrawals"`
+	CreatedBy   string                `json:"-"`
+}
+
+type internalBatchWithdrawRequest struct {
+	txns   []*types.Transaction
+	hold   bool
+	result chan error
+}
+
+// withdrawalTransactions returns txns that are processed together with given
+// one: all entries of its batch if tx is a part of batch withdrawal or just tx
+// itself otherwise
+func (w *Wallet) withdrawalTransactions(tx *types.Transaction) ([]*types.Transaction, error) {
+	if tx.BatchID == nil {
+		return []*types.Transaction{tx}, nil
+	}
+	return w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		BatchID: *tx.BatchID,
+	})
+}
+
+func (w *Wallet) ensureBatchIDIsFree(id uuid.UUID) error {
+	txns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		BatchID: id,
+		Limit:   1,
+	})
+	if err != nil {
+		return err
+	}
+	if len(txns) > 0 {
+		return newError(ErrorCodeDuplicateID, "Batch with id %s already exists", id)
+	}
+	return nil
+}
+
+func (w *Wallet) sendBatchWithdrawal(txns []*types.Transaction, updatePending bool) error {
+	amounts := make(map[string]bitcoin.BTCAmount)
+
+	w.reestimateSmartFee(txns)
+	for _, tx := range txns {
+		amounts[tx.Address] = tx.Amount
+	}
+
+	return w.broadcastWithdrawal(
+		txns,
+		map[string]interface{}{
+			"operation": "batch-withdraw",
+			"txns":      txns,
+		},
+		func() (string, error) {
+			// all entries of batch store the same rate
+			return w.nodeAPI.SendManyWithPerKBFee(amounts, txns[0].Fee, false)
+		},
+		updatePending,
+	)
+}
+
+func (w *Wallet) holdBatchUntilConfirmed(txns []*types.Transaction, requiredApprovals int) error {
+	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		for _, tx := range txns {
+			tx.RequiredApprovals = requiredApprovals
+			err := currWallet.updatePendingTxStatus(
+				tx,
+				types.PendingManualConfirmationTransaction,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	w.txnsWaitingManualConfirmationCount.Add(float64(len(txns)))
+	w.eventBroker.SendNotifications()
+	return nil
+}
+
+func (w *Wallet) withdrawBatch(txns []*types.Transaction, hold bool) error {
+	batchID := *txns[0].BatchID
+
+	if err := w.ensureBatchIDIsFree(batchID); err != nil {
+		log.Printf(
+			"wallet: duplicate batch id %s, refusing to withdraw", batchID,
+		)
+		return err
+	}
+
+	var total bitcoin.BTCAmount
+
+	for _, tx := range txns {
+		if err := w.ensureTxIDIsFree(tx.ID); err != nil {
+			log.Printf(
+				"wallet: duplicate tx id %s, refusing to withdraw", tx.ID,
+			)
+			return err
+		}
+		ourAccount, err := w.storage.GetAccountByAddress(tx.Address)
+		if err != nil {
+			return err
+		}
+		if ourAccount != nil {
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Batch withdrawal can't pay to address %s of this wallet, "+
+					"use regular withdrawal for internal transfers",
+				tx.Address,
+			)
+		}
+		total += tx.Amount
+	}
+
+	if hold {
+		requiredApprovals := w.requiredApprovals(total)
+		log.Printf(
+			"Batch withdrawal %s has total amount %s which requires manual "+
+				"confirmation. Holding it until confirmed by %d approver(s).",
+			batchID,
+			total,
+			requiredApprovals,
+		)
+		return w.holdBatchUntilConfirmed(txns, requiredApprovals)
+	}
+	return w.sendBatchWithdrawal(txns, true)
+}
+
+// WithdrawBatch makes a batch withdrawal: all entries of request are paid by
+// one Bitcoin transaction. Request is checked as a whole: it is rejected if
+// any entry is invalid. Each entry is checked like a regular withdrawal (see
+// Withdraw), additionally, addresses in batch must be distinct and can't
+// belong to this wallet.
+// Manual confirmation and pending state apply to batch as a whole: batch
+// is held for manual confirmation if its total amount requires it (and then
+// needs approvals required for total amount), confirming or cancelling any
+// entry confirms or cancels all of them, and if there is not enough money to
+// send batch, all entries become pending and are sent together later.
+// Actual withdrawal is performed in a wallet updater goroutine
+func (w *Wallet) WithdrawBatch(request *BatchWithdrawRequest) error {
+	log.Printf(
+		"Got batch withdraw request with id %s and %d entries",
+		request.ID,
+		len(request.Withdrawals),
+	)
+
+	if len(request.Withdrawals) == 0 {
+		return newError(ErrorCodeInvalidRequest, "Batch withdrawal is empty")
+	}
+
+	if request.ID == uuid.Nil {
+		request.ID = uuid.Must(uuid.NewV4())
+		log.Printf("Generated new batch id %s", request.ID)
+	}
+
+	var total bitcoin.BTCAmount
+	txns := make([]*types.Transaction, 0, len(request.Withdrawals))
+	addresses := make(map[string]bool)
+	ids := make(map[uuid.UUID]bool)
+
+	for _, entry := range request.Withdrawals {
+		switch {
+		case entry.ID == uuid.Nil:
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Can't process batch withdraw: entry to %s has no id",
+				entry.Address,
+			)
+		case ids[entry.ID]:
+			return newError(
+				ErrorCodeDuplicateID,
+				"Tx id %s is used more than once in batch", entry.ID,
+			)
+		case entry.Address == "":
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Can't process batch withdraw: address of entry %s is empty",
+				entry.ID,
+			)
+		case addresses[entry.Address]:
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Address %s is used more than once in batch", entry.Address,
+			)
+		case entry.Address == w.hotWalletAddress:
+			return newError(
+				ErrorCodeHotWalletAddress,
+				"Refusing to withdraw to hot wallet address",
+			)
+		case entry.Amount < w.minWithdraw:
+			return newError(
+				ErrorCodeAmountBelowMinimum,
+				"Error: refusing to withdraw %s in entry %s because it is "+
+					"less than min withdraw amount %s",
+				entry.Amount,
+				entry.ID,
+				w.minWithdraw,
+			)
+		}
+		addressType, err := bitcoin.AddressTypeOf(entry.Address)
+		if err != nil {
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Can't process batch withdraw: entry %s: %v",
+				entry.ID,
+				err,
+			)
+		}
+		entry.AddressType = addressType
+		ids[entry.ID] = true
+		addresses[entry.Address] = true
+		total += entry.Amount
+
+		txns = append(txns, &types.Transaction{
+			ID:                    entry.ID,
+			Address:               entry.Address,
+			Direction:             types.OutgoingDirection,
+			Amount:                entry.Amount,
+			Metainfo:              entry.Metainfo,
+			FeeType:               bitcoin.SmartFee,
+			FeePayer:              bitcoin.SenderPaysFee,
+			BatchID:               &request.ID,
+			Fresh:                 true,
+			ReportedConfirmations: -1,
+			CreatedBy:             request.CreatedBy,
+		})
+	}
+
+	// batch is paid by sender with fee rate estimated by Bitcoin node. Rate
+	// is stored with entries, so that cost of pending batch includes fee
+	feeRate, err := w.smartFeeRate(0, "")
+	if err != nil {
+		return err
+	}
+	if feeRate < w.minFeePerKb {
+		return newError(
+			ErrorCodeFeeBelowMinimum,
+			"Error: refusing to make batch withdrawal with estimated fee "+
+				"rate %s because it is less than min withdraw fee %s for "+
+				"fee type %s",
+			feeRate,
+			w.minFeePerKb,
+			bitcoin.SmartFee,
+		)
+	}
+	for _, tx := range txns {
+		tx.Fee = feeRate
+	}
+
+	hold := total > w.minWithdrawWithoutManualConfirmation ||
+		w.tierApprovals(total) > 0
+
+	// to prevent races, actual withdraw will be done in wallet updater
+	// goroutine
+	resultCh := make(chan error)
+	w.batchWithdrawQueue <- internalBatchWithdrawRequest{
+		txns:   txns,
+		hold:   hold,
+		result: resultCh,
+	}
+	return <-resultCh
+}
@@ -0,0 +1,334 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const testBatchTxHash = "5c1ca8f24b2bd7a1bfb7c2e6ba5b8c64e2ed6b3e1dca5e0e2b4b8e9f1e7a6c3d"
+
+var testBatchFeeRate = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.001"))
+
+type nodeAPISendManyMock struct {
+	nodeAPIBalanceAndAddressMock
+
+	balance uint64
+	sent    []map[string]bitcoin.BTCAmount
+	fees    []bitcoin.BTCAmount
+}
+
+func (n *nodeAPISendManyMock) EstimateSmartFee(confTarget int, estimateMode string) (*nodeapi.SmartFeeEstimate, error) {
+	// no estimate, so fallback rate is used
+	return nil, nil
+}
+
+func (n *nodeAPISendManyMock) SendManyWithPerKBFee(addresses map[string]bitcoin.BTCAmount, fee bitcoin.BTCAmount, recipientsPayFee bool) (string, error) {
+	if recipientsPayFee {
+		panic("Expected sender to pay fee of batch")
+	}
+	hash, err := n.SendToMultipleAddresses(addresses)
+	if err == nil {
+		n.fees = append(n.fees, fee)
+	}
+	return hash, err
+}
+
+func (n *nodeAPISendManyMock) GetConfirmedAndUnconfirmedBalance() (uint64, uint64, error) {
+	return n.balance, 0, nil
+}
+
+func (n *nodeAPISendManyMock) SendToMultipleAddresses(addresses map[string]bitcoin.BTCAmount) (string, error) {
+	var total bitcoin.BTCAmount
+	for _, amount := range addresses {
+		total += amount
+	}
+	if uint64(total) > n.balance {
+		return "", &nodeapi.JSONRPCError{Code: -6, Message: "Insufficient funds"}
+	}
+	n.sent = append(n.sent, addresses)
+	return testBatchTxHash, nil
+}
+
+func newBatchTestWallet(n nodeapi.NodeAPI) (*Wallet, *loggingEventBrokerMock) {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations":                   1,
+			"wallet.min_withdraw":                             bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.001")),
+			"wallet.min_withdraw_without_manual_confirmation": bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")),
+			"wallet.smart_fee.conf_target":                    6,
+			"wallet.smart_fee.estimate_mode":                  EstimateModeConservative,
+			"wallet.smart_fee.fallback_rate":                  testBatchFeeRate,
+		},
+	}
+	e := &loggingEventBrokerMock{}
+	w := NewWallet(s, n, e, NewStorage(nil))
+
+	// serve batch withdraw queue like wallet updater goroutine does
+	go func() {
+		for request := range w.batchWithdrawQueue {
+			request.result <- w.withdrawBatch(request.txns, request.hold)
+			close(request.result)
+		}
+	}()
+	return w, e
+}
+
+func newTestBatch(amounts ...string) *BatchWithdrawRequest {
+	addresses := []string{
+		"mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB",
+		"n1ZCYg9YXtB5XCZazLxSmPDa8iwJRZHhGx",
+		"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn",
+	}
+	request := &BatchWithdrawRequest{ID: uuid.Must(uuid.NewV4())}
+	for i, amount := range amounts {
+		request.Withdrawals = append(request.Withdrawals, &BatchWithdrawEntry{
+			ID:       uuid.Must(uuid.NewV4()),
+			Address:  addresses[i],
+			Amount:   bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(amount)),
+			Metainfo: map[string]interface{}{"entry": i},
+		})
+	}
+	return request
+}
+
+func assertBatchStatus(t *testing.T, w *Wallet, request *BatchWithdrawRequest, status types.TransactionStatus, hash string) {
+	txns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		BatchID: request.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(txns), len(request.Withdrawals); got != want {
+		t.Fatalf("Expected batch to have %d txns, got %d", want, got)
+	}
+	for _, tx := range txns {
+		if tx.Status != status {
+			t.Errorf("Expected batch tx %s to have status %s, got %s",
+				tx.ID, status, tx.Status)
+		}
+		if tx.Hash != hash {
+			t.Errorf("Expected batch tx %s to have hash %q, got %q",
+				tx.ID, hash, tx.Hash)
+		}
+	}
+}
+
+func TestWithdrawBatch(t *testing.T) {
+	n := &nodeAPISendManyMock{balance: uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")))}
+	w, e := newBatchTestWallet(n)
+	request := newTestBatch("0.1", "0.2")
+
+	if err := w.WithdrawBatch(request); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.sent), 1; got != want {
+		t.Fatalf("Expected batch to be sent by %d bitcoin tx, got %d", want, got)
+	}
+	for _, entry := range request.Withdrawals {
+		if got := n.sent[0][entry.Address]; got != entry.Amount {
+			t.Errorf("Expected %s to be sent to %s, got %s",
+				entry.Amount, entry.Address, got)
+		}
+	}
+	if got, want := n.fees[0], testBatchFeeRate; got != want {
+		t.Errorf("Expected batch to be sent with fee rate %s, got %s", want, got)
+	}
+	assertBatchStatus(t, w, request, types.NewTransaction, testBatchTxHash)
+
+	var expectedEvents []*events.Notification
+	for _, entry := range request.Withdrawals {
+		expectedEvents = append(expectedEvents, &events.Notification{
+			Type: events.NewOutgoingTxEvent,
+			Data: types.TxNotification{
+				Transaction: types.Transaction{
+					ID:        entry.ID,
+					Address:   entry.Address,
+					Amount:    entry.Amount,
+					Direction: types.OutgoingDirection,
+					Status:    types.NewTransaction,
+					Fee:       testBatchFeeRate,
+					FeeType:   bitcoin.SmartFee,
+				},
+			},
+		})
+	}
+	e.assertExpectedEvents(t, expectedEvents)
+
+	if err := w.WithdrawBatch(request); err == nil {
+		t.Errorf("Expected batch with duplicate id to be rejected")
+	}
+}
+
+func TestWithdrawBatchPendingAsWhole(t *testing.T) {
+	n := &nodeAPISendManyMock{}
+	w, _ := newBatchTestWallet(n)
+	request := newTestBatch("0.1", "0.2", "0.3")
+
+	if err := w.WithdrawBatch(request); err != nil {
+		t.Fatal(err)
+	}
+	assertBatchStatus(t, w, request, types.PendingTransaction, "")
+
+	// balance is enough for any single entry, but not for the whole batch
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.5")))
+	if err := w.tryToUpdatePendingTxns(); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.sent) != 0 {
+		t.Fatalf("Expected underfunded batch not to be sent, sent %v", n.sent)
+	}
+	assertBatchStatus(t, w, request, types.PendingColdStorageTransaction, "")
+	required, err := w.storage.GetMoneyRequiredFromColdStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 entries of 0.00018 (180 bytes at 0.001 per KB) for fee
+	if got, want := bitcoin.BTCAmount(required), bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.10054")); got != want {
+		t.Errorf("Expected %s to be required from cold storage, got %s",
+			want, got)
+	}
+
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.7")))
+	if err := w.tryToUpdatePendingTxns(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.sent), 1; got != want {
+		t.Fatalf("Expected batch to be sent by %d bitcoin tx, got %d", want, got)
+	}
+	assertBatchStatus(t, w, request, types.NewTransaction, testBatchTxHash)
+}
+
+func TestWithdrawBatchPendingCostIncludesFee(t *testing.T) {
+	n := &nodeAPISendManyMock{}
+	w, _ := newBatchTestWallet(n)
+	request := newTestBatch("0.1", "0.2")
+
+	if err := w.WithdrawBatch(request); err != nil {
+		t.Fatal(err)
+	}
+	assertBatchStatus(t, w, request, types.PendingTransaction, "")
+
+	// balance covers amounts, but not fee of batch tx
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.3")))
+	if err := w.tryToUpdatePendingTxns(); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.sent) != 0 {
+		t.Fatalf("Expected batch without money for fee not to be sent, sent %v", n.sent)
+	}
+	assertBatchStatus(t, w, request, types.PendingColdStorageTransaction, "")
+	required, err := w.storage.GetMoneyRequiredFromColdStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bitcoin.BTCAmount(required), bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.00036")); got != want {
+		t.Errorf("Expected %s to be required from cold storage, got %s",
+			want, got)
+	}
+}
+
+func TestWithdrawBatchManualConfirmation(t *testing.T) {
+	n := &nodeAPISendManyMock{balance: uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("10")))}
+	w, _ := newBatchTestWallet(n)
+	// every entry is small, but total amount requires manual confirmation
+	request := newTestBatch("0.5", "0.5", "0.5")
+	request.CreatedBy = "requester"
+
+	if err := w.WithdrawBatch(request); err != nil {
+		t.Fatal(err)
+	}
+	assertBatchStatus(t, w, request, types.PendingManualConfirmationTransaction, "")
+
+	if err := w.confirmPendingTx(request.Withdrawals[0].ID, "requester"); err != ErrSelfApproval {
+		t.Errorf("Expected self-approval to fail with %v, got %v",
+			ErrSelfApproval, err)
+	}
+	if err := w.confirmPendingTx(request.Withdrawals[1].ID, "approver"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.sent), 1; got != want {
+		t.Fatalf("Expected batch to be sent by %d bitcoin tx, got %d", want, got)
+	}
+	assertBatchStatus(t, w, request, types.NewTransaction, testBatchTxHash)
+
+	cancelled := newTestBatch("0.5", "0.6")
+	if err := w.WithdrawBatch(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.cancelPendingTx(cancelled.Withdrawals[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	assertBatchStatus(t, w, cancelled, types.CancelledTransaction, "")
+}
+
+func TestWithdrawBatchInvalid(t *testing.T) {
+	n := &nodeAPISendManyMock{balance: uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("10")))}
+	w, _ := newBatchTestWallet(n)
+	acct, err := w.CreateAccount(nil, bitcoin.InvalidAddressType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	duplicateAddress := newTestBatch("0.1", "0.1")
+	duplicateAddress.Withdrawals[1].Address = duplicateAddress.Withdrawals[0].Address
+	duplicateID := newTestBatch("0.1", "0.1")
+	duplicateID.Withdrawals[1].ID = duplicateID.Withdrawals[0].ID
+	noID := newTestBatch("0.1")
+	noID.Withdrawals[0].ID = uuid.Nil
+	internal := newTestBatch("0.1", "0.1")
+	internal.Withdrawals[1].Address = acct.Address
+
+	tests := []struct {
+		name     string
+		request  *BatchWithdrawRequest
+		wantCode ErrorCode
+	}{
+		{"empty batch", &BatchWithdrawRequest{}, ErrorCodeInvalidRequest},
+		{"small amount", newTestBatch("0.1", "0.0001"), ErrorCodeAmountBelowMinimum},
+		{"duplicate address", duplicateAddress, ErrorCodeInvalidRequest},
+		{"duplicate id", duplicateID, ErrorCodeDuplicateID},
+		{"entry without id", noID, ErrorCodeInvalidRequest},
+		{"address of this wallet", internal, ErrorCodeInvalidRequest},
+	}
+	for _, test := range tests {
+		err := w.WithdrawBatch(test.request)
+		walletErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Batch withdraw with %s: expected *Error, got %v", test.name, err)
+			continue
+		}
+		if walletErr.Code != test.wantCode {
+			t.Errorf("Batch withdraw with %s: expected error code %s, got %s",
+				test.name, test.wantCode, walletErr.Code)
+		}
+	}
+	if len(n.sent) != 0 {
+		t.Errorf("Expected invalid batches not to be sent, sent %v", n.sent)
+	}
+}
+
+func TestWithdrawBatchFeeBelowMinimum(t *testing.T) {
+	n := &nodeAPISendManyMock{balance: uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("10")))}
+	w, _ := newBatchTestWallet(n)
+	w.minFeePerKb = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.002"))
+
+	err := w.WithdrawBatch(newTestBatch("0.1", "0.1"))
+	walletErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if walletErr.Code != ErrorCodeFeeBelowMinimum {
+		t.Errorf("Expected error code %s, got %s", ErrorCodeFeeBelowMinimum,
+			walletErr.Code)
+	}
+	if len(n.sent) != 0 {
+		t.Errorf("Expected batch not to be sent, sent %v", n.sent)
+	}
+}
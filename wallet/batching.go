@@ -0,0 +1,248 @@
+package wallet
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// Automatic batching: if "wallet.batching.window" is set in config, regular
+// withdrawals are not sent immediately, but get status 'queued' and are
+// collected for given number of milliseconds (or until there are
+// "wallet.batching.max_size" of them). Then they are sent together in one
+// Bitcoin transaction, with each recipient paying an equal share of its fee
+// (withdrawals which fee is paid by sender are batched separately).
+// Pending withdrawals that become fundable are also sent together.
+// Only withdrawals with 'per-kb-rate' or 'smart' fee type are batched because
+// Bitcoin node can't apply fixed fee to tx with several recipients.
+// Withdrawals to cold storage and internal transfers are not batched either.
+
+const batchFlushRetryInterval = 7 * time.Second
+
+// isBatchable tells whether withdrawal should be sent together with other
+// withdrawals by automatic batching
+func (w *Wallet) isBatchable(tx *types.Transaction) (bool, error) {
+	isRate := tx.FeeType == bitcoin.PerKBRateFee || tx.FeeType == bitcoin.SmartFee
+
+	if w.batchingWindow <= 0 || tx.ColdStorage || tx.BatchID != nil || !isRate {
+		return false, nil
+	}
+	ourAccount, err := w.storage.GetAccountByAddress(tx.Address)
+	if err != nil {
+		return false, err
+	}
+	return ourAccount == nil, nil
+}
+
+func (w *Wallet) triggerBatchFlush() {
+	select {
+	case w.batchFlushTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// queueWithdrawal sets status of withdrawal to 'queued' and starts batching
+// window if it is not started yet. If enough withdrawals are queued, they are
+// sent immediately
+func (w *Wallet) queueWithdrawal(tx *types.Transaction) error {
+	var queued []*types.Transaction
+
+	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		err := currWallet.updatePendingTxStatus(tx, types.QueuedTransaction)
+		if err != nil {
+			return err
+		}
+		queued, err = currWallet.storage.GetTransactionsWithFilter(&TransactionsFilter{
+			Status: types.QueuedTransaction.String(),
+			Limit:  w.batchingMaxSize,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	w.eventBroker.SendNotifications()
+
+	log.Printf(
+		"Queued withdrawal %s to be sent in batch, %d withdrawal(s) queued",
+		tx.ID,
+		len(queued),
+	)
+
+	if w.batchingMaxSize > 0 && len(queued) >= w.batchingMaxSize {
+		w.flushQueuedWithdrawals()
+	} else if w.batchFlushTimer == nil {
+		w.batchFlushTimer = time.AfterFunc(w.batchingWindow, w.triggerBatchFlush)
+	}
+	return nil
+}
+
+// splitAutoBatch splits withdrawals into groups of at most
+// "wallet.batching.max_size" txns with distinct addresses (one Bitcoin tx
+// can't pay to the same address twice) and the same fee payer
+func (w *Wallet) splitAutoBatch(txns []*types.Transaction) [][]*types.Transaction {
+	var batches [][]*types.Transaction
+	var batchAddresses []map[string]bool
+
+	for _, tx := range txns {
+		found := false
+		for i, batch := range batches {
+			full := w.batchingMaxSize > 0 && len(batch) >= w.batchingMaxSize
+			if !full && !batchAddresses[i][tx.Address] &&
+				batch[0].FeePayer == tx.FeePayer {
+				batches[i] = append(batch, tx)
+				batchAddresses[i][tx.Address] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			batches = append(batches, []*types.Transaction{tx})
+			batchAddresses = append(batchAddresses, map[string]bool{tx.Address: true})
+		}
+	}
+	return batches
+}
+
+// sendAutoBatches sends given withdrawals in as few Bitcoin txns as possible.
+// Fee rate of batch is the highest rate requested by its withdrawals. Fee is
+// split between recipients or paid by sender like in case of withdrawals sent
+// one by one
+func (w *Wallet) sendAutoBatches(txns []*types.Transaction, updatePending bool) error {
+	for _, batch := range w.splitAutoBatch(txns) {
+		if len(batch) == 1 {
+			if err := w.sendWithdrawal(batch[0], updatePending); err != nil {
+				return err
+			}
+			continue
+		}
+
+		amounts := make(map[string]bitcoin.BTCAmount)
+		var feeRate bitcoin.BTCAmount
+
+		w.reestimateSmartFee(batch)
+		for _, tx := range batch {
+			amounts[tx.Address] = tx.Amount
+			if tx.Fee > feeRate {
+				feeRate = tx.Fee
+			}
+		}
+
+		log.Printf(
+			"Sending %d withdrawals in one batch with fee rate %s",
+			len(batch),
+			feeRate,
+		)
+
+		err := w.broadcastWithdrawal(
+			batch,
+			map[string]interface{}{
+				"operation": "batch-withdraw",
+				"txns":      batch,
+			},
+			func() (string, error) {
+				return w.nodeAPI.SendManyWithPerKBFee(
+					amounts,
+					feeRate,
+					batch[0].FeePayer != bitcoin.SenderPaysFee,
+				)
+			},
+			updatePending,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tryToFlushQueuedWithdrawals ends batching window: queued withdrawals that
+// can be funded with confirmed balance not reserved for other withdrawals
+// (see reservedForWithdrawals) are sent (smallest first, so that as many of
+// them as possible are sent), others become pending and will be sent by
+// tryToUpdatePendingTxns when there is enough money
+func (w *Wallet) tryToFlushQueuedWithdrawals() error {
+	if w.batchFlushTimer != nil {
+		w.batchFlushTimer.Stop()
+		w.batchFlushTimer = nil
+	}
+
+	queued, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		Status: types.QueuedTransaction.String(),
+	})
+	if err != nil || len(queued) == 0 {
+		return err
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		return withdrawalCost(queued[i]) < withdrawalCost(queued[j])
+	})
+
+	confBal, _, err := w.nodeAPI.GetConfirmedAndUnconfirmedBalance()
+	if err != nil {
+		return err
+	}
+	reserved, err := w.reservedForWithdrawals()
+	if err != nil {
+		return err
+	}
+	// money reserved for pending, scheduled and held withdrawals can't be
+	// used. Reservation also includes queued withdrawals themselves, they are
+	// funded from it
+	availableBalance := int64(confBal) - int64(reserved)
+	for _, tx := range queued {
+		availableBalance += withdrawalCost(tx)
+	}
+
+	fundable := len(queued)
+	for i, tx := range queued {
+		if availableBalance-withdrawalCost(tx) < 0 {
+			fundable = i
+			break
+		}
+		availableBalance -= withdrawalCost(tx)
+	}
+
+	if fundable < len(queued) {
+		log.Printf(
+			"Not enough funds to send %d of %d queued withdrawals, marking "+
+				"them as pending",
+			len(queued)-fundable,
+			len(queued),
+		)
+		err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+			for _, tx := range queued[fundable:] {
+				err := currWallet.updatePendingTxStatus(tx, types.PendingTransaction)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if fundable > 0 {
+		return w.sendAutoBatches(queued[:fundable], true)
+	}
+	w.eventBroker.SendNotifications()
+	w.schedulePendingTxUpdate()
+	return nil
+}
+
+func (w *Wallet) flushQueuedWithdrawals() {
+	err := w.tryToFlushQueuedWithdrawals()
+
+	if err != nil {
+		log.Printf("Error: wallet: failed to send queued withdrawals: %v", err)
+		log.Printf("Will reschedule to try again later")
+		w.batchFlushTimer = time.AfterFunc(
+			batchFlushRetryInterval,
+			w.triggerBatchFlush,
+		)
+	}
+}
@@ -0,0 +1,200 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const testAutoBatchTxHash = "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0"
+
+type nodeAPIAutoBatchMock struct {
+	nodeAPISendManyMock
+
+	sentSingle []string
+}
+
+func (n *nodeAPIAutoBatchMock) SendManyWithPerKBFee(addresses map[string]bitcoin.BTCAmount, fee bitcoin.BTCAmount, recipientsPayFee bool) (string, error) {
+	if !recipientsPayFee {
+		panic("Expected recipients to pay fee of automatic batch")
+	}
+	if _, err := n.SendToMultipleAddresses(addresses); err != nil {
+		return "", err
+	}
+	return testAutoBatchTxHash, nil
+}
+
+func (n *nodeAPIAutoBatchMock) sendSingle(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (string, error) {
+	n.sentSingle = append(n.sentSingle, address)
+	return testBatchTxHash, nil
+}
+
+func (n *nodeAPIAutoBatchMock) SendWithPerKBFee(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (string, error) {
+	return n.sendSingle(address, amount, fee, recipientPaysFee)
+}
+
+func (n *nodeAPIAutoBatchMock) SendWithFixedFee(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (string, error) {
+	return n.sendSingle(address, amount, fee, recipientPaysFee)
+}
+
+func newAutoBatchTestWallet(n *nodeAPIAutoBatchMock) *Wallet {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations":                   1,
+			"wallet.min_withdraw_without_manual_confirmation": bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("10")),
+			// window is long enough not to end during test
+			"wallet.batching.window":   3600000,
+			"wallet.batching.max_size": 3,
+		},
+	}
+	return NewWallet(s, n, &loggingEventBrokerMock{}, NewStorage(nil))
+}
+
+func newTestWithdrawal(address, amount string, feeType bitcoin.FeeType) *types.Transaction {
+	return &types.Transaction{
+		ID:                    uuid.Must(uuid.NewV4()),
+		Address:               address,
+		Direction:             types.OutgoingDirection,
+		Amount:                bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(amount)),
+		Fee:                   bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+		FeeType:               feeType,
+		Fresh:                 true,
+		ReportedConfirmations: -1,
+	}
+}
+
+func assertTxStatus(t *testing.T, w *Wallet, tx *types.Transaction, status types.TransactionStatus, hash string) {
+	stored, err := w.storage.GetTransactionByID(tx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != status || stored.Hash != hash {
+		t.Errorf("Expected tx to %s to have status %s and hash %q, got %s "+
+			"and %q", tx.Address, status, hash, stored.Status, stored.Hash)
+	}
+}
+
+func TestAutoBatchingByCount(t *testing.T) {
+	n := &nodeAPIAutoBatchMock{}
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("10")))
+	w := newAutoBatchTestWallet(n)
+
+	fixedFee := newTestWithdrawal("mhA3AZrxFpVnd4swXN8rtLGzKGbcTDNMCV", "0.1", bitcoin.FixedFee)
+	if err := w.withdraw(fixedFee, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.sentSingle), 1; got != want {
+		t.Fatalf("Expected withdrawal with fixed fee to be sent immediately")
+	}
+
+	withdrawals := []*types.Transaction{
+		newTestWithdrawal("mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB", "0.1", bitcoin.PerKBRateFee),
+		newTestWithdrawal("n1ZCYg9YXtB5XCZazLxSmPDa8iwJRZHhGx", "0.2", bitcoin.PerKBRateFee),
+		// same address can't be paid twice by one bitcoin tx
+		newTestWithdrawal("n1ZCYg9YXtB5XCZazLxSmPDa8iwJRZHhGx", "0.3", bitcoin.PerKBRateFee),
+	}
+	for _, tx := range withdrawals[:2] {
+		if err := w.withdraw(tx, false); err != nil {
+			t.Fatal(err)
+		}
+		assertTxStatus(t, w, tx, types.QueuedTransaction, "")
+	}
+	if len(n.sent) != 0 || len(n.sentSingle) != 1 {
+		t.Fatalf("Expected queued withdrawals not to be sent before window ends")
+	}
+
+	if err := w.withdraw(withdrawals[2], false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.sent), 1; got != want {
+		t.Fatalf("Expected queued withdrawals to be sent in %d batch when "+
+			"max size is reached, got %d", want, got)
+	}
+	if got, want := len(n.sent[0]), 2; got != want {
+		t.Errorf("Expected batch to have %d recipients, got %d", want, got)
+	}
+	if got, want := len(n.sentSingle), 2; got != want {
+		t.Errorf("Expected withdrawal to repeated address to be sent separately")
+	}
+	assertTxStatus(t, w, withdrawals[0], types.NewTransaction, testAutoBatchTxHash)
+	assertTxStatus(t, w, withdrawals[1], types.NewTransaction, testAutoBatchTxHash)
+	assertTxStatus(t, w, withdrawals[2], types.NewTransaction, testBatchTxHash)
+}
+
+func TestAutoBatchingSplitsUnfundedWithdrawals(t *testing.T) {
+	n := &nodeAPIAutoBatchMock{}
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.35")))
+	w := newAutoBatchTestWallet(n)
+	w.batchingMaxSize = 0
+
+	withdrawals := []*types.Transaction{
+		newTestWithdrawal("mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB", "0.3", bitcoin.PerKBRateFee),
+		newTestWithdrawal("n1ZCYg9YXtB5XCZazLxSmPDa8iwJRZHhGx", "0.1", bitcoin.PerKBRateFee),
+		newTestWithdrawal("mhA3AZrxFpVnd4swXN8rtLGzKGbcTDNMCV", "0.2", bitcoin.PerKBRateFee),
+	}
+	for _, tx := range withdrawals {
+		if err := w.withdraw(tx, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.tryToFlushQueuedWithdrawals(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.sent), 1; got != want {
+		t.Fatalf("Expected fundable withdrawals to be sent in %d batch, got %d",
+			want, got)
+	}
+	assertTxStatus(t, w, withdrawals[0], types.PendingTransaction, "")
+	assertTxStatus(t, w, withdrawals[1], types.NewTransaction, testAutoBatchTxHash)
+	assertTxStatus(t, w, withdrawals[2], types.NewTransaction, testAutoBatchTxHash)
+
+	// pending withdrawals that become fundable are also sent in batch
+	more := newTestWithdrawal("mhA3AZrxFpVnd4swXN8rtLGzKGbcTDNMCV", "0.4", bitcoin.PerKBRateFee)
+	if err := w.withdraw(more, false); err != nil {
+		t.Fatal(err)
+	}
+	n.balance = 0
+	if err := w.tryToFlushQueuedWithdrawals(); err != nil {
+		t.Fatal(err)
+	}
+	assertTxStatus(t, w, more, types.PendingTransaction, "")
+
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")))
+	if err := w.tryToUpdatePendingTxns(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.sent), 2; got != want {
+		t.Fatalf("Expected pending withdrawals to be sent in one batch")
+	}
+	assertTxStatus(t, w, withdrawals[0], types.NewTransaction, testAutoBatchTxHash)
+	assertTxStatus(t, w, more, types.NewTransaction, testAutoBatchTxHash)
+}
+
+func TestAutoBatchingKeepsMoneyReservedForOtherWithdrawals(t *testing.T) {
+	n := &nodeAPIAutoBatchMock{}
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.35")))
+	w := newAutoBatchTestWallet(n)
+
+	held := newTestWithdrawal("mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB", "0.3", bitcoin.PerKBRateFee)
+	held.Status = types.PendingManualConfirmationTransaction
+	if _, err := w.storage.StoreTransaction(held); err != nil {
+		t.Fatal(err)
+	}
+
+	queued := newTestWithdrawal("n1ZCYg9YXtB5XCZazLxSmPDa8iwJRZHhGx", "0.1", bitcoin.PerKBRateFee)
+	if err := w.withdraw(queued, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.tryToFlushQueuedWithdrawals(); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.sent) != 0 || len(n.sentSingle) != 0 {
+		t.Errorf("Expected money reserved for held withdrawal not to be "+
+			"spent, sent %v and %v", n.sent, n.sentSingle)
+	}
+	assertTxStatus(t, w, queued, types.PendingTransaction, "")
+}
@@ -1,9 +1,35 @@
 package wallet
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+
+	"github.com/btcsuite/btcutil"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
 )
 
+// RequiredFromColdStorageChange is sent as data of
+// required-from-cold-storage-changed event. Amount is money that should now be
+// transferred from cold storage to fund pending withdrawals (see
+// GetMoneyRequiredFromColdStorage), OldAmount is previous value. Zero Amount
+// means that hot wallet balance is enough again
+type RequiredFromColdStorageChange struct {
+	OldAmount bitcoin.BTCAmount `json:"old_amount"`
+	Amount    bitcoin.BTCAmount `json:"amount"`
+}
+
+func init() {
+	events.RegisterNotificationUnmarshaler(events.RequiredFromColdStorageChangedEvent, func(b []byte) (interface{}, error) {
+		var change RequiredFromColdStorageChange
+
+		err := json.Unmarshal(b, &change)
+		return &change, err
+	})
+}
+
 func (w *Wallet) initColdWallet() {
 	w.coldWalletAddress = w.settings.GetString("wallet.cold_wallet_address")
 	if w.coldWalletAddress == "" {
@@ -22,3 +48,49 @@ func (w *Wallet) initColdWallet() {
 			w.coldWalletAddress))
 	}
 }
+
+func (w *Wallet) initRequiredFromColdStorageMetric() error {
+	amount, err := w.GetMoneyRequiredFromColdStorage()
+	if err != nil {
+		return err
+	}
+	w.requiredFromColdStorage.Set(btcutil.Amount(amount).ToBTC())
+	return nil
+}
+
+// setMoneyRequiredFromColdStorage stores money required to transfer from
+// cold storage. If it has changed, the change is returned, otherwise result
+// is nil. Change should be reported with reportRequiredFromColdStorageChange
+// once DB transaction storing it is committed
+func (w *Wallet) setMoneyRequiredFromColdStorage(amount uint64) (*RequiredFromColdStorageChange, error) {
+	oldAmount, err := w.storage.GetMoneyRequiredFromColdStorage()
+	if err != nil {
+		return nil, err
+	}
+	if amount == oldAmount {
+		return nil, nil
+	}
+	if err = w.storage.SetMoneyRequiredFromColdStorage(amount); err != nil {
+		return nil, err
+	}
+	return &RequiredFromColdStorageChange{
+		OldAmount: bitcoin.BTCAmount(oldAmount),
+		Amount:    bitcoin.BTCAmount(amount),
+	}, nil
+}
+
+// reportRequiredFromColdStorageChange updates metric and notifies client
+// with required-from-cold-storage-changed event about change made by
+// setMoneyRequiredFromColdStorage. Nil change means nothing has changed
+func (w *Wallet) reportRequiredFromColdStorageChange(change *RequiredFromColdStorageChange) error {
+	if change == nil {
+		return nil
+	}
+	log.Printf(
+		"Money required from cold storage changed from %s to %s",
+		change.OldAmount,
+		change.Amount,
+	)
+	w.requiredFromColdStorage.Set(btcutil.Amount(change.Amount).ToBTC())
+	return w.eventBroker.Notify(events.RequiredFromColdStorageChangedEvent, change)
+}
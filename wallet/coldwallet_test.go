@@ -4,7 +4,11 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/btcsuite/btcutil"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
 	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	"github.com/onederx/bitcoin-processing/events"
 	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
 )
 
@@ -112,3 +116,67 @@ func TestColdWalletNotConfigured(t *testing.T) {
 		t.Errorf("Cold wallet address is unexpectedly non-empty: got %s", got)
 	}
 }
+
+func TestRequiredFromColdStorageChanged(t *testing.T) {
+	s := &settingstestutil.SettingsMock{Data: make(map[string]interface{})}
+	e := &loggingEventBrokerMock{}
+	w := NewWallet(s, &nodeAPIGetAddressInfoNotMineMock{t: t}, e, NewStorage(nil))
+
+	tests := []struct {
+		amount    string
+		oldAmount string
+		notified  bool
+	}{
+		{"1.5", "0", true},
+		{"1.5", "1.5", false},
+		{"0.2", "1.5", true},
+		{"0", "0.2", true},
+		{"0", "0", false},
+	}
+	for _, test := range tests {
+		e.flushEvents()
+		amount := btc(test.amount)
+		change, err := w.setMoneyRequiredFromColdStorage(uint64(amount))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(e.log) != 0 {
+			t.Errorf("Expected no events before change is reported, got %v",
+				e.log)
+		}
+		if err = w.reportRequiredFromColdStorageChange(change); err != nil {
+			t.Fatal(err)
+		}
+		metric := testutil.ToFloat64(w.requiredFromColdStorage)
+		if want := btcutil.Amount(amount).ToBTC(); metric != want {
+			t.Errorf("Expected required from cold storage metric to be %f, "+
+				"got %f", want, metric)
+		}
+		if !test.notified {
+			if len(e.log) != 0 {
+				t.Errorf("Expected no events when required amount stays %s, "+
+					"got %v", amount, e.log)
+			}
+			continue
+		}
+		if len(e.log) != 1 || e.log[0].Type != events.RequiredFromColdStorageChangedEvent {
+			t.Fatalf("Expected %s event when required amount changes to %s, "+
+				"got %v", events.RequiredFromColdStorageChangedEvent, amount, e.log)
+		}
+		want := RequiredFromColdStorageChange{
+			OldAmount: btc(test.oldAmount),
+			Amount:    amount,
+		}
+		if got := *e.log[0].Data.(*RequiredFromColdStorageChange); got != want {
+			t.Errorf("Expected event data %+v, got %+v", want, got)
+		}
+	}
+	required, err := w.GetMoneyRequiredFromColdStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if required != 0 {
+		t.Errorf("Expected nothing to be required from cold storage, got %s",
+			required)
+	}
+}
@@ -0,0 +1,42 @@
+package wallet
+
+import (
+	"log"
+
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// isConflictStatus tells whether tx with given status may never be confirmed
+// because it conflicts with another Bitcoin tx
+func isConflictStatus(status types.TransactionStatus) bool {
+	return status == types.ConflictedTransaction || status == types.DoubleSpentTransaction
+}
+
+func getConflictNotificationType(tx *types.Transaction) events.EventType {
+	switch tx.Direction {
+	case types.IncomingDirection:
+		return events.IncomingTxConflictedEvent
+	case types.OutgoingDirection:
+		return events.OutgoingTxConflictedEvent
+	default:
+		panic("Unexpected tx direction " + tx.Direction.String())
+	}
+}
+
+// handleConflict is called when tx becomes conflicted or double-spent. Client
+// is notified unless notify is false, which is the case for internal txns
+func (w *Wallet) handleConflict(tx *types.Transaction, notify bool) error {
+	log.Printf(
+		"Tx %s (%s) is %s: it conflicts with %v, %d confirmations",
+		tx.Hash,
+		tx.ID,
+		tx.Status,
+		tx.Conflicts(),
+		tx.Confirmations,
+	)
+	if !notify {
+		return nil
+	}
+	return w.NotifyTransaction(getConflictNotificationType(tx), *tx)
+}
@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const testConflictingTxHash = "c3a1e4b5d6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8091"
+
+func TestConflictDetection(t *testing.T) {
+	n := &nodeAPIReorgMock{tip: testBlockHash}
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations": 2,
+		},
+	}
+	broker := &loggingEventBrokerMock{}
+	w := NewWallet(s, n, broker, NewStorage(nil))
+
+	deposit := newTestDeposit(testBatchTxHash, types.NewTransaction)
+	deposit.ReportedConfirmations = 0
+	if _, err := w.storage.StoreTransaction(deposit); err != nil {
+		t.Fatal(err)
+	}
+
+	// replacement of the same withdrawal made by fee bump is not a conflict
+	withdrawal := newTestWithdrawal(testAddress, "0.1", bitcoin.PerKBRateFee)
+	withdrawal.Hash = testAutoBatchTxHash
+	withdrawal.Status = types.NewTransaction
+	withdrawal.ReplacedHashes = []string{testConflictingTxHash}
+	withdrawal.ReportedConfirmations = 0
+	if _, err := w.storage.StoreTransaction(withdrawal); err != nil {
+		t.Fatal(err)
+	}
+
+	n.txInfo = btcjson.GetTransactionResult{
+		WalletConflicts: []string{testConflictingTxHash},
+	}
+	w.checkForExistingTransactionUpdates()
+
+	if got, want := len(broker.log), 1; got != want {
+		t.Fatalf("Expected %d event after conflict appeared, got %d", want, got)
+	}
+	if got, want := broker.log[0].Type, events.IncomingTxConflictedEvent; got != want {
+		t.Errorf("Expected event %s, got %s", want, got)
+	}
+	assertTxStatus(t, w, deposit, types.ConflictedTransaction, testBatchTxHash)
+	assertTxStatus(t, w, withdrawal, types.NewTransaction, testAutoBatchTxHash)
+
+	// status is reported once
+	broker.flushEvents()
+	w.checkForExistingTransactionUpdates()
+	if len(broker.log) != 0 {
+		t.Fatalf("Expected no events while tx stays conflicted, got %d", len(broker.log))
+	}
+
+	// conflicting tx is mined
+	n.tip = testOtherBlockHash
+	n.txInfo.Confirmations = -1
+	w.checkForExistingTransactionUpdates()
+
+	if got, want := len(broker.log), 2; got != want {
+		t.Fatalf("Expected %d events after double spend, got %d", want, got)
+	}
+	for _, event := range broker.log {
+		if event.Type != events.IncomingTxConflictedEvent && event.Type != events.OutgoingTxConflictedEvent {
+			t.Errorf("Expected conflict events after double spend, got %s", event.Type)
+		}
+	}
+	assertTxStatus(t, w, deposit, types.DoubleSpentTransaction, testBatchTxHash)
+
+	// double-spent txns are not rechecked until chain tip changes
+	fetched := n.fetched
+	w.checkForExistingTransactionUpdates()
+	if n.fetched != fetched {
+		t.Errorf("Expected double-spent tx not to be rechecked without new block")
+	}
+}
@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"log"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+var cpfpMeta = map[string]interface{}{"kind": "child pays for parent"}
+
+// AccelerateDepositRequest is a structure with parameters of deposit
+// acceleration: id of incoming tx and fee rate per kilobyte for it and
+// Child-Pays-For-Parent tx together
+type AccelerateDepositRequest struct {
+	ID      uuid.UUID         `json:"id"`
+	FeeRate bitcoin.BTCAmount `json:"fee_rate"`
+}
+
+type internalAccelerateDepositRequest struct {
+	internalTxIDRequest
+	childID uuid.UUID
+	feeRate bitcoin.BTCAmount
+}
+
+func (w *Wallet) accelerateDeposit(parentID, childID uuid.UUID, feeRate bitcoin.BTCAmount) error {
+	parent, err := w.GetTransactionByID(parentID)
+	if err != nil {
+		return err
+	}
+
+	if parent.Direction != types.IncomingDirection || parent.Status != types.NewTransaction {
+		return newError(
+			ErrorCodeNotAcceleratable,
+			"Tx %s can't be accelerated: only unconfirmed deposits can be, "+
+				"but tx is %s with status %s",
+			parentID,
+			parent.Direction,
+			parent.Status,
+		)
+	}
+
+	children, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		ParentID: parentID,
+		Limit:    1,
+	})
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return newError(
+			ErrorCodeNotAcceleratable,
+			"Tx %s is already accelerated by tx %s, bump fee of the latter "+
+				"instead",
+			parentID,
+			children[0].ID,
+		)
+	}
+
+	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		return currWallet.storage.LockWallet(map[string]interface{}{
+			"operation": "accelerate-deposit",
+			"parent":    parent,
+			"child_id":  childID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	child, err := w.nodeAPI.ChildPaysForParent(
+		parent.Hash,
+		parent.Address,
+		w.hotWalletAddress,
+		feeRate,
+	)
+	if err != nil {
+		log.Printf("Failed to accelerate tx %s: %v", parent.Hash, err)
+		persistWithdrawResultWithRetry(func() error {
+			return w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+				return currWallet.storage.ClearWallet()
+			})
+		}, err, false)
+		return err
+	}
+
+	log.Printf(
+		"Accelerated tx %s with Child-Pays-For-Parent tx %s paying fee %s",
+		parent.Hash,
+		child.Hash,
+		child.Fee,
+	)
+
+	// CPFP tx sends money to hot wallet, so, like other transfers to hot
+	// wallet, it is internal and client is not notified about it.
+	// Fee is taken from change (the only output) - this way it can be bumped
+	// further like a withdrawal paid by sender
+	tx := &types.Transaction{
+		ID:                    childID,
+		Hash:                  child.Hash,
+		Address:               w.hotWalletAddress,
+		Direction:             types.OutgoingDirection,
+		Status:                types.NewTransaction,
+		Amount:                child.Amount,
+		Metainfo:              cpfpMeta,
+		Fee:                   child.Fee,
+		FeeType:               bitcoin.FixedFee,
+		FeePayer:              bitcoin.SenderPaysFee,
+		ParentID:              &parent.ID,
+		Fresh:                 true,
+		ReportedConfirmations: -1,
+	}
+	persistWithdrawResultWithRetry(func() error {
+		return w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+			if _, err := currWallet.storage.StoreTransaction(tx); err != nil {
+				return err
+			}
+			return currWallet.storage.ClearWallet()
+		})
+	}, nil, false)
+	return nil
+}
+
+// AccelerateDeposit speeds up confirmation of unconfirmed incoming tx (for
+// example, one sent by customer with too low fee) using Child-Pays-For-Parent
+// technique: output of incoming tx is spent back to hot wallet by a new tx
+// which pays fee for both, so that their combined fee rate equals FeeRate of
+// request. FeeRate must not be less than minimal fee rate set in config.
+// CPFP tx is stored as internal outgoing tx to hot wallet, its ParentID is id
+// of accelerated tx. Actual work is done in wallet updater goroutine. Returns
+// stored CPFP tx
+func (w *Wallet) AccelerateDeposit(request *AccelerateDepositRequest) (*types.Transaction, error) {
+	log.Printf(
+		"Got request to accelerate deposit %s with fee rate %s",
+		request.ID,
+		request.FeeRate,
+	)
+
+	if request.FeeRate < w.minFeePerKb {
+		return nil, newError(
+			ErrorCodeFeeBelowMinimum,
+			"Error: refusing to accelerate deposit with fee rate %s because "+
+				"it is less than min fee rate %s",
+			request.FeeRate,
+			w.minFeePerKb,
+		)
+	}
+
+	childID := uuid.Must(uuid.NewV4())
+	resultCh := make(chan error)
+	w.accelerateDepositQueue <- internalAccelerateDepositRequest{
+		internalTxIDRequest: internalTxIDRequest{
+			id:     request.ID,
+			result: resultCh,
+		},
+		childID: childID,
+		feeRate: request.FeeRate,
+	}
+	if err := <-resultCh; err != nil {
+		return nil, err
+	}
+	return w.GetTransactionByID(childID)
+}
@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const testCPFPTxHash = "4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a"
+
+type nodeAPICPFPMock struct {
+	nodeapi.NodeAPI
+
+	accelerated []string
+	destination string
+}
+
+func (n *nodeAPICPFPMock) ChildPaysForParent(parentHash, parentAddress, destination string, feeRate bitcoin.BTCAmount) (*nodeapi.CPFPTransaction, error) {
+	n.accelerated = append(n.accelerated, parentHash)
+	n.destination = destination
+	return &nodeapi.CPFPTransaction{
+		Hash:   testCPFPTxHash,
+		Amount: bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0999")),
+		Fee:    bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+	}, nil
+}
+
+func newTestDeposit(hash string, status types.TransactionStatus) *types.Transaction {
+	return &types.Transaction{
+		ID:                    uuid.Must(uuid.NewV4()),
+		Hash:                  hash,
+		Address:               testAddress,
+		Direction:             types.IncomingDirection,
+		Status:                status,
+		Amount:                bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+		ReportedConfirmations: -1,
+	}
+}
+
+func TestAccelerateDeposit(t *testing.T) {
+	const hotWalletAddress = "mhA3AZrxFpVnd4swXN8rtLGzKGbcTDNMCV"
+
+	n := &nodeAPICPFPMock{}
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"wallet.min_fee.per_kb": bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.00002")),
+		},
+	}
+	w := NewWallet(s, n, &loggingEventBrokerMock{}, NewStorage(nil))
+	w.hotWalletAddress = hotWalletAddress
+
+	deposit := newTestDeposit(testBatchTxHash, types.NewTransaction)
+	confirmed := newTestDeposit(testAutoBatchTxHash, types.ConfirmedTransaction)
+	for _, tx := range []*types.Transaction{deposit, confirmed} {
+		if _, err := w.storage.StoreTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := w.AccelerateDeposit(&AccelerateDepositRequest{
+		ID:      deposit.ID,
+		FeeRate: bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.00001")),
+	})
+	if walletErr, ok := err.(*Error); !ok || walletErr.Code != ErrorCodeFeeBelowMinimum {
+		t.Errorf("Expected acceleration with low fee rate to fail with code "+
+			"%s, got %v", ErrorCodeFeeBelowMinimum, err)
+	}
+
+	err = w.accelerateDeposit(confirmed.ID, uuid.Must(uuid.NewV4()), w.minFeePerKb)
+	if walletErr, ok := err.(*Error); !ok || walletErr.Code != ErrorCodeNotAcceleratable {
+		t.Errorf("Expected acceleration of confirmed deposit to fail with "+
+			"code %s, got %v", ErrorCodeNotAcceleratable, err)
+	}
+
+	childID := uuid.Must(uuid.NewV4())
+	if err = w.accelerateDeposit(deposit.ID, childID, w.minFeePerKb); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.accelerated) != 1 || n.destination != hotWalletAddress {
+		t.Fatalf("Expected deposit to be spent to hot wallet once, got %d "+
+			"txns to %s", len(n.accelerated), n.destination)
+	}
+	child, err := w.storage.GetTransactionByID(childID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.Hash != testCPFPTxHash || child.Direction != types.OutgoingDirection ||
+		child.Address != hotWalletAddress {
+		t.Errorf("Expected CPFP tx to be outgoing tx %s to hot wallet, got %s "+
+			"tx %s to %s", testCPFPTxHash, child.Direction, child.Hash,
+			child.Address)
+	}
+	if child.ParentID == nil || *child.ParentID != deposit.ID {
+		t.Errorf("Expected CPFP tx to be linked to deposit %s, got %v",
+			deposit.ID, child.ParentID)
+	}
+
+	err = w.accelerateDeposit(deposit.ID, uuid.Must(uuid.NewV4()), w.minFeePerKb)
+	if walletErr, ok := err.(*Error); !ok || walletErr.Code != ErrorCodeNotAcceleratable {
+		t.Errorf("Expected second acceleration of deposit to fail with code "+
+			"%s, got %v", ErrorCodeNotAcceleratable, err)
+	}
+}
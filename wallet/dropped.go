@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"log"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// checkDropped checks whether unconfirmed outgoing tx which was received by
+// Bitcoin node at given time is still in node mempool. Txns younger than
+// "transaction.rebroadcast_after" are not checked. If tx is not in mempool, it
+// is broadcasted again, and if node refuses to accept it, tx becomes dropped.
+// Other errors (for example, failure to connect to node) are returned, so that
+// tx is checked again later. Dropped tx that returned to mempool (for
+// example, was rebroadcasted by node itself) becomes new again
+func (w *Wallet) checkDropped(tx *types.Transaction, received time.Time) error {
+	if tx.Direction != types.OutgoingDirection || tx.Confirmations != 0 || w.rebroadcastAfter <= 0 {
+		return nil
+	}
+	switch tx.Status {
+	case types.NewTransaction:
+		if time.Since(received) < w.rebroadcastAfter {
+			return nil
+		}
+	case types.DroppedTransaction:
+	default:
+		return nil
+	}
+
+	inMempool, err := w.nodeAPI.IsInMempool(tx.Hash)
+	if err != nil {
+		return err
+	}
+	if inMempool {
+		if tx.Status == types.DroppedTransaction {
+			log.Printf("Dropped tx %s (%s) is back in mempool", tx.Hash, tx.ID)
+			tx.Status = types.NewTransaction
+		}
+		return nil
+	}
+	if tx.Status == types.DroppedTransaction {
+		return nil
+	}
+
+	err = w.nodeAPI.RebroadcastTransaction(tx.Hash)
+	if err == nil {
+		log.Printf(
+			"Tx %s (%s) was not in mempool %s after it was sent, rebroadcasted "+
+				"it",
+			tx.Hash,
+			tx.ID,
+			time.Since(received),
+		)
+		return nil
+	}
+	if _, refused := err.(*nodeapi.JSONRPCError); !refused {
+		return err
+	}
+	log.Printf(
+		"Tx %s (%s) was dropped from mempool and can't be rebroadcasted: %v",
+		tx.Hash,
+		tx.ID,
+		err,
+	)
+	tx.Status = types.DroppedTransaction
+	return nil
+}
@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+type nodeAPIMempoolMock struct {
+	nodeAPIReorgMock
+
+	inMempool      bool
+	rebroadcastErr error
+	rebroadcasted  int
+	bumped         []string
+}
+
+func (n *nodeAPIMempoolMock) IsInMempool(hash string) (bool, error) {
+	return n.inMempool, nil
+}
+
+func (n *nodeAPIMempoolMock) RebroadcastTransaction(hash string) error {
+	n.rebroadcasted++
+	if n.rebroadcastErr == nil {
+		n.inMempool = true
+	}
+	return n.rebroadcastErr
+}
+
+func (n *nodeAPIMempoolMock) BumpFee(hash string, fee bitcoin.BTCAmount, feeType bitcoin.FeeType, recipientsPayFee bool) (string, error) {
+	n.bumped = append(n.bumped, hash)
+	n.inMempool = true
+	return testReplacementTxHash, nil
+}
+
+func TestDroppedWithdrawal(t *testing.T) {
+	n := &nodeAPIMempoolMock{}
+	n.tip = testBlockHash
+	n.txInfo = btcjson.GetTransactionResult{TimeReceived: time.Now().Unix()}
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations": 2,
+			"transaction.rebroadcast_after": 600,
+		},
+	}
+	broker := &loggingEventBrokerMock{}
+	w := NewWallet(s, n, broker, NewStorage(nil))
+
+	withdrawal := newTestWithdrawal(testAddress, "0.1", bitcoin.PerKBRateFee)
+	withdrawal.Hash = testBatchTxHash
+	withdrawal.Status = types.NewTransaction
+	withdrawal.ReportedConfirmations = 0
+	if _, err := w.storage.StoreTransaction(withdrawal); err != nil {
+		t.Fatal(err)
+	}
+
+	// young txns are not checked
+	w.checkForExistingTransactionUpdates()
+	if n.rebroadcasted != 0 {
+		t.Errorf("Expected tx not to be rebroadcasted before it gets old")
+	}
+
+	// old tx missing from mempool is rebroadcasted
+	n.txInfo.TimeReceived = time.Now().Add(-time.Hour).Unix()
+	w.checkForExistingTransactionUpdates()
+	if got, want := n.rebroadcasted, 1; got != want {
+		t.Fatalf("Expected tx to be rebroadcasted %d time, got %d", want, got)
+	}
+	assertTxStatus(t, w, withdrawal, types.NewTransaction, testBatchTxHash)
+	if len(broker.log) != 0 {
+		t.Errorf("Expected no events after successful rebroadcast, got %d", len(broker.log))
+	}
+
+	// failure to reach node does not make tx dropped
+	n.inMempool = false
+	n.rebroadcastErr = errors.New("connection refused")
+	w.checkForExistingTransactionUpdates()
+	assertTxStatus(t, w, withdrawal, types.NewTransaction, testBatchTxHash)
+	if len(broker.log) != 0 {
+		t.Errorf("Expected no events when rebroadcast failed to reach node, got %d", len(broker.log))
+	}
+
+	// node refuses to accept tx again
+	n.rebroadcastErr = &nodeapi.JSONRPCError{Code: -26, Message: "mempool min fee not met"}
+	w.checkForExistingTransactionUpdates()
+	assertTxStatus(t, w, withdrawal, types.DroppedTransaction, testBatchTxHash)
+	if got, want := len(broker.log), 1; got != want {
+		t.Fatalf("Expected %d event after tx was dropped, got %d", want, got)
+	}
+	if got, want := broker.log[0].Type, events.WithdrawalDroppedEvent; got != want {
+		t.Errorf("Expected event %s, got %s", want, got)
+	}
+
+	// dropped tx is reported once and not rebroadcasted again
+	broker.flushEvents()
+	w.checkForExistingTransactionUpdates()
+	if len(broker.log) != 0 || n.rebroadcasted != 3 {
+		t.Errorf("Expected dropped tx to be left alone, got %d events and %d "+
+			"rebroadcasts", len(broker.log), n.rebroadcasted)
+	}
+
+	// operator re-issues withdrawal with higher fee
+	newFee := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0005"))
+	if err := w.bumpFee(withdrawal.ID, newFee, bitcoin.PerKBRateFee); err != nil {
+		t.Fatal(err)
+	}
+	assertTxStatus(t, w, withdrawal, types.NewTransaction, testReplacementTxHash)
+	w.checkForExistingTransactionUpdates()
+	assertTxStatus(t, w, withdrawal, types.NewTransaction, testReplacementTxHash)
+}
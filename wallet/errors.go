@@ -0,0 +1,53 @@
+package wallet
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ErrorCode is a machine-readable kind of Error. API uses it to choose HTTP
+// status of response and passes it to client
+type ErrorCode string
+
+// Possible codes of wallet errors
+const (
+	ErrorCodeInvalidRequest     ErrorCode = "invalid_request"
+	ErrorCodeInsufficientFunds  ErrorCode = "insufficient_funds"
+	ErrorCodeDuplicateID        ErrorCode = "duplicate_id"
+	ErrorCodeAmountBelowMinimum ErrorCode = "amount_below_minimum"
+	ErrorCodeFeeBelowMinimum    ErrorCode = "fee_below_minimum"
+	ErrorCodeNotPending         ErrorCode = "not_pending"
+	ErrorCodeHotWalletAddress   ErrorCode = "hot_wallet_address"
+	ErrorCodeNotFound           ErrorCode = "not_found"
+	ErrorCodeSelfApproval       ErrorCode = "self_approval"
+	ErrorCodeDuplicateApproval  ErrorCode = "duplicate_approval"
+	ErrorCodeNotReplaceable     ErrorCode = "not_replaceable"
+	ErrorCodeNotAcceleratable   ErrorCode = "not_acceleratable"
+)
+
+// Error is an error caused by request wallet can't fulfill (as opposed to
+// internal errors like failure to reach DB or Bitcoin node). It has Code
+// telling what is wrong with request and human-readable Message
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// isNoTxWithSuchIDError tells whether err is returned by storage because tx
+// with requested id does not exist
+func isNoTxWithSuchIDError(err error) bool {
+	if err == sql.ErrNoRows {
+		return true
+	}
+	// Such error is returned by memory storage.
+	_, ok := err.(ErrNoTxWithSuchID)
+	return ok
+}
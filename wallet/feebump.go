@@ -0,0 +1,275 @@
+package wallet
+
+import (
+	"log"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// BumpFeeRequest is a structure with parameters of fee bump: id of withdrawal
+// and its new fee, which is a rate per kilobyte or a fixed value depending on
+// FeeType ("per-kb-rate" or "fixed")
+type BumpFeeRequest struct {
+	ID      uuid.UUID         `json:"id"`
+	Fee     bitcoin.BTCAmount `json:"fee"`
+	FeeType string            `json:"fee_type"`
+}
+
+type internalBumpFeeRequest struct {
+	internalTxIDRequest
+	fee     bitcoin.BTCAmount
+	feeType bitcoin.FeeType
+}
+
+// replaceHash records that withdrawal is now paid by bitcoin tx with given
+// hash, fee and fee type and notifies client about it with event of given
+// type. Current hash, fee and fee type are remembered in ReplacedHashes and
+// ReplacedFees. New tx is not mined yet, so withdrawal becomes new again
+func (w *Wallet) replaceHash(tx *types.Transaction, hash string, fee bitcoin.BTCAmount,
+	feeType bitcoin.FeeType, eventType events.EventType) error {
+	replacedHashes := make([]string, 0, len(tx.ReplacedHashes)+1)
+	for _, replaced := range tx.ReplacedHashes {
+		if replaced != hash {
+			replacedHashes = append(replacedHashes, replaced)
+		}
+	}
+	replacedFees := make([]types.ReplacedFee, 0, len(tx.ReplacedFees)+1)
+	for _, replaced := range tx.ReplacedFees {
+		if replaced.Hash != hash {
+			replacedFees = append(replacedFees, replaced)
+		}
+	}
+	tx.ReplacedHashes = append(replacedHashes, tx.Hash)
+	tx.ReplacedFees = append(replacedFees, types.ReplacedFee{
+		Hash:    tx.Hash,
+		Fee:     tx.Fee,
+		FeeType: tx.FeeType,
+	})
+	tx.Hash = hash
+	tx.Fee = fee
+	tx.FeeType = feeType
+	tx.BlockHash = ""
+	tx.Confirmations = 0
+	tx.Status = types.NewTransaction
+
+	if err := w.storage.updateReplacement(tx); err != nil {
+		return err
+	}
+	if tx.ColdStorage || tx.Address == w.hotWalletAddress {
+		// don't notify about internal txns
+		return nil
+	}
+	return w.NotifyTransaction(eventType, *tx)
+}
+
+func (w *Wallet) bumpFee(id uuid.UUID, fee bitcoin.BTCAmount, feeType bitcoin.FeeType) error {
+	tx, err := w.GetTransactionByID(id)
+	if err != nil {
+		return err
+	}
+
+	replaceable := tx.Status == types.NewTransaction || tx.Status == types.DroppedTransaction
+	if tx.Direction != types.OutgoingDirection || !replaceable || tx.Hash == "" {
+		return newError(
+			ErrorCodeNotReplaceable,
+			"Fee of tx %s can't be bumped: only unconfirmed or dropped "+
+				"withdrawals can be replaced, but tx has status %s",
+			id,
+			tx.Status,
+		)
+	}
+
+	// all withdrawals paid by the same bitcoin tx (entries of batch) get
+	// new hash together
+	txns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		Direction: types.OutgoingDirection.String(),
+		Hash:      tx.Hash,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		return currWallet.storage.LockWallet(map[string]interface{}{
+			"operation": "bump-fee",
+			"txns":      txns,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	newHash, err := w.nodeAPI.BumpFee(
+		tx.Hash,
+		fee,
+		feeType,
+		tx.FeePayer != bitcoin.SenderPaysFee,
+	)
+	if err != nil {
+		log.Printf("Failed to bump fee of tx %s: %v", tx.Hash, err)
+		persistWithdrawResultWithRetry(func() error {
+			return w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+				return currWallet.storage.ClearWallet()
+			})
+		}, err, false)
+		return err
+	}
+
+	log.Printf(
+		"Bumped fee of tx %s to %s (%s), replacement tx is %s",
+		tx.Hash,
+		fee,
+		feeType,
+		newHash,
+	)
+
+	persistWithdrawResultWithRetry(func() error {
+		return w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+			for _, tx := range txns {
+				// replaceHash modifies tx, work on a copy in case of retry
+				replacedTx := *tx
+				err := currWallet.replaceHash(
+					&replacedTx,
+					newHash,
+					fee,
+					feeType,
+					events.WithdrawalFeeBumpedEvent,
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return currWallet.storage.ClearWallet()
+		})
+	}, nil, false)
+
+	w.eventBroker.SendNotifications()
+	return nil
+}
+
+// BumpFee replaces Bitcoin tx of unconfirmed withdrawal (one with status
+// 'new') by a tx paying higher fee (Replace-By-Fee, BIP 125). This is useful
+// when withdrawal is stuck because fee was too low. Withdrawal with status
+// 'dropped' is re-issued the same way: replacement spends the same inputs, so
+// only one of them can get into blockchain. New fee is either a rate
+// per kilobyte or a fixed value, it must be higher than current fee and not
+// less than minimal fee set in config. Fee increase is paid by the same party
+// that paid original fee (see FeePayer of Transaction). If withdrawal is an
+// entry of batch, fee of whole batch is bumped.
+// Withdrawal keeps its id, but gets new hash, previous hash is added to
+// ReplacedHashes and WithdrawalFeeBumpedEvent is emitted. Actual work is done
+// in wallet updater goroutine
+func (w *Wallet) BumpFee(request *BumpFeeRequest) error {
+	feeType, err := bitcoin.FeeTypeFromString(request.FeeType)
+	if err != nil {
+		return &Error{Code: ErrorCodeInvalidRequest, Message: err.Error()}
+	}
+
+	log.Printf(
+		"Got request to bump fee of withdrawal %s to %s (type %s)",
+		request.ID,
+		request.Fee,
+		feeType,
+	)
+
+	var minFee bitcoin.BTCAmount
+
+	switch feeType {
+	case bitcoin.PerKBRateFee:
+		minFee = w.minFeePerKb
+	case bitcoin.FixedFee:
+		minFee = w.minFeeFixed
+	default:
+		return newError(
+			ErrorCodeInvalidRequest,
+			"Fee type %s is not supported for fee bump, use per-kb-rate or "+
+				"fixed",
+			feeType,
+		)
+	}
+	if request.Fee < minFee {
+		return newError(
+			ErrorCodeFeeBelowMinimum,
+			"Error: refusing to bump fee to %s because it is less than min "+
+				"withdraw fee %s for fee type %s",
+			request.Fee,
+			minFee,
+			feeType,
+		)
+	}
+
+	resultCh := make(chan error)
+	w.bumpFeeQueue <- internalBumpFeeRequest{
+		internalTxIDRequest: internalTxIDRequest{
+			id:     request.ID,
+			result: resultCh,
+		},
+		fee:     request.Fee,
+		feeType: feeType,
+	}
+	return <-resultCh
+}
+
+// isReplacedHash tells whether bitcoin tx with given hash paid some withdrawal
+// before its fee was bumped
+func (w *Wallet) isReplacedHash(hash string) (bool, error) {
+	txns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		ReplacedHash: hash,
+		Limit:        1,
+	})
+	return len(txns) > 0, err
+}
+
+// trackReplacements checks whether one of bitcoin txns replaced by fee bumps
+// got into blockchain instead of current one (this is possible, for example,
+// if replacement was broadcasted when replaced tx was already being mined).
+// If so, this tx becomes current again, withdrawal gets back fee and fee type
+// it had when paid by this tx and WithdrawalReplacementRevertedEvent is sent
+func (w *Wallet) trackReplacements(tx *types.Transaction) error {
+	if len(tx.ReplacedHashes) == 0 || tx.Confirmations >= 0 {
+		return nil
+	}
+	// negative number of confirmations means current tx conflicts with
+	// one in blockchain
+	for _, replaced := range tx.ReplacedHashes {
+		replacedTxInfo, err := w.nodeAPI.GetTransaction(replaced)
+		if err != nil {
+			return err
+		}
+		if replacedTxInfo.Confirmations <= 0 {
+			continue
+		}
+		log.Printf(
+			"Tx %s replaced by %s got into blockchain instead of it, "+
+				"switching withdrawal %s back to it",
+			replaced,
+			tx.Hash,
+			tx.ID,
+		)
+		// withdrawals bumped before fees of replaced txns were stored keep
+		// current fee
+		fee, feeType := tx.Fee, tx.FeeType
+		for _, replacedFee := range tx.ReplacedFees {
+			if replacedFee.Hash == replaced {
+				fee, feeType = replacedFee.Fee, replacedFee.FeeType
+			}
+		}
+		err = w.replaceHash(
+			tx,
+			replaced,
+			fee,
+			feeType,
+			events.WithdrawalReplacementRevertedEvent,
+		)
+		if err != nil {
+			return err
+		}
+		tx.UpdateFromFullTxInfo(replacedTxInfo)
+		return nil
+	}
+	return nil
+}
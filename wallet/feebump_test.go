@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const testReplacementTxHash = "9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a2f1e0d9c8b7a6f5e4d3c2b1a0f9e8d"
+
+type nodeAPIBumpFeeMock struct {
+	nodeapi.NodeAPI
+
+	bumped        []string
+	confirmations map[string]int64
+}
+
+func (n *nodeAPIBumpFeeMock) BumpFee(hash string, fee bitcoin.BTCAmount, feeType bitcoin.FeeType, recipientsPayFee bool) (string, error) {
+	n.bumped = append(n.bumped, hash)
+	return testReplacementTxHash, nil
+}
+
+func (n *nodeAPIBumpFeeMock) GetTransaction(hash string) (*btcjson.GetTransactionResult, error) {
+	return &btcjson.GetTransactionResult{
+		TxID:          hash,
+		Confirmations: n.confirmations[hash],
+	}, nil
+}
+
+func TestBumpFee(t *testing.T) {
+	n := &nodeAPIBumpFeeMock{}
+	e := &loggingEventBrokerMock{}
+	w := NewWallet(&settingstestutil.SettingsMock{}, n, e, NewStorage(nil))
+
+	// entries of batch share bitcoin tx and are bumped together
+	batch := []*types.Transaction{
+		newTestWithdrawal("mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB", "0.1", bitcoin.PerKBRateFee),
+		newTestWithdrawal("n1ZCYg9YXtB5XCZazLxSmPDa8iwJRZHhGx", "0.2", bitcoin.PerKBRateFee),
+	}
+	for _, tx := range batch {
+		tx.Status = types.NewTransaction
+		tx.Hash = testBatchTxHash
+		if _, err := w.storage.StoreTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending := newTestWithdrawal("mhA3AZrxFpVnd4swXN8rtLGzKGbcTDNMCV", "0.3", bitcoin.PerKBRateFee)
+	pending.Status = types.PendingTransaction
+	if _, err := w.storage.StoreTransaction(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	err := w.bumpFee(pending.ID, bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0005")), bitcoin.PerKBRateFee)
+	if walletErr, ok := err.(*Error); !ok || walletErr.Code != ErrorCodeNotReplaceable {
+		t.Errorf("Expected bump of pending withdrawal to fail with code %s, "+
+			"got %v", ErrorCodeNotReplaceable, err)
+	}
+
+	newFee := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0005"))
+	if err = w.bumpFee(batch[0].ID, newFee, bitcoin.FixedFee); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.bumped), 1; got != want {
+		t.Fatalf("Expected bitcoin tx of batch to be bumped once, got %d", got)
+	}
+	for _, tx := range batch {
+		stored, err := w.storage.GetTransactionByID(tx.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Hash != testReplacementTxHash {
+			t.Errorf("Expected tx to %s to get hash of replacement tx, got %q",
+				tx.Address, stored.Hash)
+		}
+		if len(stored.ReplacedHashes) != 1 || stored.ReplacedHashes[0] != testBatchTxHash {
+			t.Errorf("Expected tx to %s to have replaced hash %s, got %v",
+				tx.Address, testBatchTxHash, stored.ReplacedHashes)
+		}
+		if stored.Fee != newFee || stored.FeeType != bitcoin.FixedFee {
+			t.Errorf("Expected tx to %s to have fee %s (fixed), got %s (%s)",
+				tx.Address, newFee, stored.Fee, stored.FeeType)
+		}
+	}
+	if replaced, err := w.isReplacedHash(testBatchTxHash); err != nil || !replaced {
+		t.Errorf("Expected hash %s to be recognized as replaced", testBatchTxHash)
+	}
+
+	// original tx was mined anyway, replacement conflicts with it
+	e.flushEvents()
+	n.confirmations = map[string]int64{
+		testBatchTxHash:       1,
+		testReplacementTxHash: -1,
+	}
+	tx, err := w.storage.GetTransactionByID(batch[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Confirmations = -1
+	if err = w.trackReplacements(tx); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Hash != testBatchTxHash || tx.Confirmations != 1 {
+		t.Errorf("Expected withdrawal to switch back to mined tx %s, got %s "+
+			"with %d confirmations", testBatchTxHash, tx.Hash, tx.Confirmations)
+	}
+	if len(tx.ReplacedHashes) != 1 || tx.ReplacedHashes[0] != testReplacementTxHash {
+		t.Errorf("Expected replacement tx to become replaced hash, got %v",
+			tx.ReplacedHashes)
+	}
+	stored, err := w.storage.GetTransactionByID(tx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Fee != batch[0].Fee || stored.FeeType != bitcoin.PerKBRateFee {
+		t.Errorf("Expected withdrawal to get fee %s (per-kb-rate) of mined "+
+			"tx back, got %s (%s)", batch[0].Fee, stored.Fee, stored.FeeType)
+	}
+	if len(e.log) != 1 || e.log[0].Type != events.WithdrawalReplacementRevertedEvent {
+		t.Errorf("Expected one %s event, got %v",
+			events.WithdrawalReplacementRevertedEvent, e.log)
+	}
+}
@@ -0,0 +1,170 @@
+package wallet
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// TransactionsFilter describes which txns should be fetched from storage by
+// GetTransactionsWithFilter. Zero value of any field means do not use this
+// filter, with non-zero value only txns that match it will be included.
+// Zero Limit means no limit
+type TransactionsFilter struct {
+	Direction string
+	Status    string
+	Address   string
+	Hash      string
+	BatchID   uuid.UUID
+
+	// ParentID selects Child-Pays-For-Parent txns accelerating incoming tx
+	// with given id
+	ParentID uuid.UUID
+
+	// ReplacedHash selects txns which bitcoin tx with given hash was replaced
+	// by fee bump
+	ReplacedHash string
+
+	// ColdStorage, if not nil, selects only withdrawals to cold storage
+	// (if true) or only other txns (if false)
+	ColdStorage *bool
+
+	// Inclusive bounds of tx amount
+	MinAmount bitcoin.BTCAmount
+	MaxAmount bitcoin.BTCAmount
+
+	// Bounds of creation and last update time. Lower bounds (After) are
+	// inclusive, upper bounds (Before) are exclusive
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	// Metainfo selects txns which metainfo contains given JSON value in the
+	// same sense as Postgres JSONB containment operator @>: for example,
+	// {"user_id": 42} matches any tx which metainfo is an object with field
+	// user_id equal to 42
+	Metainfo interface{}
+
+	Order SortOrder
+	Limit int
+
+	// After is a position of last tx on previous page. If it is not nil,
+	// only txns that follow it in chosen order are included
+	After *TransactionsCursor
+}
+
+// matches tells whether tx passes filter. It is used by in-memory storage,
+// Postgres storage implements same checks in SQL
+func (f *TransactionsFilter) matches(tx *types.Transaction) bool {
+	switch {
+	case f.Direction != "" && f.Direction != tx.Direction.String():
+		return false
+	case f.Status != "" && f.Status != tx.Status.String():
+		return false
+	case f.Address != "" && f.Address != tx.Address:
+		return false
+	case f.Hash != "" && f.Hash != tx.Hash:
+		return false
+	case f.BatchID != uuid.Nil && (tx.BatchID == nil || *tx.BatchID != f.BatchID):
+		return false
+	case f.ParentID != uuid.Nil && (tx.ParentID == nil || *tx.ParentID != f.ParentID):
+		return false
+	case f.ReplacedHash != "" && !containsString(tx.ReplacedHashes, f.ReplacedHash):
+		return false
+	case f.ColdStorage != nil && *f.ColdStorage != tx.ColdStorage:
+		return false
+	case f.MinAmount != 0 && tx.Amount < f.MinAmount:
+		return false
+	case f.MaxAmount != 0 && tx.Amount > f.MaxAmount:
+		return false
+	case !inTimeRange(tx.CreatedAt, f.CreatedAfter, f.CreatedBefore):
+		return false
+	case !inTimeRange(tx.UpdatedAt, f.UpdatedAfter, f.UpdatedBefore):
+		return false
+	case f.Metainfo != nil && !jsonContains(normalizeJSON(tx.Metainfo), normalizeJSON(f.Metainfo)):
+		return false
+	}
+	if f.After != nil {
+		position := cursorOfTransaction(tx)
+		if f.Order == SortDescending {
+			return position.less(f.After)
+		}
+		return f.After.less(position)
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func inTimeRange(t, after, before time.Time) bool {
+	if !after.IsZero() && t.Before(after) {
+		return false
+	}
+	return before.IsZero() || t.Before(before)
+}
+
+// normalizeJSON converts value to the form it has after being decoded from
+// JSON, so that values stored in memory can be compared with ones that came
+// from API
+func normalizeJSON(value interface{}) interface{} {
+	var result interface{}
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	if err = json.Unmarshal(valueJSON, &result); err != nil {
+		return nil
+	}
+	return result
+}
+
+// jsonContains mimics Postgres JSONB containment operator: objects contain
+// objects with subset of their fields (compared recursively), arrays contain
+// arrays which every element is contained by some element of container,
+// scalars contain equal scalars
+func jsonContains(container, contained interface{}) bool {
+	switch containedValue := contained.(type) {
+	case map[string]interface{}:
+		containerValue, ok := container.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range containedValue {
+			if field, ok := containerValue[key]; !ok || !jsonContains(field, value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		containerValue, ok := container.([]interface{})
+		if !ok {
+			return false
+		}
+	elements:
+		for _, value := range containedValue {
+			for _, element := range containerValue {
+				if jsonContains(element, value) {
+					continue elements
+				}
+			}
+			return false
+		}
+		return true
+	default:
+		return reflect.DeepEqual(container, contained)
+	}
+}
@@ -0,0 +1,130 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+func TestGetTransactionsWithFilter(t *testing.T) {
+	var (
+		storage = NewStorage(nil)
+		weekAgo = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+		yes     = true
+
+		coldStorageAddress = "addr2"
+		smallTx            = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.01"))
+		largeTx            = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1"))
+		threshold          = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1"))
+	)
+
+	newTx := func(address string, amount bitcoin.BTCAmount, createdAt time.Time, metainfo interface{}) *types.Transaction {
+		tx := &types.Transaction{
+			ID:          uuid.Must(uuid.NewV4()),
+			Hash:        uuid.Must(uuid.NewV4()).String(),
+			Address:     address,
+			Direction:   types.IncomingDirection,
+			Status:      types.NewTransaction,
+			Amount:      amount,
+			CreatedAt:   createdAt,
+			Metainfo:    metainfo,
+			ColdStorage: address == coldStorageAddress,
+		}
+		if _, err := storage.StoreTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	old := newTx("addr1", largeTx, weekAgo.Add(-time.Hour), nil)
+	small := newTx("addr1", smallTx, weekAgo.Add(time.Hour), map[string]interface{}{"user_id": 42})
+	large := newTx("addr1", largeTx, weekAgo.Add(2*time.Hour), map[string]interface{}{
+		"user_id": 42,
+		"tags":    []string{"vip", "new"},
+	})
+	other := newTx(coldStorageAddress, largeTx, weekAgo.Add(3*time.Hour), map[string]interface{}{"user_id": 43})
+
+	tests := []struct {
+		name   string
+		filter TransactionsFilter
+		want   []*types.Transaction
+	}{
+		{
+			name:   "Address",
+			filter: TransactionsFilter{Address: "addr1"},
+			want:   []*types.Transaction{old, small, large},
+		},
+		{
+			name:   "Hash",
+			filter: TransactionsFilter{Hash: small.Hash},
+			want:   []*types.Transaction{small},
+		},
+		{
+			name: "AddressTimeAndAmount",
+			filter: TransactionsFilter{
+				Address:      "addr1",
+				CreatedAfter: weekAgo,
+				MinAmount:    threshold,
+			},
+			want: []*types.Transaction{large},
+		},
+		{
+			name:   "MaxAmount",
+			filter: TransactionsFilter{MaxAmount: threshold},
+			want:   []*types.Transaction{small},
+		},
+		{
+			name: "CreatedBeforeIsExclusive",
+			filter: TransactionsFilter{
+				CreatedAfter:  small.CreatedAt,
+				CreatedBefore: large.CreatedAt,
+			},
+			want: []*types.Transaction{small},
+		},
+		{
+			name:   "ColdStorage",
+			filter: TransactionsFilter{ColdStorage: &yes},
+			want:   []*types.Transaction{other},
+		},
+		{
+			name:   "MetainfoField",
+			filter: TransactionsFilter{Metainfo: map[string]interface{}{"user_id": 42}},
+			want:   []*types.Transaction{small, large},
+		},
+		{
+			name: "MetainfoArrayElement",
+			filter: TransactionsFilter{Metainfo: map[string]interface{}{
+				"tags": []interface{}{"new"},
+			}},
+			want: []*types.Transaction{large},
+		},
+		{
+			name:   "MetainfoNoMatch",
+			filter: TransactionsFilter{Metainfo: map[string]interface{}{"user_id": "42"}},
+			want:   []*types.Transaction{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			txns, err := storage.GetTransactionsWithFilter(&test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txns) != len(test.want) {
+				t.Fatalf("Expected %d txns, got %d: %v", len(test.want),
+					len(txns), txns)
+			}
+			for i := range txns {
+				if txns[i].ID != test.want[i].ID {
+					t.Errorf("Expected tx %d to be %s, got %s", i,
+						test.want[i].ID, txns[i].ID)
+				}
+			}
+		})
+	}
+}
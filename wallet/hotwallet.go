@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
 )
 
 func (w *Wallet) generateHotWalletAddress() (string, error) {
-	newHotWalletAddress, err := w.generateNewAddress()
+	newHotWalletAddress, _, err := w.generateNewAddress(bitcoin.InvalidAddressType)
 	if err != nil {
 		return "", errors.New(
 			"Error generating hot wallet address " + err.Error(),
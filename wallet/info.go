@@ -1,16 +1,47 @@
 package wallet
 
 import (
+	"github.com/gofrs/uuid"
+
 	"github.com/onederx/bitcoin-processing/bitcoin"
 	"github.com/onederx/bitcoin-processing/wallet/types"
 )
 
-// GetTransactionsWithFilter fetches transactions from storage filtered by
+// GetTransactionsWithFilter fetches all transactions from storage filtered by
 // status and direction. Empty filter string means do not filter, nonempty
 // string means only transactions with equal value of corresponding parameter
-// will be included
+// will be included. It is meant for internal use, API should use paginated
+// GetTransactions
 func (w *Wallet) GetTransactionsWithFilter(directionFilter string, statusFilter string) ([]*types.Transaction, error) {
-	return w.storage.GetTransactionsWithFilter(directionFilter, statusFilter)
+	return w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		Direction: directionFilter,
+		Status:    statusFilter,
+	})
+}
+
+// GetTransactionByID fetches transaction with given id from storage. If there
+// is no such transaction, *Error with code ErrorCodeNotFound is returned
+func (w *Wallet) GetTransactionByID(id uuid.UUID) (*types.Transaction, error) {
+	tx, err := w.storage.GetTransactionByID(id)
+	if isNoTxWithSuchIDError(err) {
+		return nil, newError(ErrorCodeNotFound, "Transaction with id %s not found", id)
+	}
+	return tx, err
+}
+
+// GetTransactionsByHash fetches all transactions corresponding to bitcoin tx
+// with given hash. There can be several of them if bitcoin tx pays to several
+// wallet addresses or is a transfer between them. If there are none, *Error
+// with code ErrorCodeNotFound is returned
+func (w *Wallet) GetTransactionsByHash(hash string) ([]*types.Transaction, error) {
+	txns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+	if len(txns) == 0 {
+		return nil, newError(ErrorCodeNotFound, "Transaction with hash %s not found", hash)
+	}
+	return txns, nil
 }
 
 // GetBalance returns current wallet balance. More precisely, it returns two BTC
@@ -0,0 +1,43 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+func TestGetTransactionsByHash(t *testing.T) {
+	const hash = "7d4ab6c3bda8e5fa1d41bbff1e1e4e48ab16f5c6a4ba2dd57ab80a28e6b40e8b"
+	w := &Wallet{storage: NewStorage(nil)}
+
+	// internal transfer produces outgoing and incoming tx with same hash
+	for _, direction := range []types.TransactionDirection{
+		types.OutgoingDirection,
+		types.IncomingDirection,
+	} {
+		_, err := w.storage.StoreTransaction(&types.Transaction{
+			ID:        uuid.Must(uuid.NewV4()),
+			Hash:      hash,
+			Direction: direction,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	txns, err := w.GetTransactionsByHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(txns), 2; got != want {
+		t.Errorf("Expected %d txns with hash %s, got %d", want, hash, got)
+	}
+
+	_, err = w.GetTransactionsByHash("unknown")
+	if e, ok := err.(*Error); !ok || e.Code != ErrorCodeNotFound {
+		t.Errorf("Expected %s error for unknown hash, got %v",
+			ErrorCodeNotFound, err)
+	}
+}
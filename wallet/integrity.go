@@ -24,6 +24,12 @@ func (w *Wallet) Check() {
 		panic(err)
 	}
 
+	data, ok := walletOperation["tx"]
+	if !ok {
+		// batch withdrawal
+		data = walletOperation["txns"]
+	}
+
 	log.Fatalf(
 		"FATAL: refusing to start processing because it was interrupted "+
 			"during wallet operation and left in an inconsistent state. "+
@@ -32,6 +38,6 @@ func (w *Wallet) Check() {
 			"The following request can be executed in processing DB to let it "+
 			"start again: "+
 			"\"DELETE FROM metadata WHERE key = 'wallet_operation'\"",
-		walletOperation["operation"], walletOperation["tx"],
+		walletOperation["operation"], data,
 	)
 }
@@ -0,0 +1,349 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// InvoiceStatus is a enum describing how much money invoice has received and
+// when
+type InvoiceStatus int
+
+const (
+	// UnpaidInvoice is a status of invoice that has not received any money
+	// yet
+	UnpaidInvoice InvoiceStatus = iota
+
+	// PartiallyPaidInvoice is a status of invoice that has received less than
+	// expected amount and has not expired yet
+	PartiallyPaidInvoice
+
+	// PaidInvoice is a status of invoice that has received exactly expected
+	// amount before it expired
+	PaidInvoice
+
+	// OverpaidInvoice is a status of invoice that has received more than
+	// expected amount, with expected amount received before it expired
+	OverpaidInvoice
+
+	// ExpiredInvoice is a status of invoice that has not received expected
+	// amount before it expired
+	ExpiredInvoice
+
+	// PaidLateInvoice is a status of expired invoice that has received
+	// expected amount after it expired
+	PaidLateInvoice
+
+	// InvalidInvoiceStatus is a status value generated when converting status
+	// from other type and value of source type is invalid
+	InvalidInvoiceStatus
+)
+
+var invoiceStatusToStringMap = map[InvoiceStatus]string{
+	UnpaidInvoice:        "unpaid",
+	PartiallyPaidInvoice: "partially-paid",
+	PaidInvoice:          "paid",
+	OverpaidInvoice:      "overpaid",
+	ExpiredInvoice:       "expired",
+	PaidLateInvoice:      "paid-late",
+}
+
+var stringToInvoiceStatusMap = make(map[string]InvoiceStatus)
+
+var invoiceStatusToEventTypeMap = map[InvoiceStatus]events.EventType{
+	PartiallyPaidInvoice: events.InvoicePartiallyPaidEvent,
+	PaidInvoice:          events.InvoicePaidEvent,
+	OverpaidInvoice:      events.InvoiceOverpaidEvent,
+	ExpiredInvoice:       events.InvoiceExpiredEvent,
+	PaidLateInvoice:      events.InvoicePaidLateEvent,
+}
+
+var errNoInvoiceWithSuchID = errors.New("Invoice with such id is not in db")
+
+func (s InvoiceStatus) String() string {
+	str, ok := invoiceStatusToStringMap[s]
+	if !ok {
+		return "invalid"
+	}
+	return str
+}
+
+// InvoiceStatusFromString converts string representation of InvoiceStatus to
+// enum value
+func InvoiceStatusFromString(str string) (InvoiceStatus, error) {
+	s, ok := stringToInvoiceStatusMap[str]
+	if !ok {
+		return InvalidInvoiceStatus, errors.New("Invalid invoice status: " + str)
+	}
+	return s, nil
+}
+
+// MarshalJSON serializes InvoiceStatus to a JSON value. Resulting value is
+// simply a string representation of status
+func (s InvoiceStatus) MarshalJSON() ([]byte, error) {
+	return []byte("\"" + s.String() + "\""), nil
+}
+
+// UnmarshalJSON deserializes InvoiceStatus from JSON. Resulting value is
+// mapped from string representation of status
+func (s *InvoiceStatus) UnmarshalJSON(b []byte) error {
+	var j string
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+	*s, err = InvoiceStatusFromString(j)
+	return err
+}
+
+// Invoice is a request to pay expected amount to dedicated address before
+// given time. Incoming txns to this address are aggregated against invoice:
+// Received is a sum of their amounts. Only fully confirmed txns are counted,
+// tx is considered to be paid in time if it was first seen before invoice
+// expired. URI is a BIP 21 payment URI for expected amount, it is not stored
+type Invoice struct {
+	ID        uuid.UUID              `json:"id"`
+	Address   string                 `json:"address"`
+	URI       string                 `json:"uri,omitempty"`
+	Amount    bitcoin.BTCAmount      `json:"amount"`
+	Received  bitcoin.BTCAmount      `json:"received"`
+	Status    InvoiceStatus          `json:"status"`
+	Metainfo  map[string]interface{} `json:"metainfo"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	CreatedBy string                 `json:"created_by,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// CreateInvoiceRequest is a structure with parameters of new invoice. ID is
+// optional, it is generated if not set. Metainfo is attached both to invoice
+// and to account created for its address. CreatedBy is not sent by client: it
+// is set by API server to id of API key that requested invoice
+type CreateInvoiceRequest struct {
+	ID        uuid.UUID              `json:"id,omitempty"`
+	Amount    bitcoin.BTCAmount      `json:"amount"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Metainfo  map[string]interface{} `json:"metainfo"`
+	CreatedBy string                 `json:"-"`
+}
+
+func init() {
+	for status, str := range invoiceStatusToStringMap {
+		stringToInvoiceStatusMap[str] = status
+	}
+	for _, eventType := range invoiceStatusToEventTypeMap {
+		events.RegisterNotificationUnmarshaler(eventType, func(b []byte) (interface{}, error) {
+			var invoice Invoice
+
+			err := json.Unmarshal(b, &invoice)
+			return &invoice, err
+		})
+	}
+}
+
+func (i *Invoice) setPaymentURI() {
+	i.URI = paymentURI(i.Address, i.Amount, i.Metainfo)
+}
+
+// invoiceStatus computes status of invoice and amount it has received at
+// given moment from incoming txns to its address. Invoice that has expired,
+// but has unconfirmed txns seen in time, is not considered expired until they
+// are confirmed
+func invoiceStatus(invoice *Invoice, txns []*types.Transaction, now time.Time) (InvoiceStatus, bitcoin.BTCAmount) {
+	var inTime, late bitcoin.BTCAmount
+	pendingInTime := false
+
+	for _, tx := range txns {
+		seenInTime := !tx.CreatedAt.After(invoice.ExpiresAt)
+		switch tx.Status {
+		case types.FullyConfirmedTransaction:
+			if seenInTime {
+				inTime += tx.Amount
+			} else {
+				late += tx.Amount
+			}
+		case types.NewTransaction, types.ConfirmedTransaction:
+			pendingInTime = pendingInTime || seenInTime
+		}
+	}
+	received := inTime + late
+
+	switch {
+	case inTime >= invoice.Amount && received > invoice.Amount:
+		return OverpaidInvoice, received
+	case inTime >= invoice.Amount:
+		return PaidInvoice, received
+	case now.Before(invoice.ExpiresAt) || pendingInTime:
+		if received > 0 {
+			return PartiallyPaidInvoice, received
+		}
+		return UnpaidInvoice, received
+	case received >= invoice.Amount:
+		return PaidLateInvoice, received
+	default:
+		return ExpiredInvoice, received
+	}
+}
+
+// updateInvoice recomputes status and received amount of invoice, stores
+// them and notifies client if status has changed
+func (w *Wallet) updateInvoice(invoice *Invoice) error {
+	txns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		Direction: types.IncomingDirection.String(),
+		Address:   invoice.Address,
+	})
+	if err != nil {
+		return err
+	}
+	status, received := invoiceStatus(invoice, txns, time.Now())
+	if status == invoice.Status && received == invoice.Received {
+		return nil
+	}
+	statusChanged := status != invoice.Status
+	if statusChanged {
+		log.Printf(
+			"Invoice %s for %s to %s is %s: received %s",
+			invoice.ID,
+			invoice.Amount,
+			invoice.Address,
+			status,
+			received,
+		)
+	}
+	invoice.Status = status
+	invoice.Received = received
+	if err = w.storage.updateInvoice(invoice); err != nil {
+		return err
+	}
+	eventType, ok := invoiceStatusToEventTypeMap[status]
+	if !statusChanged || !ok {
+		return nil
+	}
+	invoice.setPaymentURI()
+	return w.eventBroker.Notify(eventType, *invoice)
+}
+
+// updateInvoiceOfAddress updates invoice given address belongs to, if any.
+// It is called when incoming tx to this address changes
+func (w *Wallet) updateInvoiceOfAddress(address string) error {
+	invoice, err := w.storage.GetInvoiceByAddress(address)
+	if err != nil || invoice == nil {
+		return err
+	}
+	return w.updateInvoice(invoice)
+}
+
+// checkExpiredInvoices updates invoices that have expired without being paid.
+// It is called by wallet updater goroutine on each iteration
+func (w *Wallet) checkExpiredInvoices() {
+	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		invoices, err := currWallet.storage.GetExpiredUnpaidInvoices(time.Now())
+		if err != nil {
+			return err
+		}
+		for _, invoice := range invoices {
+			if err = currWallet.updateInvoice(invoice); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("wallet: error: failed to check expired invoices: %v", err)
+		return
+	}
+	w.eventBroker.SendNotifications()
+}
+
+// CreateInvoice creates new invoice: generates new address for it, stores
+// account with this address (txns to it get invoice metainfo) and the
+// invoice itself. Invoice expects given amount to be paid to this address
+// before ExpiresAt, its status is then updated as money arrives and client is
+// notified with invoice-* events
+func (w *Wallet) CreateInvoice(request *CreateInvoiceRequest) (*Invoice, error) {
+	if request.Amount == 0 {
+		return nil, newError(ErrorCodeInvalidRequest, "Invoice amount should be positive")
+	}
+	if !request.ExpiresAt.After(time.Now()) {
+		return nil, newError(
+			ErrorCodeInvalidRequest,
+			"Invoice expiration time %s is in the past",
+			request.ExpiresAt,
+		)
+	}
+	if request.ID == uuid.Nil {
+		request.ID = uuid.Must(uuid.NewV4())
+	} else {
+		existing, err := w.storage.GetInvoiceByID(request.ID)
+		if err != nil && err != errNoInvoiceWithSuchID {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, newError(ErrorCodeDuplicateID, "Invoice with id %s already exists", request.ID)
+		}
+	}
+
+	address, addressType, err := w.generateNewAddress(bitcoin.InvalidAddressType)
+	if err != nil {
+		return nil, err
+	}
+	now := currentTimestamp()
+	invoice := &Invoice{
+		ID:        request.ID,
+		Address:   address,
+		Amount:    request.Amount,
+		Status:    UnpaidInvoice,
+		Metainfo:  request.Metainfo,
+		ExpiresAt: request.ExpiresAt.UTC(),
+		CreatedBy: request.CreatedBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	invoice.setPaymentURI()
+
+	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		err := currWallet.storage.StoreAccount(&Account{
+			Address:     address,
+			AddressType: addressType,
+			Metainfo:    request.Metainfo,
+		})
+		if err != nil {
+			return err
+		}
+		return currWallet.storage.StoreInvoice(invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf(
+		"Created invoice %s for %s to %s, expires at %s",
+		invoice.ID,
+		invoice.Amount,
+		invoice.Address,
+		invoice.ExpiresAt,
+	)
+	return invoice, nil
+}
+
+// GetInvoice fetches invoice with given id from storage. If there is no such
+// invoice, *Error with code ErrorCodeNotFound is returned
+func (w *Wallet) GetInvoice(id uuid.UUID) (*Invoice, error) {
+	invoice, err := w.storage.GetInvoiceByID(id)
+	if err == errNoInvoiceWithSuchID {
+		return nil, newError(ErrorCodeNotFound, "Invoice with id %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	invoice.setPaymentURI()
+	return invoice, nil
+}
@@ -0,0 +1,289 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+func TestInvoiceStatus(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(-time.Hour)
+	inTime := expiresAt.Add(-time.Minute)
+	late := expiresAt.Add(time.Minute)
+
+	payment := func(amount string, status types.TransactionStatus, createdAt time.Time) *types.Transaction {
+		return &types.Transaction{
+			Amount:    bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(amount)),
+			Status:    status,
+			CreatedAt: createdAt,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		txns         []*types.Transaction
+		wantStatus   InvoiceStatus
+		wantReceived string
+	}{
+		{"unpaid", inTime, nil, UnpaidInvoice, "0"},
+		{
+			"unconfirmed payment is not counted",
+			inTime,
+			[]*types.Transaction{payment("0.1", types.NewTransaction, inTime)},
+			UnpaidInvoice,
+			"0",
+		},
+		{
+			"partially paid",
+			inTime,
+			[]*types.Transaction{payment("0.04", types.FullyConfirmedTransaction, inTime)},
+			PartiallyPaidInvoice,
+			"0.04",
+		},
+		{
+			"paid by several txns",
+			inTime,
+			[]*types.Transaction{
+				payment("0.04", types.FullyConfirmedTransaction, inTime),
+				payment("0.06", types.FullyConfirmedTransaction, inTime),
+			},
+			PaidInvoice,
+			"0.1",
+		},
+		{
+			"overpaid",
+			inTime,
+			[]*types.Transaction{payment("0.11", types.FullyConfirmedTransaction, inTime)},
+			OverpaidInvoice,
+			"0.11",
+		},
+		{
+			"late payment on top of paid invoice",
+			now,
+			[]*types.Transaction{
+				payment("0.1", types.FullyConfirmedTransaction, inTime),
+				payment("0.01", types.FullyConfirmedTransaction, late),
+			},
+			OverpaidInvoice,
+			"0.11",
+		},
+		{"expired", now, nil, ExpiredInvoice, "0"},
+		{
+			"expired partially paid",
+			now,
+			[]*types.Transaction{payment("0.04", types.FullyConfirmedTransaction, inTime)},
+			ExpiredInvoice,
+			"0.04",
+		},
+		{
+			"payment seen in time is waited for",
+			now,
+			[]*types.Transaction{payment("0.1", types.ConfirmedTransaction, inTime)},
+			UnpaidInvoice,
+			"0",
+		},
+		{
+			"payment seen after expiration is not waited for",
+			now,
+			[]*types.Transaction{payment("0.1", types.ConfirmedTransaction, late)},
+			ExpiredInvoice,
+			"0",
+		},
+		{
+			"paid late",
+			now,
+			[]*types.Transaction{
+				payment("0.04", types.FullyConfirmedTransaction, inTime),
+				payment("0.06", types.FullyConfirmedTransaction, late),
+			},
+			PaidLateInvoice,
+			"0.1",
+		},
+		{
+			"double-spent payment is not counted",
+			inTime,
+			[]*types.Transaction{payment("0.1", types.DoubleSpentTransaction, inTime)},
+			UnpaidInvoice,
+			"0",
+		},
+	}
+	for _, test := range tests {
+		invoice := &Invoice{
+			Amount:    bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+			ExpiresAt: expiresAt,
+		}
+		status, received := invoiceStatus(invoice, test.txns, test.now)
+		if status != test.wantStatus {
+			t.Errorf("%s: expected status %s, got %s", test.name, test.wantStatus, status)
+		}
+		if want := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(test.wantReceived)); received != want {
+			t.Errorf("%s: expected received amount %s, got %s", test.name, want, received)
+		}
+	}
+}
+
+func TestCreateInvoiceChecks(t *testing.T) {
+	w := NewWallet(&settingstestutil.SettingsMock{}, &nodeAPIBalanceAndAddressMock{}, &loggingEventBrokerMock{}, NewStorage(nil))
+	amount := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1"))
+	expiresAt := time.Now().Add(time.Hour)
+
+	if _, err := w.CreateInvoice(&CreateInvoiceRequest{ID: testTxID, Amount: amount, ExpiresAt: expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		request  *CreateInvoiceRequest
+		wantCode ErrorCode
+	}{
+		{
+			"zero amount",
+			&CreateInvoiceRequest{ExpiresAt: expiresAt},
+			ErrorCodeInvalidRequest,
+		},
+		{
+			"expiration in the past",
+			&CreateInvoiceRequest{Amount: amount, ExpiresAt: time.Now().Add(-time.Minute)},
+			ErrorCodeInvalidRequest,
+		},
+		{
+			"duplicate id",
+			&CreateInvoiceRequest{ID: testTxID, Amount: amount, ExpiresAt: expiresAt},
+			ErrorCodeDuplicateID,
+		},
+	}
+	for _, test := range tests {
+		_, err := w.CreateInvoice(test.request)
+		walletErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("CreateInvoice with %s: expected *Error, got %v", test.name, err)
+			continue
+		}
+		if walletErr.Code != test.wantCode {
+			t.Errorf("CreateInvoice with %s: expected error code %s, got %s",
+				test.name, test.wantCode, walletErr.Code)
+		}
+	}
+
+	_, err := w.GetInvoice(uuid.Must(uuid.NewV4()))
+	if walletErr, ok := err.(*Error); !ok || walletErr.Code != ErrorCodeNotFound {
+		t.Errorf("Expected GetInvoice of unknown id to fail with not-found error, got %v", err)
+	}
+}
+
+func invoiceEvents(log []*events.Notification) []*events.Notification {
+	var result []*events.Notification
+
+	for _, event := range log {
+		if _, ok := event.Data.(Invoice); ok {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+func TestInvoicePayment(t *testing.T) {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations": 1,
+		},
+	}
+	broker := &loggingEventBrokerMock{}
+	w := NewWallet(s, &nodeAPIBalanceAndAddressMock{}, broker, NewStorage(nil))
+
+	invoice, err := w.CreateInvoice(&CreateInvoiceRequest{
+		Amount:    bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Metainfo:  map[string]interface{}{"order_id": 42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Status != UnpaidInvoice || invoice.Address != testAddress {
+		t.Fatalf("Expected new unpaid invoice to %s, got %s invoice to %s",
+			testAddress, invoice.Status, invoice.Address)
+	}
+	if got, want := invoice.URI, "bitcoin:"+testAddress+"?amount=0.1"; got != want {
+		t.Errorf("Expected invoice payment URI %s, got %s", want, got)
+	}
+	account, err := w.GetAccount(invoice.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Metainfo["order_id"] != 42 {
+		t.Errorf("Expected account of invoice to have its metainfo, got %v", account.Metainfo)
+	}
+
+	pay := func(hash, amount string) {
+		deposit := newTestDeposit(hash, types.NewTransaction)
+		deposit.Amount = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(amount))
+		deposit.Confirmations = 1
+		if _, err := w.updateTxInfo(deposit, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertInvoice := func(status InvoiceStatus, received string, eventType events.EventType) {
+		stored, err := w.GetInvoice(invoice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(received))
+		if stored.Status != status || stored.Received != want {
+			t.Errorf("Expected invoice to be %s with %s received, got %s with %s",
+				status, want, stored.Status, stored.Received)
+		}
+		got := invoiceEvents(broker.log)
+		if len(got) != 1 || got[0].Type != eventType {
+			t.Errorf("Expected single %s event, got %v", eventType, got)
+		}
+		broker.flushEvents()
+	}
+
+	pay(testBatchTxHash, "0.04")
+	assertInvoice(PartiallyPaidInvoice, "0.04", events.InvoicePartiallyPaidEvent)
+
+	pay(testAutoBatchTxHash, "0.07")
+	assertInvoice(OverpaidInvoice, "0.11", events.InvoiceOverpaidEvent)
+}
+
+func TestInvoiceExpiration(t *testing.T) {
+	broker := &loggingEventBrokerMock{}
+	w := NewWallet(&settingstestutil.SettingsMock{}, &nodeAPIBalanceAndAddressMock{}, broker, NewStorage(nil))
+
+	invoice, err := w.CreateInvoice(&CreateInvoiceRequest{
+		Amount:    bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.checkExpiredInvoices()
+	if len(broker.log) != 0 {
+		t.Fatalf("Expected no events before invoice expired, got %d", len(broker.log))
+	}
+
+	invoice.ExpiresAt = time.Now().Add(-time.Minute)
+	w.checkExpiredInvoices()
+	if got, want := len(broker.log), 1; got != want {
+		t.Fatalf("Expected %d event after invoice expired, got %d", want, got)
+	}
+	if got, want := broker.log[0].Type, events.InvoiceExpiredEvent; got != want {
+		t.Errorf("Expected event %s, got %s", want, got)
+	}
+
+	// expired invoice is reported once
+	broker.flushEvents()
+	w.checkExpiredInvoices()
+	if len(broker.log) != 0 {
+		t.Errorf("Expected no events after invoice was reported expired, got %d", len(broker.log))
+	}
+}
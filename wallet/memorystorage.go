@@ -6,6 +6,8 @@ import (
 	"errors"
 	"log"
 	"runtime/debug"
+	"sort"
+	"time"
 
 	"github.com/gofrs/uuid"
 
@@ -33,7 +35,9 @@ var errNoTxWithSuchTxHashAndDirection = errors.New(
 type InMemoryWalletStorage struct {
 	lastSeenBlockHash            string
 	accounts                     []*Account
+	accountMetainfoUpdates       []*AccountMetainfoUpdate
 	transactions                 []*types.Transaction
+	invoices                     []*Invoice
 	hotWalletAddress             string
 	moneyRequiredFromColdStorage uint64
 	walletOperationLock          string
@@ -139,6 +143,7 @@ func (s *InMemoryWalletStorage) StoreTransaction(transaction *types.Transaction)
 
 	if !txIsNew {
 		existingTransaction.Update(transaction)
+		existingTransaction.UpdatedAt = currentTimestamp()
 		return existingTransaction, nil
 	}
 
@@ -154,6 +159,10 @@ func (s *InMemoryWalletStorage) StoreTransaction(transaction *types.Transaction)
 		}
 		transaction.ID = uuid.Must(uuid.NewV4())
 	}
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = currentTimestamp()
+	}
+	transaction.UpdatedAt = transaction.CreatedAt
 	s.transactions = append(s.transactions, transaction)
 	return transaction, nil
 }
@@ -168,6 +177,42 @@ func (s *InMemoryWalletStorage) GetAccountByAddress(address string) (*Account, e
 	return nil, nil
 }
 
+// GetAccountsWithFilter gets accounts matching filter sorted by address. See
+// AccountsFilter for description of filter and pagination parameters
+func (s *InMemoryWalletStorage) GetAccountsWithFilter(filter *AccountsFilter) ([]*Account, error) {
+	result := make([]*Account, 0)
+
+	for _, account := range s.accounts {
+		if filter.matches(account) {
+			result = append(result, account)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+// updateAccountMetainfo sets new metainfo of account and fills old metainfo
+// in update record, which is stored for audit
+func (s *InMemoryWalletStorage) updateAccountMetainfo(update *AccountMetainfoUpdate) error {
+	account, err := s.GetAccountByAddress(update.Address)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return errNoAccountWithSuchAddress
+	}
+	update.OldMetainfo = account.Metainfo
+	account.Metainfo = update.Metainfo
+	s.accountMetainfoUpdates = append(s.accountMetainfoUpdates, update)
+	return nil
+}
+
 // StoreAccount stores Account information. No checks, including checks for
 // address duplication, are performed
 func (s *InMemoryWalletStorage) StoreAccount(account *Account) error {
@@ -175,6 +220,62 @@ func (s *InMemoryWalletStorage) StoreAccount(account *Account) error {
 	return nil
 }
 
+// StoreInvoice stores new invoice
+func (s *InMemoryWalletStorage) StoreInvoice(invoice *Invoice) error {
+	s.invoices = append(s.invoices, invoice)
+	return nil
+}
+
+// GetInvoiceByID fetches invoice with given id
+func (s *InMemoryWalletStorage) GetInvoiceByID(id uuid.UUID) (*Invoice, error) {
+	for _, invoice := range s.invoices {
+		if invoice.ID == id {
+			return invoice, nil
+		}
+	}
+	return nil, errNoInvoiceWithSuchID
+}
+
+// GetInvoiceByAddress fetches invoice that has given address. If there is no
+// such invoice, nil is returned without error
+func (s *InMemoryWalletStorage) GetInvoiceByAddress(address string) (*Invoice, error) {
+	for _, invoice := range s.invoices {
+		if invoice.Address == address {
+			return invoice, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetExpiredUnpaidInvoices returns invoices with status 'unpaid' or
+// 'partially-paid' which ExpiresAt time is not later than now
+func (s *InMemoryWalletStorage) GetExpiredUnpaidInvoices(now time.Time) ([]*Invoice, error) {
+	result := make([]*Invoice, 0)
+
+	for _, invoice := range s.invoices {
+		if invoice.Status != UnpaidInvoice && invoice.Status != PartiallyPaidInvoice {
+			continue
+		}
+		if !invoice.ExpiresAt.After(now) {
+			result = append(result, invoice)
+		}
+	}
+	return result, nil
+}
+
+// updateInvoice sets new status and received amount of invoice
+func (s *InMemoryWalletStorage) updateInvoice(invoice *Invoice) error {
+	storedInvoice, err := s.GetInvoiceByID(invoice.ID)
+	if err != nil {
+		return err
+	}
+	invoice.UpdatedAt = currentTimestamp()
+	storedInvoice.Status = invoice.Status
+	storedInvoice.Received = invoice.Received
+	storedInvoice.UpdatedAt = invoice.UpdatedAt
+	return nil
+}
+
 // GetBroadcastedTransactionsWithLessConfirmations returns txns which are
 // already broadcasted to Bitcoin network (have corresponding Bitcoin tx), but
 // still have less than given number of confirmations. This method is used by
@@ -189,7 +290,7 @@ func (s *InMemoryWalletStorage) GetBroadcastedTransactionsWithLessConfirmations(
 		if transaction.Hash == "" {
 			continue
 		}
-		if transaction.ReportedConfirmations < confirmations {
+		if transaction.Confirmations < confirmations {
 			result = append(result, transaction)
 		}
 	}
@@ -207,6 +308,54 @@ func (s *InMemoryWalletStorage) updateReportedConfirmations(transaction *types.T
 	return nil
 }
 
+func (s *InMemoryWalletStorage) updateApprovals(transaction *types.Transaction, approvals []types.Approval) error {
+	storedTransaction, err := s.GetTransactionByID(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	storedTransaction.Approvals = approvals
+	storedTransaction.UpdatedAt = currentTimestamp()
+	transaction.Approvals = approvals
+	transaction.UpdatedAt = storedTransaction.UpdatedAt
+	return nil
+}
+
+// updateFee stores fee of tx, which changes when fee rate of withdrawal with
+// 'smart' fee type is estimated again before it is sent
+func (s *InMemoryWalletStorage) updateFee(transaction *types.Transaction) error {
+	storedTransaction, err := s.GetTransactionByID(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	storedTransaction.Fee = transaction.Fee
+	storedTransaction.UpdatedAt = currentTimestamp()
+	transaction.UpdatedAt = storedTransaction.UpdatedAt
+	return nil
+}
+
+// updateReplacement stores hash, replaced hashes and their fees, fee and
+// status of tx after its bitcoin tx was replaced
+func (s *InMemoryWalletStorage) updateReplacement(transaction *types.Transaction) error {
+	storedTransaction, err := s.GetTransactionByID(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	storedTransaction.Hash = transaction.Hash
+	storedTransaction.ReplacedHashes = append([]string(nil), transaction.ReplacedHashes...)
+	storedTransaction.ReplacedFees = append([]types.ReplacedFee(nil), transaction.ReplacedFees...)
+	storedTransaction.Fee = transaction.Fee
+	storedTransaction.FeeType = transaction.FeeType
+	storedTransaction.BlockHash = transaction.BlockHash
+	storedTransaction.Confirmations = transaction.Confirmations
+	storedTransaction.Status = transaction.Status
+	storedTransaction.UpdatedAt = currentTimestamp()
+	transaction.UpdatedAt = storedTransaction.UpdatedAt
+	return nil
+}
+
 // GetHotWalletAddress returns hot wallet address - string value set by
 // SetHotWalletAddress
 func (s *InMemoryWalletStorage) GetHotWalletAddress() (string, error) {
@@ -243,25 +392,61 @@ func (s *InMemoryWalletStorage) GetPendingTransactions() ([]*types.Transaction,
 	return result, nil
 }
 
-// GetTransactionsWithFilter gets txns filtered by direction and/or status.
-// Empty values of filters mean do not use this filter, with non-empty filter
-// only txns that have equal value of corresponding parameter will be included
-// in resulting slice
-func (s *InMemoryWalletStorage) GetTransactionsWithFilter(directionFilter string, statusFilter string) ([]*types.Transaction, error) {
+// GetDueScheduledTransactions returns withdrawals with status 'scheduled'
+// which ExecuteAfter time is not later than now
+func (s *InMemoryWalletStorage) GetDueScheduledTransactions(now time.Time) ([]*types.Transaction, error) {
 	result := make([]*types.Transaction, 0)
 
 	for _, transaction := range s.transactions {
-		if directionFilter != "" && directionFilter != transaction.Direction.String() {
+		if transaction.Status != types.ScheduledTransaction {
 			continue
 		}
+		if transaction.ExecuteAfter == nil || !transaction.ExecuteAfter.After(now) {
+			result = append(result, transaction)
+		}
+	}
+
+	return result, nil
+}
 
-		if statusFilter != "" && statusFilter != transaction.Status.String() {
+// GetExpiredTransactions returns withdrawals with status 'scheduled',
+// 'pending', 'pending-cold-storage' or 'pending-manual-confirmation' which
+// ExpiresAt time is not later than now
+func (s *InMemoryWalletStorage) GetExpiredTransactions(now time.Time) ([]*types.Transaction, error) {
+	result := make([]*types.Transaction, 0)
+
+	for _, transaction := range s.transactions {
+		if !isExpirableStatus(transaction.Status) || transaction.ExpiresAt == nil {
 			continue
 		}
+		if !transaction.ExpiresAt.After(now) {
+			result = append(result, transaction)
+		}
+	}
+
+	return result, nil
+}
+
+// GetTransactionsWithFilter gets txns matching filter sorted by creation time.
+// See TransactionsFilter for description of filter and pagination parameters
+func (s *InMemoryWalletStorage) GetTransactionsWithFilter(filter *TransactionsFilter) ([]*types.Transaction, error) {
+	result := make([]*types.Transaction, 0)
 
-		result = append(result, transaction)
+	for _, transaction := range s.transactions {
+		if filter.matches(transaction) {
+			result = append(result, transaction)
+		}
 	}
 
+	sort.Slice(result, func(i, j int) bool {
+		if filter.Order == SortDescending {
+			i, j = j, i
+		}
+		return cursorOfTransaction(result[i]).less(cursorOfTransaction(result[j]))
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
 	return result, nil
 }
 
@@ -15,6 +15,14 @@ func init() {
 		events.OutgoingTxConfirmedEvent,
 		events.PendingStatusUpdatedEvent,
 		events.PendingTxCancelledEvent,
+		events.WithdrawalApprovedEvent,
+		events.WithdrawalFeeBumpedEvent,
+		events.IncomingTxReorgedEvent,
+		events.OutgoingTxReorgedEvent,
+		events.IncomingTxConflictedEvent,
+		events.OutgoingTxConflictedEvent,
+		events.WithdrawalDroppedEvent,
+		events.WithdrawalReplacementRevertedEvent,
 	}
 	for _, et := range txEvents {
 		events.RegisterNotificationUnmarshaler(et, func(b []byte) (interface{}, error) {
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// Limits of page size in GetTransactions and GetAccounts. Page size is limited
+// so that listing of all transactions or accounts can't overload DB and API
+// server
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// SortOrder is an order of txns in GetTransactions result. Txns are sorted by
+// creation time (and then by id to make order stable)
+type SortOrder string
+
+// Possible sort orders. Empty value means ascending
+const (
+	SortAscending  SortOrder = "asc"
+	SortDescending SortOrder = "desc"
+)
+
+// TransactionsCursor is a position in list of txns sorted by creation time.
+// It is passed to API clients as an opaque string
+type TransactionsCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func cursorOfTransaction(tx *types.Transaction) *TransactionsCursor {
+	return &TransactionsCursor{CreatedAt: tx.CreatedAt, ID: tx.ID}
+}
+
+func (c *TransactionsCursor) String() string {
+	cursorJSON, err := json.Marshal(c)
+	if err != nil {
+		panic("Failed to marshal transactions cursor: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+// ParseTransactionsCursor decodes cursor returned by TransactionsCursor.String
+func ParseTransactionsCursor(cursor string) (*TransactionsCursor, error) {
+	var result TransactionsCursor
+
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(cursorJSON, &result)
+	}
+	if err != nil {
+		return nil, newError(ErrorCodeInvalidRequest, "Invalid cursor %q", cursor)
+	}
+	return &result, nil
+}
+
+// less tells whether position c comes before other in ascending order
+func (c *TransactionsCursor) less(other *TransactionsCursor) bool {
+	if !c.CreatedAt.Equal(other.CreatedAt) {
+		return c.CreatedAt.Before(other.CreatedAt)
+	}
+	return bytes.Compare(c.ID[:], other.ID[:]) < 0
+}
+
+// currentTimestamp returns current time with precision DB is able to store,
+// so that cursors made from txns before and after storing them are equal
+func currentTimestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// checkPageSize validates requested page size. Zero means default size
+func checkPageSize(limit int) (int, error) {
+	switch {
+	case limit == 0:
+		return DefaultPageSize, nil
+	case limit < 0 || limit > MaxPageSize:
+		return 0, newError(ErrorCodeInvalidRequest,
+			"Invalid limit %d, should be between 1 and %d", limit, MaxPageSize)
+	}
+	return limit, nil
+}
+
+// GetTransactions fetches one page of txns matching filter. cursor is a
+// position of last tx on previous page, empty value means get first page.
+// Besides txns, cursor for next page is returned, it is empty if there are no
+// more txns. If filter.Limit is 0, DefaultPageSize is used
+func (w *Wallet) GetTransactions(filterParams TransactionsFilter, cursor string) ([]*types.Transaction, string, error) {
+	filter := &filterParams
+
+	switch filter.Order {
+	case "", SortAscending, SortDescending:
+	default:
+		return nil, "", newError(ErrorCodeInvalidRequest,
+			"Invalid sort order %q, should be %q or %q", filter.Order,
+			SortAscending, SortDescending)
+	}
+	pageSize, err := checkPageSize(filter.Limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if cursor != "" {
+		after, err := ParseTransactionsCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		filter.After = after
+	}
+
+	// one more tx is requested to find out if there is next page
+	filter.Limit = pageSize + 1
+	txns, err := w.storage.GetTransactionsWithFilter(filter)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(txns) <= pageSize {
+		return txns, "", nil
+	}
+	txns = txns[:pageSize]
+	return txns, cursorOfTransaction(txns[pageSize-1]).String(), nil
+}
@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+func TestGetTransactionsPagination(t *testing.T) {
+	w := &Wallet{storage: NewStorage(nil)}
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var ids []uuid.UUID
+	for i := 0; i < 5; i++ {
+		tx := &types.Transaction{
+			ID:        uuid.Must(uuid.NewV4()),
+			Direction: types.IncomingDirection,
+			Status:    types.NewTransaction,
+			// two txns with same creation time check that id breaks tie
+			CreatedAt: created.Add(time.Duration(i/2) * time.Second),
+		}
+		if _, err := w.storage.StoreTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.ID)
+	}
+	w.storage.StoreTransaction(&types.Transaction{
+		ID:        uuid.Must(uuid.NewV4()),
+		Direction: types.OutgoingDirection,
+		Status:    types.NewTransaction,
+	})
+
+	fetchAll := func(order SortOrder) []uuid.UUID {
+		var result []uuid.UUID
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > len(ids) {
+				t.Fatalf("Pagination in %s order does not stop", order)
+			}
+			txns, nextCursor, err := w.GetTransactions(TransactionsFilter{
+				Direction: types.IncomingDirection.String(),
+				Order:     order,
+				Limit:     2,
+			}, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txns) > 2 {
+				t.Fatalf("Expected at most 2 txns on page, got %d", len(txns))
+			}
+			for _, tx := range txns {
+				result = append(result, tx.ID)
+			}
+			if nextCursor == "" {
+				return result
+			}
+			cursor = nextCursor
+		}
+	}
+
+	ascending := fetchAll(SortAscending)
+	descending := fetchAll(SortDescending)
+	if len(ascending) != len(ids) || len(descending) != len(ids) {
+		t.Fatalf("Expected to get %d txns, got %d in ascending and %d in "+
+			"descending order", len(ids), len(ascending), len(descending))
+	}
+	seen := make(map[uuid.UUID]bool)
+	for i, id := range ascending {
+		if seen[id] {
+			t.Errorf("Tx %s returned twice", id)
+		}
+		seen[id] = true
+		if descending[len(descending)-1-i] != id {
+			t.Errorf("Descending order is not reverse of ascending: %v %v",
+				ascending, descending)
+			break
+		}
+	}
+	if ascending[0] != ids[0] && ascending[0] != ids[1] {
+		t.Errorf("Expected oldest tx to be first, got %s", ascending[0])
+	}
+}
+
+func TestGetTransactionsInvalidParameters(t *testing.T) {
+	w := &Wallet{storage: NewStorage(nil)}
+
+	tests := []struct {
+		order  SortOrder
+		limit  int
+		cursor string
+	}{
+		{order: "random"},
+		{limit: -1},
+		{limit: MaxPageSize + 1},
+		{cursor: "not a cursor"},
+	}
+	for _, test := range tests {
+		_, _, err := w.GetTransactions(TransactionsFilter{
+			Order: test.order,
+			Limit: test.limit,
+		}, test.cursor)
+		if e, ok := err.(*Error); !ok || e.Code != ErrorCodeInvalidRequest {
+			t.Errorf("Expected %s error for %+v, got %v",
+				ErrorCodeInvalidRequest, test, err)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package wallet
 
 import (
-	"errors"
-	"fmt"
 	"log"
 	"sort"
 	"time"
@@ -19,7 +17,18 @@ type internalTxIDRequest struct {
 }
 
 type internalCancelRequest internalTxIDRequest
-type internalConfirmRequest internalTxIDRequest
+
+type internalConfirmRequest struct {
+	internalTxIDRequest
+	approver string
+}
+
+// ErrSelfApproval is returned when withdrawal is confirmed by the same party
+// (API key) that requested it
+var ErrSelfApproval = &Error{
+	Code:    ErrorCodeSelfApproval,
+	Message: "Withdrawal can't be confirmed by the same API key that requested it",
+}
 
 func (w *Wallet) updatePendingTxStatus(tx *types.Transaction, status types.TransactionStatus) error {
 	if status == tx.Status {
@@ -60,14 +69,56 @@ func (w *Wallet) updatePendingTxns() {
 	w.eventBroker.SendNotifications()
 }
 
+// pendingWithdrawal is a group of pending txns that are sent together: a
+// single withdrawal or all entries of a batch withdrawal. Amount is money
+// needed to send them, including fee if it is paid by sender
+type pendingWithdrawal struct {
+	txns   []*types.Transaction
+	amount int64
+}
+
+func (p *pendingWithdrawal) updateStatus(w *Wallet, status types.TransactionStatus) error {
+	for _, tx := range p.txns {
+		if err := w.updatePendingTxStatus(tx, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupPendingTxns groups entries of batch withdrawals together and sorts
+// resulting withdrawals by amount
+func groupPendingTxns(pendingTxns []*types.Transaction) []*pendingWithdrawal {
+	var withdrawals []*pendingWithdrawal
+	batches := make(map[uuid.UUID]*pendingWithdrawal)
+
+	for _, tx := range pendingTxns {
+		var withdrawal *pendingWithdrawal
+		if tx.BatchID != nil {
+			withdrawal = batches[*tx.BatchID]
+		}
+		if withdrawal == nil {
+			withdrawal = &pendingWithdrawal{}
+			withdrawals = append(withdrawals, withdrawal)
+			if tx.BatchID != nil {
+				batches[*tx.BatchID] = withdrawal
+			}
+		}
+		withdrawal.txns = append(withdrawal.txns, tx)
+		withdrawal.amount += withdrawalCost(tx)
+	}
+	sort.SliceStable(withdrawals, func(i, j int) bool {
+		return withdrawals[i].amount < withdrawals[j].amount
+	})
+	return withdrawals
+}
+
 func (w *Wallet) tryToUpdatePendingTxns() error {
 	pendingTxns, err := w.storage.GetPendingTransactions()
 	if err != nil {
 		return err
 	}
-	sort.Slice(pendingTxns, func(i, j int) bool {
-		return pendingTxns[i].Amount < pendingTxns[j].Amount
-	})
+	pendingWithdrawals := groupPendingTxns(pendingTxns)
 
 	confBal, unconfBal, err := w.nodeAPI.GetConfirmedAndUnconfirmedBalance()
 	if err != nil {
@@ -75,41 +126,77 @@ func (w *Wallet) tryToUpdatePendingTxns() error {
 	}
 	availableBalance := int64(confBal)
 
-	var amount int64
+	// withdrawals that can be batched are collected and sent together
+	// instead of being sent one by one
+	var autoBatch []*types.Transaction
+
 	exceedingTx := -1
-	for i, tx := range pendingTxns {
-		amount = int64(tx.Amount)
-		if availableBalance-amount >= 0 {
-			availableBalance -= amount
-			log.Printf("There is now enough money to send tx %v, resending", tx)
-			err = w.sendWithdrawal(tx, false)
+	for i, withdrawal := range pendingWithdrawals {
+		if availableBalance-withdrawal.amount >= 0 {
+			availableBalance -= withdrawal.amount
+			tx := withdrawal.txns[0]
+			if tx.BatchID != nil {
+				log.Printf("There is now enough money to send batch %s, "+
+					"resending", tx.BatchID)
+				err = w.sendBatchWithdrawal(withdrawal.txns, false)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			log.Printf("There is now enough money to send tx %v, "+
+				"resending", tx)
+			batchable, err := w.isBatchable(tx)
 			if err != nil {
 				return err
 			}
+			if batchable {
+				autoBatch = append(autoBatch, tx)
+				continue
+			}
+			if err = w.sendWithdrawal(tx, false); err != nil {
+				return err
+			}
 		} else {
 			exceedingTx = i
 			break
 		}
 	}
 
+	if len(autoBatch) > 0 {
+		if err = w.sendAutoBatches(autoBatch, false); err != nil {
+			return err
+		}
+	}
+
+	// metric and event are updated only when new required amount is
+	// committed
+	var change *RequiredFromColdStorageChange
+
 	if exceedingTx == -1 {
-		return w.storage.SetMoneyRequiredFromColdStorage(0)
+		err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+			change, err = currWallet.setMoneyRequiredFromColdStorage(0)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return w.reportRequiredFromColdStorageChange(change)
 	}
 
-	return w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
 		if unconfBal > 0 {
 			// we did not have enough money to fund all pending txns, but we
 			// have some unconfirmed balance, maybe we'll be able to fund some
 			// pending txns when this balance is confirmed
-			pendingTxns = pendingTxns[exceedingTx:]
+			pendingWithdrawals = pendingWithdrawals[exceedingTx:]
 			exceedingTx = -1
 			availableBalance += int64(unconfBal)
-			for i, tx := range pendingTxns {
-				amount = int64(tx.Amount)
-				if availableBalance-amount >= 0 {
-					availableBalance -= amount
-					err = currWallet.updatePendingTxStatus(
-						tx,
+			for i, withdrawal := range pendingWithdrawals {
+				if availableBalance-withdrawal.amount >= 0 {
+					availableBalance -= withdrawal.amount
+					err = withdrawal.updateStatus(
+						currWallet,
 						types.PendingTransaction,
 					)
 					if err != nil {
@@ -123,48 +210,81 @@ func (w *Wallet) tryToUpdatePendingTxns() error {
 		}
 
 		if exceedingTx == -1 {
-			return currWallet.storage.SetMoneyRequiredFromColdStorage(0)
+			change, err = currWallet.setMoneyRequiredFromColdStorage(0)
+			return err
 		}
 
-		for _, tx := range pendingTxns[exceedingTx:] {
-			availableBalance -= int64(tx.Amount)
-			err = currWallet.updatePendingTxStatus(
-				tx,
+		for _, withdrawal := range pendingWithdrawals[exceedingTx:] {
+			availableBalance -= withdrawal.amount
+			err = withdrawal.updateStatus(
+				currWallet,
 				types.PendingColdStorageTransaction,
 			)
 			if err != nil {
 				return err
 			}
 		}
-		return currWallet.storage.SetMoneyRequiredFromColdStorage(
+		change, err = currWallet.setMoneyRequiredFromColdStorage(
 			uint64(-availableBalance),
 		)
+		return err
 	})
+	if err != nil {
+		return err
+	}
+	return w.reportRequiredFromColdStorageChange(change)
 }
 
 func (w *Wallet) cancelPendingTx(id uuid.UUID) error {
+	cancelledManualConfirmation := 0
+
 	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
-		tx, err := currWallet.storage.GetTransactionByID(id)
+		tx, err := currWallet.GetTransactionByID(id)
+		if err != nil {
+			return err
+		}
+
+		// entries of batch withdrawal are cancelled together
+		txns, err := currWallet.withdrawalTransactions(tx)
 		if err != nil {
 			return err
 		}
 
-		switch tx.Status {
-		case types.PendingTransaction:
-		case types.PendingColdStorageTransaction:
-		case types.PendingManualConfirmationTransaction:
-		default:
-			return errors.New("Transaction is not pending")
+		for _, tx := range txns {
+			switch tx.Status {
+			case types.PendingTransaction:
+			case types.PendingColdStorageTransaction:
+			case types.PendingManualConfirmationTransaction:
+			case types.QueuedTransaction:
+			case types.ScheduledTransaction:
+			default:
+				return newError(
+					ErrorCodeNotPending,
+					"Tx %s is not pending. Its status is %s",
+					tx.ID,
+					tx.Status,
+				)
+			}
 		}
 
-		return currWallet.updatePendingTxStatus(tx, types.CancelledTransaction)
+		cancelledManualConfirmation = 0
+		for _, tx := range txns {
+			if tx.Status == types.PendingManualConfirmationTransaction {
+				cancelledManualConfirmation++
+			}
+			err = currWallet.updatePendingTxStatus(tx, types.CancelledTransaction)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 
 	if err != nil {
 		return err
 	}
 
-	w.txnsWaitingManualConfirmationCount.Dec()
+	w.txnsWaitingManualConfirmationCount.Sub(float64(cancelledManualConfirmation))
 
 	w.updatePendingTxns()
 	w.eventBroker.SendNotifications()
@@ -172,14 +292,14 @@ func (w *Wallet) cancelPendingTx(id uuid.UUID) error {
 	return nil
 }
 
-func (w *Wallet) confirmPendingTx(id uuid.UUID) error {
+func (w *Wallet) confirmPendingTx(id uuid.UUID, approver string) error {
 	var (
 		tx  *types.Transaction
 		err error
 	)
 
 	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
-		tx, err = currWallet.storage.GetTransactionByID(id)
+		tx, err = currWallet.GetTransactionByID(id)
 		return err
 	})
 
@@ -188,20 +308,52 @@ func (w *Wallet) confirmPendingTx(id uuid.UUID) error {
 	}
 
 	if tx.Status != types.PendingManualConfirmationTransaction {
-		return fmt.Errorf(
+		return newError(
+			ErrorCodeNotPending,
 			"Tx %s is not pending manual confirmation. Its status is %s",
 			id,
 			tx.Status,
 		)
 	}
 
-	err = w.sendWithdrawal(tx, true)
+	if approver != "" && approver == tx.CreatedBy {
+		log.Printf(
+			"Refusing to confirm tx %s: approver %s is the one who requested it",
+			id,
+			approver,
+		)
+		return ErrSelfApproval
+	}
+
+	txns, err := w.withdrawalTransactions(tx)
+	if err != nil {
+		return err
+	}
+
+	quorumReached, err := w.addApproval(txns, approver)
+	if err != nil {
+		return err
+	}
+
+	if !quorumReached {
+		w.eventBroker.SendNotifications()
+		return nil
+	}
+
+	switch {
+	case tx.BatchID != nil:
+		err = w.sendBatchWithdrawal(txns, true)
+	case isScheduledForLater(tx):
+		err = w.scheduleWithdrawal(tx)
+	default:
+		err = w.sendWithdrawal(tx, true)
+	}
 
 	if err != nil {
 		return err
 	}
 
-	w.txnsWaitingManualConfirmationCount.Dec()
+	w.txnsWaitingManualConfirmationCount.Sub(float64(len(txns)))
 	return nil
 }
 
@@ -211,8 +363,8 @@ func (w *Wallet) confirmPendingTx(id uuid.UUID) error {
 // To prevent races, actual cancellation will be done in wallet updater
 // goroutine (in private method cancelPendingTx).
 // It is an error if tx was not pending (had status other than 'pending',
-// 'pending-cold-storage' or 'pending-manual-confirmation'). In this case tx
-// status is not updated and erorr is returned.
+// 'pending-cold-storage', 'pending-manual-confirmation', 'queued' or
+// 'scheduled'). In this case tx status is not updated and erorr is returned.
 // In reality cancelling tx that already was broadcasted to Bitcoin network does
 // not make much sence - since other peers have already seen a signature for
 // such tx, they can re-broadcast it and mine it to blockchain even if original
@@ -226,8 +378,11 @@ func (w *Wallet) CancelPendingTx(id uuid.UUID) error {
 	return <-resultCh
 }
 
-// ConfirmPendingTransaction effectively sends tx which status was
-// 'pending-manual-confirmation' given its id. Tx can become 'new' if there is
+// ConfirmPendingTransaction records approval of tx which status is
+// 'pending-manual-confirmation' given its id. When tx collects required number
+// of approvals from distinct approvers (see "wallet.approval_tiers" in config),
+// it is effectively sent (or scheduled if its ExecuteAfter time has not come
+// yet). Tx can become 'new' if there is
 // enough confirmed wallet balance to fund it right now or 'pending' if not
 // (and can afterwards become 'pending-cold-storage' if with unconfirmed balance
 // there is still not enough money).
@@ -235,11 +390,20 @@ func (w *Wallet) CancelPendingTx(id uuid.UUID) error {
 // 'pending-manual-confirmation', in this case nothing is done and error is
 // returned. To prevent races, actual work will be done in wallet updater
 // goroutine (in private method confirmPendingTx).
-func (w *Wallet) ConfirmPendingTransaction(id uuid.UUID) error {
+// Argument approver identifies who confirms the tx (id of API key). It is
+// an error (ErrSelfApproval) if tx was requested by the same approver or
+// (ErrDuplicateApproval) if this approver has already confirmed it. Empty
+// approver means identity is unknown (API authentication is disabled): self
+// approval check is skipped, but all such approvals count as one approver, so
+// tx can collect only one anonymous approval.
+func (w *Wallet) ConfirmPendingTransaction(id uuid.UUID, approver string) error {
 	resultCh := make(chan error)
 	w.confirmQueue <- internalConfirmRequest{
-		id:     id,
-		result: resultCh,
+		internalTxIDRequest: internalTxIDRequest{
+			id:     id,
+			result: resultCh,
+		},
+		approver: approver,
 	}
 	return <-resultCh
 }
@@ -0,0 +1,234 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+func TestConfirmPendingTxCollectsApprovals(t *testing.T) {
+	var (
+		amount    = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("2"))
+		tierStart = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1"))
+	)
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations": 1,
+		},
+	}
+	n := &nodeAPIBalanceAndAddressMock{}
+	e := &loggingEventBrokerMock{}
+	ws := NewStorage(nil)
+	w := NewWallet(s, n, e, ws)
+	w.approvalTiers = []ApprovalTier{{Amount: tierStart, Approvals: 2}}
+
+	acct, _ := w.CreateAccount(nil, bitcoin.InvalidAddressType)
+
+	tx := &types.Transaction{
+		ID:                    testTxID,
+		Address:               acct.Address,
+		Direction:             types.OutgoingDirection,
+		Amount:                amount,
+		FeeType:               bitcoin.FixedFee,
+		Fresh:                 true,
+		ReportedConfirmations: -1,
+		CreatedBy:             "requester",
+	}
+	if err := w.holdWithdrawalUntilConfirmed(tx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tx.RequiredApprovals, 2; got != want {
+		t.Fatalf("Expected tx to require %d approvals, got %d", want, got)
+	}
+
+	if err := w.confirmPendingTx(testTxID, "requester"); err != ErrSelfApproval {
+		t.Errorf("Expected self-approval to fail with %v, got %v",
+			ErrSelfApproval, err)
+	}
+
+	e.flushEvents()
+	if err := w.confirmPendingTx(testTxID, "first-approver"); err != nil {
+		t.Fatal(err)
+	}
+	e.assertExpectedEvents(t, []*events.Notification{
+		&events.Notification{
+			Type: events.WithdrawalApprovedEvent,
+			Data: types.TxNotification{
+				Transaction: types.Transaction{
+					ID:        testTxID,
+					Address:   testAddress,
+					Amount:    amount,
+					FeeType:   bitcoin.FixedFee,
+					Direction: types.OutgoingDirection,
+					Status:    types.PendingManualConfirmationTransaction,
+				},
+			},
+		},
+	})
+
+	if err := w.confirmPendingTx(testTxID, "first-approver"); err != ErrDuplicateApproval {
+		t.Errorf("Expected repeated approval to fail with %v, got %v",
+			ErrDuplicateApproval, err)
+	}
+
+	storedTx, err := w.storage.GetTransactionByID(testTxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storedTx.Status, types.PendingManualConfirmationTransaction; got != want {
+		t.Fatalf("Expected tx with one of two approvals to have status %s, "+
+			"got %s", want, got)
+	}
+
+	e.flushEvents()
+	if err := w.confirmPendingTx(testTxID, "second-approver"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(e.log), 5; got != want {
+		t.Fatalf("Expected approval and internal withdrawal to generate %d "+
+			"events, got %d", want, got)
+	}
+	if got, want := e.log[0].Type, events.WithdrawalApprovedEvent; got != want {
+		t.Errorf("Expected first event to be %s, got %s", want, got)
+	}
+	if got, want := len(storedTx.Approvals), 2; got != want {
+		t.Errorf("Expected tx to have %d approvals, got %d", want, got)
+	}
+	if got, want := storedTx.Status, types.FullyConfirmedTransaction; got != want {
+		t.Errorf("Expected approved tx to have status %s, got %s", want, got)
+	}
+}
+
+type nodeAPIFeePayerMock struct {
+	nodeAPISendManyMock
+
+	recipientPaysFee []bool
+}
+
+func (n *nodeAPIFeePayerMock) SendWithFixedFee(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (string, error) {
+	if !recipientPaysFee {
+		amount += fee
+	}
+	if uint64(amount) > n.balance {
+		return "", &nodeapi.JSONRPCError{Code: -6, Message: "Insufficient funds"}
+	}
+	n.recipientPaysFee = append(n.recipientPaysFee, recipientPaysFee)
+	return testBatchTxHash, nil
+}
+
+func TestWithdrawalCost(t *testing.T) {
+	tests := []struct {
+		feeType  bitcoin.FeeType
+		feePayer bitcoin.FeePayer
+		want     string
+	}{
+		{bitcoin.FixedFee, bitcoin.RecipientPaysFee, "1"},
+		{bitcoin.FixedFee, bitcoin.SenderPaysFee, "1.0002"},
+		{bitcoin.PerKBRateFee, bitcoin.RecipientPaysFee, "1"},
+		{bitcoin.PerKBRateFee, bitcoin.SenderPaysFee, "1.00005"},
+		{bitcoin.SmartFee, bitcoin.SenderPaysFee, "1.00005"},
+	}
+	for _, test := range tests {
+		tx := &types.Transaction{
+			Amount:   bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")),
+			Fee:      bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0002")),
+			FeeType:  test.feeType,
+			FeePayer: test.feePayer,
+		}
+		want := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(test.want))
+		if got := bitcoin.BTCAmount(withdrawalCost(tx)); got != want {
+			t.Errorf("Expected cost of withdrawal with fee type %s paid by %s "+
+				"to be %s, got %s", test.feeType, test.feePayer, want, got)
+		}
+	}
+}
+
+func TestPendingWithdrawalPaidBySender(t *testing.T) {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations": 1,
+		},
+	}
+	n := &nodeAPIFeePayerMock{}
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")))
+	w := NewWallet(s, n, &loggingEventBrokerMock{}, NewStorage(nil))
+
+	tx := &types.Transaction{
+		ID:                    testTxID,
+		Address:               testAddress,
+		Direction:             types.OutgoingDirection,
+		Status:                types.PendingTransaction,
+		Amount:                bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")),
+		Fee:                   bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.01")),
+		FeeType:               bitcoin.FixedFee,
+		FeePayer:              bitcoin.SenderPaysFee,
+		Fresh:                 true,
+		ReportedConfirmations: -1,
+	}
+	if _, err := w.storage.StoreTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// balance covers amount, but not amount and fee
+	if err := w.tryToUpdatePendingTxns(); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.recipientPaysFee) != 0 {
+		t.Fatalf("Expected withdrawal not to be sent without money for fee")
+	}
+	assertTxStatus(t, w, tx, types.PendingColdStorageTransaction, "")
+	required, err := w.storage.GetMoneyRequiredFromColdStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bitcoin.BTCAmount(required), tx.Fee; got != want {
+		t.Errorf("Expected %s to be required from cold storage, got %s",
+			want, got)
+	}
+
+	n.balance += uint64(tx.Fee)
+	if err := w.tryToUpdatePendingTxns(); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.recipientPaysFee) != 1 || n.recipientPaysFee[0] {
+		t.Fatalf("Expected withdrawal to be sent with fee paid by sender, "+
+			"got %v", n.recipientPaysFee)
+	}
+	assertTxStatus(t, w, tx, types.NewTransaction, testBatchTxHash)
+}
+
+func TestConfirmPendingTxAllowsOneAnonymousApproval(t *testing.T) {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations": 1,
+		},
+	}
+	w := NewWallet(s, &nodeAPIBalanceAndAddressMock{}, &loggingEventBrokerMock{}, NewStorage(nil))
+	w.approvalTiers = []ApprovalTier{{Amount: 1, Approvals: 2}}
+
+	tx := &types.Transaction{
+		ID:                    testTxID,
+		Address:               testAddress,
+		Direction:             types.OutgoingDirection,
+		Amount:                bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("2")),
+		FeeType:               bitcoin.FixedFee,
+		Fresh:                 true,
+		ReportedConfirmations: -1,
+	}
+	if err := w.holdWithdrawalUntilConfirmed(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.confirmPendingTx(testTxID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.confirmPendingTx(testTxID, ""); err != ErrDuplicateApproval {
+		t.Errorf("Expected second anonymous approval to fail with %v, got %v",
+			ErrDuplicateApproval, err)
+	}
+	assertTxStatus(t, w, tx, types.PendingManualConfirmationTransaction, "")
+}
@@ -8,6 +8,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 
@@ -40,8 +41,23 @@ const transactionFields string = `
 	metainfo,
 	fee,
 	fee_type,
+	fee_payer,
+	conf_target,
+	estimate_mode,
 	cold_storage,
-	reported_confirmations
+	reported_confirmations,
+	created_by,
+	required_approvals,
+	approvals,
+	batch_id,
+	replaced_hashes,
+	replaced_fees,
+	wallet_conflicts,
+	parent_id,
+	execute_after,
+	expires_at,
+	created_at,
+	updated_at
 `
 
 func newPostgresWalletStorage(db *sql.DB) *PostgresWalletStorage {
@@ -74,14 +90,29 @@ func (s *PostgresWalletStorage) SetLastSeenBlockHash(hash string) error {
 	return s.setMeta("last_seen_block_hash", hash)
 }
 
+func utcTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
 func transactionFromDatabaseRow(row queryResult) (*types.Transaction, error) {
 	var id uuid.UUID
-	var hash, blockHash, address, direction, status, feeType string
-	var metainfoJSON *string
+	var hash, blockHash, address, direction, status, feeType, feePayer, estimateMode, createdBy string
+	var metainfoJSON, approvalsJSON, replacedHashesJSON, replacedFeesJSON, walletConflictsJSON *string
 	var confirmations, reportedConfirmations int64
+	var confTarget, requiredApprovals int
+	var approvals []types.Approval
+	var replacedHashes, walletConflicts []string
+	var replacedFees []types.ReplacedFee
 	var amount, fee uint64
 	var metainfo interface{}
 	var coldStorage bool
+	var batchID, parentID uuid.NullUUID
+	var createdAt, updatedAt time.Time
+	var executeAfter, expiresAt *time.Time
 
 	err := row.Scan(
 		&id,
@@ -95,8 +126,23 @@ func transactionFromDatabaseRow(row queryResult) (*types.Transaction, error) {
 		&metainfoJSON,
 		&fee,
 		&feeType,
+		&feePayer,
+		&confTarget,
+		&estimateMode,
 		&coldStorage,
 		&reportedConfirmations,
+		&createdBy,
+		&requiredApprovals,
+		&approvalsJSON,
+		&batchID,
+		&replacedHashesJSON,
+		&replacedFeesJSON,
+		&walletConflictsJSON,
+		&parentID,
+		&executeAfter,
+		&expiresAt,
+		&createdAt,
+		&updatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -111,6 +157,7 @@ func transactionFromDatabaseRow(row queryResult) (*types.Transaction, error) {
 		return nil, err
 	}
 	transactionFeeType, _ := bitcoin.FeeTypeFromString(feeType)
+	transactionFeePayer, _ := bitcoin.FeePayerFromString(feePayer)
 	if metainfoJSON != nil {
 		err = json.Unmarshal([]byte(*metainfoJSON), &metainfo)
 		if err != nil {
@@ -119,6 +166,30 @@ func transactionFromDatabaseRow(row queryResult) (*types.Transaction, error) {
 	} else {
 		metainfo = nil
 	}
+	if approvalsJSON != nil {
+		err = json.Unmarshal([]byte(*approvalsJSON), &approvals)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if replacedHashesJSON != nil {
+		err = json.Unmarshal([]byte(*replacedHashesJSON), &replacedHashes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if replacedFeesJSON != nil {
+		err = json.Unmarshal([]byte(*replacedFeesJSON), &replacedFees)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if walletConflictsJSON != nil {
+		err = json.Unmarshal([]byte(*walletConflictsJSON), &walletConflicts)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	tx := &types.Transaction{
 		ID:                    id,
@@ -132,9 +203,28 @@ func transactionFromDatabaseRow(row queryResult) (*types.Transaction, error) {
 		Metainfo:              metainfo,
 		Fee:                   bitcoin.BTCAmount(fee),
 		FeeType:               transactionFeeType,
+		FeePayer:              transactionFeePayer,
+		ConfTarget:            confTarget,
+		EstimateMode:          estimateMode,
 		ColdStorage:           coldStorage,
 		Fresh:                 false,
 		ReportedConfirmations: reportedConfirmations,
+		CreatedBy:             createdBy,
+		RequiredApprovals:     requiredApprovals,
+		Approvals:             approvals,
+		ReplacedHashes:        replacedHashes,
+		ReplacedFees:          replacedFees,
+		WalletConflicts:       walletConflicts,
+		ExecuteAfter:          utcTimePtr(executeAfter),
+		ExpiresAt:             utcTimePtr(expiresAt),
+		CreatedAt:             createdAt.UTC(),
+		UpdatedAt:             updatedAt.UTC(),
+	}
+	if batchID.Valid {
+		tx.BatchID = &batchID.UUID
+	}
+	if parentID.Valid {
+		tx.ParentID = &parentID.UUID
 	}
 	return tx, nil
 }
@@ -223,12 +313,20 @@ func (s *PostgresWalletStorage) StoreTransaction(transaction *types.Transaction)
 	}
 
 	if !txIsNew {
-		_, err := s.db.Exec(`UPDATE transactions SET hash = $1, block_hash = $2,
-			confirmations = $3, status = $4 WHERE id = $5`,
+		updatedAt := currentTimestamp()
+		walletConflictsJSON, err := json.Marshal(transaction.WalletConflicts)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.db.Exec(`UPDATE transactions SET hash = $1, block_hash = $2,
+			confirmations = $3, status = $4, wallet_conflicts = $5,
+			updated_at = $6 WHERE id = $7`,
 			transaction.Hash,
 			transaction.BlockHash,
 			transaction.Confirmations,
 			transaction.Status.String(),
+			string(walletConflictsJSON),
+			updatedAt,
 			existingTransaction.ID,
 		)
 		if err != nil {
@@ -236,6 +334,7 @@ func (s *PostgresWalletStorage) StoreTransaction(transaction *types.Transaction)
 				err, transaction)
 		}
 		existingTransaction.Update(transaction)
+		existingTransaction.UpdatedAt = updatedAt
 		return existingTransaction, nil
 	}
 
@@ -251,12 +350,34 @@ func (s *PostgresWalletStorage) StoreTransaction(transaction *types.Transaction)
 		}
 		transaction.ID = uuid.Must(uuid.NewV4())
 	}
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = currentTimestamp()
+	}
+	transaction.UpdatedAt = transaction.CreatedAt
 	metainfoJSON, err := json.Marshal(transaction.Metainfo)
 	if err != nil {
 		return nil, err
 	}
+	approvalsJSON, err := json.Marshal(transaction.Approvals)
+	if err != nil {
+		return nil, err
+	}
+	replacedHashesJSON, err := json.Marshal(transaction.ReplacedHashes)
+	if err != nil {
+		return nil, err
+	}
+	replacedFeesJSON, err := json.Marshal(transaction.ReplacedFees)
+	if err != nil {
+		return nil, err
+	}
+	walletConflictsJSON, err := json.Marshal(transaction.WalletConflicts)
+	if err != nil {
+		return nil, err
+	}
 	query := fmt.Sprintf(`INSERT INTO transactions (%s)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13,
+			$14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26,
+			$27, $28)`,
 		transactionFields,
 	)
 	_, err = s.db.Exec(
@@ -272,8 +393,23 @@ func (s *PostgresWalletStorage) StoreTransaction(transaction *types.Transaction)
 		string(metainfoJSON),
 		transaction.Fee,
 		transaction.FeeType.String(),
+		transaction.FeePayer.String(),
+		transaction.ConfTarget,
+		transaction.EstimateMode,
 		transaction.ColdStorage,
 		transaction.ReportedConfirmations,
+		transaction.CreatedBy,
+		transaction.RequiredApprovals,
+		string(approvalsJSON),
+		transaction.BatchID,
+		string(replacedHashesJSON),
+		string(replacedFeesJSON),
+		string(walletConflictsJSON),
+		transaction.ParentID,
+		transaction.ExecuteAfter,
+		transaction.ExpiresAt,
+		transaction.CreatedAt,
+		transaction.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to insert new tx into DB: %s. Tx %#v",
@@ -282,15 +418,24 @@ func (s *PostgresWalletStorage) StoreTransaction(transaction *types.Transaction)
 	return transaction, nil
 }
 
+// accountAddressTypeString converts account address type to a value stored
+// in DB: unknown type is stored as empty string
+func accountAddressTypeString(addressType bitcoin.AddressType) string {
+	if addressType == bitcoin.InvalidAddressType {
+		return ""
+	}
+	return addressType.String()
+}
+
 // GetAccountByAddress fetches account metainfo corresponding to given address
 // and returns resulting Account structure
 func (s *PostgresWalletStorage) GetAccountByAddress(address string) (*Account, error) {
-	var marshaledMetainfo string
+	var marshaledMetainfo, addressType string
 	var metainfo map[string]interface{}
 	err := s.db.QueryRow(
-		"SELECT metainfo FROM accounts WHERE address = $1",
+		"SELECT address_type, metainfo FROM accounts WHERE address = $1",
 		address,
-	).Scan(&marshaledMetainfo)
+	).Scan(&addressType, &marshaledMetainfo)
 
 	switch err {
 	case nil:
@@ -303,28 +448,124 @@ func (s *PostgresWalletStorage) GetAccountByAddress(address string) (*Account, e
 	if err != nil {
 		return nil, err
 	}
+	accountAddressType, _ := bitcoin.AddressTypeFromString(addressType)
 	account := &Account{
-		Address:  address,
-		Metainfo: metainfo,
+		Address:     address,
+		AddressType: accountAddressType,
+		Metainfo:    metainfo,
 	}
 	return account, nil
 }
 
 // StoreAccount stores a new account record with account address (which is
-// a private key) and metainfo
+// a private key), its type and metainfo
 func (s *PostgresWalletStorage) StoreAccount(account *Account) error {
 	marshaledMetainfo, err := json.Marshal(account.Metainfo)
 	if err != nil {
 		return err
 	}
 	_, err = s.db.Exec(
-		`INSERT INTO accounts (address, metainfo) VALUES ($1, $2)`,
+		`INSERT INTO accounts (address, address_type, metainfo) VALUES ($1, $2, $3)`,
 		account.Address,
+		accountAddressTypeString(account.AddressType),
 		marshaledMetainfo,
 	)
 	return err
 }
 
+// GetAccountsWithFilter gets accounts matching filter sorted by address. See
+// AccountsFilter for description of filter and pagination parameters
+func (s *PostgresWalletStorage) GetAccountsWithFilter(filter *AccountsFilter) ([]*Account, error) {
+	query := "SELECT address, address_type, metainfo FROM accounts"
+	queryArgs := make([]interface{}, 0, 2)
+	whereClause := make([]string, 0, 2)
+	result := make([]*Account, 0, 20)
+
+	if filter.Metainfo != nil {
+		metainfoJSON, err := json.Marshal(filter.Metainfo)
+		if err != nil {
+			return result, err
+		}
+		queryArgs = append(queryArgs, string(metainfoJSON))
+		whereClause = append(whereClause, fmt.Sprintf("metainfo @> $%d", len(queryArgs)))
+	}
+	if filter.After != "" {
+		queryArgs = append(queryArgs, filter.After)
+		whereClause = append(whereClause, fmt.Sprintf("address > $%d", len(queryArgs)))
+	}
+	if len(whereClause) > 0 {
+		query += " WHERE " + strings.Join(whereClause, " AND ")
+	}
+	query += " ORDER BY address"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	rows, err := s.db.Query(query, queryArgs...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var account Account
+		var marshaledMetainfo, addressType string
+
+		if err = rows.Scan(&account.Address, &addressType, &marshaledMetainfo); err != nil {
+			return result, err
+		}
+		account.AddressType, _ = bitcoin.AddressTypeFromString(addressType)
+		if err = json.Unmarshal([]byte(marshaledMetainfo), &account.Metainfo); err != nil {
+			return result, err
+		}
+		result = append(result, &account)
+	}
+	return result, rows.Err()
+}
+
+// updateAccountMetainfo sets new metainfo of account and stores update record
+// in account_metainfo_updates table for audit. Old metainfo is filled in
+// update record
+func (s *PostgresWalletStorage) updateAccountMetainfo(update *AccountMetainfoUpdate) error {
+	var oldMetainfoJSON string
+
+	err := s.db.QueryRow(
+		"SELECT metainfo FROM accounts WHERE address = $1 FOR UPDATE",
+		update.Address,
+	).Scan(&oldMetainfoJSON)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return errNoAccountWithSuchAddress
+	default:
+		return err
+	}
+	if err = json.Unmarshal([]byte(oldMetainfoJSON), &update.OldMetainfo); err != nil {
+		return err
+	}
+	metainfoJSON, err := json.Marshal(update.Metainfo)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		"UPDATE accounts SET metainfo = $1 WHERE address = $2",
+		string(metainfoJSON),
+		update.Address,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO account_metainfo_updates (address, old_metainfo,
+		metainfo, updated_by, updated_at) VALUES ($1, $2, $3, $4, $5)`,
+		update.Address,
+		oldMetainfoJSON,
+		string(metainfoJSON),
+		update.UpdatedBy,
+		update.Time,
+	)
+	return err
+}
+
 // GetBroadcastedTransactionsWithLessConfirmations returns txns which are
 // already broadcasted to Bitcoin network (have corresponding Bitcoin tx), but
 // still have less than given number of confirmations. This method is used by
@@ -368,6 +609,210 @@ func (s *PostgresWalletStorage) updateReportedConfirmations(transaction *types.T
 	return nil
 }
 
+func (s *PostgresWalletStorage) updateApprovals(transaction *types.Transaction, approvals []types.Approval) error {
+	approvalsJSON, err := json.Marshal(approvals)
+	if err != nil {
+		return err
+	}
+	updatedAt := currentTimestamp()
+	_, err = s.db.Exec(
+		`UPDATE transactions SET approvals = $1, updated_at = $2 WHERE id = $3`,
+		string(approvalsJSON),
+		updatedAt,
+		transaction.ID,
+	)
+	if err != nil {
+		return err
+	}
+	transaction.Approvals = approvals
+	transaction.UpdatedAt = updatedAt
+	return nil
+}
+
+// updateFee stores fee of tx, which changes when fee rate of withdrawal with
+// 'smart' fee type is estimated again before it is sent
+func (s *PostgresWalletStorage) updateFee(transaction *types.Transaction) error {
+	updatedAt := currentTimestamp()
+	_, err := s.db.Exec(
+		`UPDATE transactions SET fee = $1, updated_at = $2 WHERE id = $3`,
+		transaction.Fee,
+		updatedAt,
+		transaction.ID,
+	)
+	if err != nil {
+		return err
+	}
+	transaction.UpdatedAt = updatedAt
+	return nil
+}
+
+// updateReplacement stores hash, replaced hashes and their fees, fee and
+// status of tx after its bitcoin tx was replaced
+func (s *PostgresWalletStorage) updateReplacement(transaction *types.Transaction) error {
+	replacedHashesJSON, err := json.Marshal(transaction.ReplacedHashes)
+	if err != nil {
+		return err
+	}
+	replacedFeesJSON, err := json.Marshal(transaction.ReplacedFees)
+	if err != nil {
+		return err
+	}
+	updatedAt := currentTimestamp()
+	_, err = s.db.Exec(
+		`UPDATE transactions SET hash = $1, replaced_hashes = $2,
+		replaced_fees = $3, fee = $4, fee_type = $5, block_hash = $6,
+		confirmations = $7, status = $8, updated_at = $9 WHERE id = $10`,
+		transaction.Hash,
+		string(replacedHashesJSON),
+		string(replacedFeesJSON),
+		transaction.Fee,
+		transaction.FeeType.String(),
+		transaction.BlockHash,
+		transaction.Confirmations,
+		transaction.Status.String(),
+		updatedAt,
+		transaction.ID,
+	)
+	if err != nil {
+		return err
+	}
+	transaction.UpdatedAt = updatedAt
+	return nil
+}
+
+const invoiceFields string = `id, address, amount, received, status,
+	metainfo, expires_at, created_by, created_at, updated_at`
+
+func invoiceFromDatabaseRow(row queryResult) (*Invoice, error) {
+	var invoice Invoice
+	var status string
+	var metainfoJSON *string
+	var createdBy sql.NullString
+
+	err := row.Scan(
+		&invoice.ID,
+		&invoice.Address,
+		&invoice.Amount,
+		&invoice.Received,
+		&status,
+		&metainfoJSON,
+		&invoice.ExpiresAt,
+		&createdBy,
+		&invoice.CreatedAt,
+		&invoice.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	invoice.Status, err = InvoiceStatusFromString(status)
+	if err != nil {
+		return nil, err
+	}
+	if metainfoJSON != nil {
+		err = json.Unmarshal([]byte(*metainfoJSON), &invoice.Metainfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	invoice.CreatedBy = createdBy.String
+	invoice.ExpiresAt = invoice.ExpiresAt.UTC()
+	invoice.CreatedAt = invoice.CreatedAt.UTC()
+	invoice.UpdatedAt = invoice.UpdatedAt.UTC()
+	return &invoice, nil
+}
+
+// StoreInvoice stores new invoice
+func (s *PostgresWalletStorage) StoreInvoice(invoice *Invoice) error {
+	metainfoJSON, err := json.Marshal(invoice.Metainfo)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO invoices (`+invoiceFields+`) VALUES ($1, $2, $3, $4, $5,
+		$6, $7, $8, $9, $10)`,
+		invoice.ID,
+		invoice.Address,
+		invoice.Amount,
+		invoice.Received,
+		invoice.Status.String(),
+		string(metainfoJSON),
+		invoice.ExpiresAt,
+		invoice.CreatedBy,
+		invoice.CreatedAt,
+		invoice.UpdatedAt,
+	)
+	return err
+}
+
+// GetInvoiceByID fetches invoice with given id
+func (s *PostgresWalletStorage) GetInvoiceByID(id uuid.UUID) (*Invoice, error) {
+	invoice, err := invoiceFromDatabaseRow(s.db.QueryRow(
+		`SELECT `+invoiceFields+` FROM invoices WHERE id = $1`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, errNoInvoiceWithSuchID
+	}
+	return invoice, err
+}
+
+// GetInvoiceByAddress fetches invoice that has given address. If there is no
+// such invoice, nil is returned without error
+func (s *PostgresWalletStorage) GetInvoiceByAddress(address string) (*Invoice, error) {
+	invoice, err := invoiceFromDatabaseRow(s.db.QueryRow(
+		`SELECT `+invoiceFields+` FROM invoices WHERE address = $1`,
+		address,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return invoice, err
+}
+
+// GetExpiredUnpaidInvoices returns invoices with status 'unpaid' or
+// 'partially-paid' which ExpiresAt time is not later than now
+func (s *PostgresWalletStorage) GetExpiredUnpaidInvoices(now time.Time) ([]*Invoice, error) {
+	result := make([]*Invoice, 0)
+
+	rows, err := s.db.Query(
+		`SELECT `+invoiceFields+` FROM invoices WHERE status IN ($1, $2) AND
+		expires_at <= $3`,
+		UnpaidInvoice.String(),
+		PartiallyPaidInvoice.String(),
+		now,
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		invoice, err := invoiceFromDatabaseRow(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, invoice)
+	}
+	return result, rows.Err()
+}
+
+// updateInvoice sets new status and received amount of invoice
+func (s *PostgresWalletStorage) updateInvoice(invoice *Invoice) error {
+	updatedAt := currentTimestamp()
+	_, err := s.db.Exec(
+		`UPDATE invoices SET status = $1, received = $2, updated_at = $3
+		WHERE id = $4`,
+		invoice.Status.String(),
+		invoice.Received,
+		updatedAt,
+		invoice.ID,
+	)
+	if err != nil {
+		return err
+	}
+	invoice.UpdatedAt = updatedAt
+	return nil
+}
+
 // GetHotWalletAddress returns hot wallet address - string value set by
 // SetHotWalletAddress.
 func (s *PostgresWalletStorage) GetHotWalletAddress() (string, error) {
@@ -422,6 +867,55 @@ func (s *PostgresWalletStorage) GetPendingTransactions() ([]*types.Transaction,
 	return result, rows.Err()
 }
 
+func (s *PostgresWalletStorage) getTransactions(query string, args ...interface{}) ([]*types.Transaction, error) {
+	result := make([]*types.Transaction, 0, 20)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		transaction, err := transactionFromDatabaseRow(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, transaction)
+	}
+	return result, rows.Err()
+}
+
+// GetDueScheduledTransactions returns withdrawals with status 'scheduled'
+// which ExecuteAfter time is not later than now
+func (s *PostgresWalletStorage) GetDueScheduledTransactions(now time.Time) ([]*types.Transaction, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM transactions WHERE status = $1 AND
+		(execute_after IS NULL OR execute_after <= $2)`,
+		transactionFields,
+	)
+	return s.getTransactions(query, types.ScheduledTransaction.String(), now)
+}
+
+// GetExpiredTransactions returns withdrawals with status 'scheduled',
+// 'pending', 'pending-cold-storage' or 'pending-manual-confirmation' which
+// ExpiresAt time is not later than now
+func (s *PostgresWalletStorage) GetExpiredTransactions(now time.Time) ([]*types.Transaction, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM transactions WHERE status IN ($1, $2, $3, $4) AND
+		expires_at <= $5`,
+		transactionFields,
+	)
+	return s.getTransactions(
+		query,
+		types.ScheduledTransaction.String(),
+		types.PendingTransaction.String(),
+		types.PendingColdStorageTransaction.String(),
+		types.PendingManualConfirmationTransaction.String(),
+		now,
+	)
+}
+
 // GetMoneyRequiredFromColdStorage returns money required to transfer from
 // cold storage - uint64 value set by SetMoneyRequiredFromColdStorage.
 func (s *PostgresWalletStorage) GetMoneyRequiredFromColdStorage() (uint64, error) {
@@ -445,30 +939,95 @@ func (s *PostgresWalletStorage) SetMoneyRequiredFromColdStorage(amount uint64) e
 	)
 }
 
-// GetTransactionsWithFilter gets txns filtered by direction and/or status.
-// Empty values of filters mean do not use this filter, with non-empty filter
-// only txns that have equal value of corresponding parameter will be included
-// in resulting slice
-func (s *PostgresWalletStorage) GetTransactionsWithFilter(directionFilter string, statusFilter string) ([]*types.Transaction, error) {
+// GetTransactionsWithFilter gets txns matching filter sorted by creation time.
+// See TransactionsFilter for description of filter and pagination parameters.
+// Indexes used by these queries are created in tools/init-db.sql
+func (s *PostgresWalletStorage) GetTransactionsWithFilter(filter *TransactionsFilter) ([]*types.Transaction, error) {
 	query := fmt.Sprintf("SELECT %s FROM transactions", transactionFields)
-	queryArgs := make([]interface{}, 0, 2)
-	whereClause := make([]string, 0, 2)
+	queryArgs := make([]interface{}, 0, 4)
+	whereClause := make([]string, 0, 3)
 	argc := 0
 	result := make([]*types.Transaction, 0, 20)
+	order, comparison := "ASC", ">"
 
-	if directionFilter != "" {
+	// addCondition adds condition on a single query argument, %s in
+	// condition is replaced with argument placeholder
+	addCondition := func(condition string, arg interface{}) {
 		argc++
-		whereClause = append(whereClause, fmt.Sprintf("direction = $%d", argc))
-		queryArgs = append(queryArgs, directionFilter)
+		whereClause = append(whereClause, fmt.Sprintf(condition, fmt.Sprintf("$%d", argc)))
+		queryArgs = append(queryArgs, arg)
 	}
-	if statusFilter != "" {
-		argc++
-		whereClause = append(whereClause, fmt.Sprintf("status = $%d", argc))
-		queryArgs = append(queryArgs, statusFilter)
+
+	if filter.Order == SortDescending {
+		order, comparison = "DESC", "<"
+	}
+	if filter.Direction != "" {
+		addCondition("direction = %s", filter.Direction)
+	}
+	if filter.Status != "" {
+		addCondition("status = %s", filter.Status)
+	}
+	if filter.Address != "" {
+		addCondition("address = %s", filter.Address)
+	}
+	if filter.Hash != "" {
+		addCondition("hash = %s", filter.Hash)
+	}
+	if filter.BatchID != uuid.Nil {
+		addCondition("batch_id = %s", filter.BatchID)
+	}
+	if filter.ParentID != uuid.Nil {
+		addCondition("parent_id = %s", filter.ParentID)
+	}
+	if filter.ReplacedHash != "" {
+		replacedHashJSON, err := json.Marshal([]string{filter.ReplacedHash})
+		if err != nil {
+			return result, err
+		}
+		addCondition("replaced_hashes @> %s", string(replacedHashJSON))
+	}
+	if filter.ColdStorage != nil {
+		addCondition("cold_storage = %s", *filter.ColdStorage)
+	}
+	if filter.MinAmount != 0 {
+		addCondition("amount >= %s", uint64(filter.MinAmount))
+	}
+	if filter.MaxAmount != 0 {
+		addCondition("amount <= %s", uint64(filter.MaxAmount))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		addCondition("created_at >= %s", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		addCondition("created_at < %s", filter.CreatedBefore)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		addCondition("updated_at >= %s", filter.UpdatedAfter)
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		addCondition("updated_at < %s", filter.UpdatedBefore)
+	}
+	if filter.Metainfo != nil {
+		metainfoJSON, err := json.Marshal(filter.Metainfo)
+		if err != nil {
+			return result, err
+		}
+		addCondition("metainfo @> %s", string(metainfoJSON))
+	}
+	if filter.After != nil {
+		argc += 2
+		whereClause = append(whereClause, fmt.Sprintf(
+			"(created_at, id) %s ($%d, $%d)", comparison, argc-1, argc,
+		))
+		queryArgs = append(queryArgs, filter.After.CreatedAt, filter.After.ID)
 	}
 	if len(whereClause) > 0 {
 		query += " WHERE " + strings.Join(whereClause, " AND ")
 	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s", order, order)
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
 	rows, err := s.db.Query(query, queryArgs...)
 	if err != nil {
 		return result, err
@@ -0,0 +1,57 @@
+package wallet
+
+import (
+	"log"
+
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/util"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// isReorged tells whether tx that was included in block with given hash is
+// not there anymore: after chain reorganization it either returned to mempool
+// or was mined in another block
+func isReorged(previousBlockHash string, tx *types.Transaction) bool {
+	return previousBlockHash != "" && tx.BlockHash != previousBlockHash
+}
+
+func getReorgNotificationType(tx *types.Transaction) events.EventType {
+	switch tx.Direction {
+	case types.IncomingDirection:
+		return events.IncomingTxReorgedEvent
+	case types.OutgoingDirection:
+		return events.OutgoingTxReorgedEvent
+	default:
+		panic("Unexpected tx direction " + tx.Direction.String())
+	}
+}
+
+// handleReorg is called when block including tx was removed from blockchain.
+// Tx status is already downgraded according to current number of
+// confirmations. Client is notified (unless notify is false, which is the case
+// for internal txns) and reported confirmations are reset so that
+// confirmations tx gains in new chain are reported again
+func (w *Wallet) handleReorg(tx *types.Transaction, previousBlockHash string, notify bool) error {
+	log.Printf(
+		"Chain reorganization: tx %s (%s) is no longer in block %s, now it "+
+			"is in block %q with %d confirmations",
+		tx.Hash,
+		tx.ID,
+		previousBlockHash,
+		tx.BlockHash,
+		tx.Confirmations,
+	)
+
+	if notify {
+		err := w.NotifyTransaction(getReorgNotificationType(tx), *tx)
+		if err != nil {
+			return err
+		}
+	}
+	reportedConfirmations := util.Max64(0, util.Min64(tx.Confirmations, w.maxConfirmations))
+	if err := w.storage.updateReportedConfirmations(tx, reportedConfirmations); err != nil {
+		return err
+	}
+	tx.ReportedConfirmations = reportedConfirmations
+	return nil
+}
@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const (
+	testBlockHash      = "000000000000000000024bead8df69990852c202db0e0097c1a12ea637d7e96d"
+	testOtherBlockHash = "00000000000000000001c8018d9cb3b742ef25114f27563e3fc4a1902167f985"
+)
+
+type nodeAPIReorgMock struct {
+	nodeAPIBalanceAndAddressMock
+
+	tip     string
+	txInfo  btcjson.GetTransactionResult
+	fetched int
+}
+
+func (n *nodeAPIReorgMock) GetBestBlockHash() (string, error) {
+	return n.tip, nil
+}
+
+func (n *nodeAPIReorgMock) GetTransaction(hash string) (*btcjson.GetTransactionResult, error) {
+	n.fetched++
+	result := n.txInfo
+	result.TxID = hash
+	return &result, nil
+}
+
+func TestReorgDetection(t *testing.T) {
+	n := &nodeAPIReorgMock{
+		tip:    testOtherBlockHash,
+		txInfo: btcjson.GetTransactionResult{BlockHash: testBlockHash, Confirmations: 2},
+	}
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations":  2,
+			"transaction.reorg_safety_depth": 3,
+		},
+	}
+	broker := &loggingEventBrokerMock{}
+	w := NewWallet(s, n, broker, NewStorage(nil))
+
+	deposit := &types.Transaction{
+		Hash:                  testBatchTxHash,
+		BlockHash:             testBlockHash,
+		Confirmations:         2,
+		Address:               testAddress,
+		Direction:             types.IncomingDirection,
+		Status:                types.FullyConfirmedTransaction,
+		Amount:                bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+		ReportedConfirmations: 2,
+	}
+	if _, err := w.storage.StoreTransaction(deposit); err != nil {
+		t.Fatal(err)
+	}
+
+	w.checkForExistingTransactionUpdates()
+	if len(broker.log) != 0 {
+		t.Fatalf("Expected no events while tx stays in its block, got %d", len(broker.log))
+	}
+	fetched := n.fetched
+
+	// fully confirmed txns are not rechecked until chain tip changes
+	w.checkForExistingTransactionUpdates()
+	if n.fetched != fetched {
+		t.Errorf("Expected fully confirmed tx not to be rechecked without new block")
+	}
+
+	// block is orphaned and tx returns to mempool
+	n.tip = testBlockHash
+	n.txInfo = btcjson.GetTransactionResult{}
+	w.checkForExistingTransactionUpdates()
+
+	if got, want := len(broker.log), 1; got != want {
+		t.Fatalf("Expected %d event after reorg, got %d", want, got)
+	}
+	if got, want := broker.log[0].Type, events.IncomingTxReorgedEvent; got != want {
+		t.Errorf("Expected event %s after reorg, got %s", want, got)
+	}
+	stored, err := w.storage.GetTransactionByID(deposit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != types.NewTransaction || stored.BlockHash != "" {
+		t.Errorf("Expected reorged tx to become new and have no block, got "+
+			"status %s and block %q", stored.Status, stored.BlockHash)
+	}
+
+	// tx is mined again in another block, confirmations are reported again
+	broker.flushEvents()
+	n.tip = testOtherBlockHash
+	n.txInfo = btcjson.GetTransactionResult{BlockHash: testOtherBlockHash, Confirmations: 2}
+	w.checkForExistingTransactionUpdates()
+
+	if got, want := len(broker.log), 2; got != want {
+		t.Fatalf("Expected %d confirmation events, got %d", want, got)
+	}
+	for _, event := range broker.log {
+		if event.Type != events.IncomingTxConfirmedEvent {
+			t.Errorf("Expected event %s, got %s", events.IncomingTxConfirmedEvent, event.Type)
+		}
+	}
+	if stored.Status != types.FullyConfirmedTransaction {
+		t.Errorf("Expected tx to become fully confirmed again, got %s", stored.Status)
+	}
+}
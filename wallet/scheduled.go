@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"log"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// isExpirableStatus tells whether withdrawal with given status is cancelled
+// when its ExpiresAt time comes
+func isExpirableStatus(status types.TransactionStatus) bool {
+	switch status {
+	case types.ScheduledTransaction:
+	case types.PendingTransaction:
+	case types.PendingColdStorageTransaction:
+	case types.PendingManualConfirmationTransaction:
+	default:
+		return false
+	}
+	return true
+}
+
+// isScheduledForLater tells whether withdrawal should not be sent yet
+func isScheduledForLater(tx *types.Transaction) bool {
+	return tx.ExecuteAfter != nil && tx.ExecuteAfter.After(time.Now())
+}
+
+// scheduleWithdrawal sets status of withdrawal to 'scheduled', it will be
+// processed by processScheduledWithdrawals when its ExecuteAfter time comes
+func (w *Wallet) scheduleWithdrawal(tx *types.Transaction) error {
+	log.Printf(
+		"Withdrawal %s is scheduled to be sent after %s",
+		tx.ID,
+		tx.ExecuteAfter,
+	)
+	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		return currWallet.updatePendingTxStatus(tx, types.ScheduledTransaction)
+	})
+	if err != nil {
+		return err
+	}
+	w.eventBroker.SendNotifications()
+	return nil
+}
+
+// processScheduledWithdrawals cancels withdrawals which ExpiresAt time has
+// passed and sends scheduled withdrawals which ExecuteAfter time has come.
+// It is called by wallet updater goroutine on each iteration, schedule is
+// kept in storage, so it survives restarts
+func (w *Wallet) processScheduledWithdrawals() {
+	now := time.Now()
+
+	expired, err := w.storage.GetExpiredTransactions(now)
+	if err != nil {
+		log.Printf("wallet: error: failed to get expired withdrawals: %v", err)
+		return
+	}
+	for _, tx := range expired {
+		log.Printf(
+			"Withdrawal %s with status %s expired at %s, cancelling it",
+			tx.ID,
+			tx.Status,
+			tx.ExpiresAt,
+		)
+		if err = w.cancelPendingTx(tx.ID); err != nil {
+			log.Printf(
+				"wallet: error: failed to cancel expired withdrawal %s: %v",
+				tx.ID,
+				err,
+			)
+		}
+	}
+
+	due, err := w.storage.GetDueScheduledTransactions(now)
+	if err != nil {
+		log.Printf("wallet: error: failed to get scheduled withdrawals: %v", err)
+		return
+	}
+	for _, tx := range due {
+		log.Printf("Scheduled withdrawal %s is due, sending it", tx.ID)
+		if err = w.dispatchWithdrawal(tx); err != nil {
+			log.Printf(
+				"wallet: error: failed to send scheduled withdrawal %s, will "+
+					"retry later: %v",
+				tx.ID,
+				err,
+			)
+		}
+	}
+}
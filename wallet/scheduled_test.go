@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+func TestScheduledWithdrawals(t *testing.T) {
+	n := &nodeAPIAutoBatchMock{}
+	n.balance = uint64(bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("10")))
+	w := newAutoBatchTestWallet(n)
+	broker := w.eventBroker.(*loggingEventBrokerMock)
+
+	later := time.Now().Add(time.Hour)
+	scheduled := newTestWithdrawal("mv4rnyY3Su5gjcDNzbMLKBQkBicCtHUtFB", "0.1", bitcoin.FixedFee)
+	scheduled.ExecuteAfter = &later
+	if err := w.withdraw(scheduled, false); err != nil {
+		t.Fatal(err)
+	}
+	assertTxStatus(t, w, scheduled, types.ScheduledTransaction, "")
+
+	w.processScheduledWithdrawals()
+	if len(n.sentSingle) != 0 {
+		t.Fatalf("Expected scheduled withdrawal not to be sent before its time")
+	}
+
+	// scheduled time comes
+	stored, err := w.storage.GetTransactionByID(scheduled.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier := time.Now().Add(-time.Second)
+	stored.ExecuteAfter = &earlier
+	w.processScheduledWithdrawals()
+	if got, want := len(n.sentSingle), 1; got != want {
+		t.Fatalf("Expected scheduled withdrawal to be sent when its time comes")
+	}
+	assertTxStatus(t, w, scheduled, types.NewTransaction, testBatchTxHash)
+
+	// withdrawal held for manual confirmation expires
+	broker.flushEvents()
+	expiring := newTestWithdrawal("n1ZCYg9YXtB5XCZazLxSmPDa8iwJRZHhGx", "0.2", bitcoin.FixedFee)
+	expiring.ExpiresAt = &later
+	if err = w.withdraw(expiring, true); err != nil {
+		t.Fatal(err)
+	}
+	w.processScheduledWithdrawals()
+	assertTxStatus(t, w, expiring, types.PendingManualConfirmationTransaction, "")
+
+	stored, err = w.storage.GetTransactionByID(expiring.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.ExpiresAt = &earlier
+	w.processScheduledWithdrawals()
+	assertTxStatus(t, w, expiring, types.CancelledTransaction, "")
+	last := broker.log[len(broker.log)-1]
+	if last.Type != events.PendingTxCancelledEvent {
+		t.Errorf("Expected expired withdrawal to be cancelled with event %s, "+
+			"got %s", events.PendingTxCancelledEvent, last.Type)
+	}
+}
+
+func TestWithdrawScheduleChecks(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	later := time.Now().Add(time.Hour)
+	muchLater := later.Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		executeAfter  *time.Time
+		expiresAt     *time.Time
+		toColdStorage bool
+		wantErr       bool
+	}{
+		{"no schedule", nil, nil, false, false},
+		{"valid schedule", &later, &muchLater, false, false},
+		{"expired", nil, &past, false, true},
+		{"expires before execution", &muchLater, &later, false, true},
+		{"cold storage", &later, nil, true, true},
+	}
+	for _, test := range tests {
+		request := &WithdrawRequest{
+			ExecuteAfter: test.executeAfter,
+			ExpiresAt:    test.expiresAt,
+		}
+		err := checkWithdrawSchedule(request, test.toColdStorage)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: expected error %t, got %v", test.name, test.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package wallet
+
+import (
+	"log"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// Estimate modes accepted by Bitcoin node for 'smart' fee type. Conservative
+// estimate considers longer history of blocks and is less likely to underpay,
+// economical one reacts faster to decreasing fee market
+const (
+	EstimateModeEconomical   = "economical"
+	EstimateModeConservative = "conservative"
+)
+
+// maxConfTarget is the largest confirmation target Bitcoin Core can estimate
+// fee for
+const maxConfTarget = 1008
+
+func (w *Wallet) clampSmartFeeRate(rate bitcoin.BTCAmount) bitcoin.BTCAmount {
+	if rate < w.smartFeeMinRate {
+		return w.smartFeeMinRate
+	}
+	if w.smartFeeMaxRate > 0 && rate > w.smartFeeMaxRate {
+		return w.smartFeeMaxRate
+	}
+	return rate
+}
+
+// smartFeeRate gets fee rate per kilobyte for withdrawal with 'smart' fee type
+// from Bitcoin node. Zero confTarget and empty estimateMode are replaced with
+// values from config ("wallet.smart_fee.conf_target" and
+// "wallet.smart_fee.estimate_mode"). Resulting rate is limited by
+// "wallet.smart_fee.min_rate" and "wallet.smart_fee.max_rate". If node can't
+// make an estimate, "wallet.smart_fee.fallback_rate" is used instead
+func (w *Wallet) smartFeeRate(confTarget int, estimateMode string) (bitcoin.BTCAmount, error) {
+	if confTarget == 0 {
+		confTarget = w.smartFeeConfTarget
+	}
+	if estimateMode == "" {
+		estimateMode = w.smartFeeEstimateMode
+	}
+	if confTarget < 1 || confTarget > maxConfTarget {
+		return 0, newError(
+			ErrorCodeInvalidRequest,
+			"Confirmation target %d is out of range 1-%d",
+			confTarget,
+			maxConfTarget,
+		)
+	}
+	if estimateMode != EstimateModeEconomical && estimateMode != EstimateModeConservative {
+		return 0, newError(
+			ErrorCodeInvalidRequest,
+			"Unknown estimate mode %q, should be %q or %q",
+			estimateMode,
+			EstimateModeEconomical,
+			EstimateModeConservative,
+		)
+	}
+
+	estimate, err := w.nodeAPI.EstimateSmartFee(confTarget, estimateMode)
+	if err != nil {
+		return 0, err
+	}
+	if estimate == nil || estimate.FeeRate == nil {
+		var reasons []string
+		if estimate != nil {
+			reasons = estimate.Errors
+		}
+		log.Printf(
+			"Bitcoin node could not estimate fee for confirmation target %d "+
+				"(%v), using fallback rate %s",
+			confTarget,
+			reasons,
+			w.smartFeeFallbackRate,
+		)
+		return w.clampSmartFeeRate(w.smartFeeFallbackRate), nil
+	}
+
+	rate := bitcoin.BTCAmountFromFloat(*estimate.FeeRate)
+	result := w.clampSmartFeeRate(rate)
+
+	log.Printf(
+		"Bitcoin node estimated fee rate %s for confirmation target %d "+
+			"(%s mode), using rate %s",
+		rate,
+		confTarget,
+		estimateMode,
+		result,
+	)
+	return result, nil
+}
+
+// reestimateSmartFee estimates fee rate of withdrawals with 'smart' fee type
+// again right before they are sent: rate estimated when withdrawal was
+// requested may be stale by then if it was pending, scheduled or held for
+// manual confirmation. If estimate fails or new rate is less than
+// "wallet.min_fee.per_kb" (request with such rate would be rejected, see
+// checkWithdrawLimits), previous rate is kept
+func (w *Wallet) reestimateSmartFee(txns []*types.Transaction) {
+	type estimateParams struct {
+		confTarget   int
+		estimateMode string
+	}
+	rates := make(map[estimateParams]bitcoin.BTCAmount)
+
+	for _, tx := range txns {
+		if tx.FeeType != bitcoin.SmartFee {
+			continue
+		}
+		params := estimateParams{tx.ConfTarget, tx.EstimateMode}
+		rate, ok := rates[params]
+		if !ok {
+			var err error
+			rate, err = w.smartFeeRate(tx.ConfTarget, tx.EstimateMode)
+			if err != nil {
+				log.Printf(
+					"Failed to estimate fee rate of tx %s again, keeping "+
+						"rate %s: %v",
+					tx.ID,
+					tx.Fee,
+					err,
+				)
+				continue
+			}
+			rates[params] = rate
+		}
+		if rate < w.minFeePerKb {
+			log.Printf(
+				"Fee rate %s estimated again for tx %s is less than min "+
+					"withdraw fee %s, keeping rate %s",
+				rate,
+				tx.ID,
+				w.minFeePerKb,
+				tx.Fee,
+			)
+			continue
+		}
+		tx.Fee = rate
+	}
+}
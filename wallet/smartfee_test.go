@@ -0,0 +1,216 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/bitcoin/nodeapi"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+type nodeAPISmartFeeMock struct {
+	nodeapi.NodeAPI
+
+	estimate   *nodeapi.SmartFeeEstimate
+	confTarget int
+	mode       string
+}
+
+func (n *nodeAPISmartFeeMock) EstimateSmartFee(confTarget int, estimateMode string) (*nodeapi.SmartFeeEstimate, error) {
+	n.confTarget, n.mode = confTarget, estimateMode
+	return n.estimate, nil
+}
+
+func newSmartFeeTestWallet(n nodeapi.NodeAPI) *Wallet {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"wallet.min_fee.per_kb":          bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.00002")),
+			"wallet.smart_fee.conf_target":   6,
+			"wallet.smart_fee.estimate_mode": EstimateModeConservative,
+			"wallet.smart_fee.min_rate":      bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.00001")),
+			"wallet.smart_fee.max_rate":      bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.001")),
+			"wallet.smart_fee.fallback_rate": bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0002")),
+		},
+	}
+	return NewWallet(s, n, &loggingEventBrokerMock{}, NewStorage(nil))
+}
+
+func TestSmartFeeRate(t *testing.T) {
+	rate := func(r float64) *nodeapi.SmartFeeEstimate {
+		return &nodeapi.SmartFeeEstimate{FeeRate: &r, Blocks: 2}
+	}
+	unavailable := &nodeapi.SmartFeeEstimate{
+		Errors: []string{"Insufficient data or no feerate found"},
+	}
+
+	tests := []struct {
+		name       string
+		estimate   *nodeapi.SmartFeeEstimate
+		confTarget int
+		mode       string
+		wantRate   string
+		wantTarget int
+		wantMode   string
+	}{
+		{"defaults", rate(0.00012), 0, "", "0.00012", 6, EstimateModeConservative},
+		{"explicit params", rate(0.00034), 2, EstimateModeEconomical, "0.00034", 2, EstimateModeEconomical},
+		{"floor", rate(0.000001), 0, "", "0.00001", 6, EstimateModeConservative},
+		{"ceiling", rate(0.01), 0, "", "0.001", 6, EstimateModeConservative},
+		{"fallback", unavailable, 0, "", "0.0002", 6, EstimateModeConservative},
+	}
+	for _, test := range tests {
+		n := &nodeAPISmartFeeMock{estimate: test.estimate}
+		w := newSmartFeeTestWallet(n)
+
+		got, err := w.smartFeeRate(test.confTarget, test.mode)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if want := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(test.wantRate)); got != want {
+			t.Errorf("%s: expected rate %s, got %s", test.name, want, got)
+		}
+		if n.confTarget != test.wantTarget || n.mode != test.wantMode {
+			t.Errorf("%s: expected estimate for %d blocks in %s mode, got "+
+				"%d blocks in %s mode", test.name, test.wantTarget,
+				test.wantMode, n.confTarget, n.mode)
+		}
+	}
+}
+
+func TestSmartFeeWithdrawChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  *WithdrawRequest
+		wantCode ErrorCode
+	}{
+		{
+			"conf target out of range",
+			&WithdrawRequest{ConfTarget: 2000},
+			ErrorCodeInvalidRequest,
+		},
+		{
+			"unknown estimate mode",
+			&WithdrawRequest{EstimateMode: "fast"},
+			ErrorCodeInvalidRequest,
+		},
+		{
+			// floor is lower than min fee
+			"estimated rate below min fee",
+			&WithdrawRequest{},
+			ErrorCodeFeeBelowMinimum,
+		},
+	}
+	low := 0.000001
+	n := &nodeAPISmartFeeMock{estimate: &nodeapi.SmartFeeEstimate{FeeRate: &low}}
+	w := newSmartFeeTestWallet(n)
+
+	for _, test := range tests {
+		test.request.ID = testTxID
+		test.request.Address = testAddress
+		test.request.Amount = bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1"))
+		test.request.FeeType = "smart"
+
+		err := w.Withdraw(test.request, false)
+		walletErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Withdraw with %s: expected *Error, got %v", test.name, err)
+			continue
+		}
+		if walletErr.Code != test.wantCode {
+			t.Errorf("Withdraw with %s: expected error code %s, got %s",
+				test.name, test.wantCode, walletErr.Code)
+		}
+	}
+}
+
+type nodeAPISmartFeeSendMock struct {
+	nodeAPISmartFeeMock
+
+	sentFee bitcoin.BTCAmount
+}
+
+func (n *nodeAPISmartFeeSendMock) SendWithPerKBFee(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (string, error) {
+	n.sentFee = fee
+	return testBatchTxHash, nil
+}
+
+func TestSmartFeeEstimatedAgainBeforeSending(t *testing.T) {
+	current := 0.0003
+	n := &nodeAPISmartFeeSendMock{
+		nodeAPISmartFeeMock: nodeAPISmartFeeMock{
+			estimate: &nodeapi.SmartFeeEstimate{FeeRate: &current},
+		},
+	}
+	w := newSmartFeeTestWallet(n)
+
+	// withdrawal became pending when rate was lower
+	tx := &types.Transaction{
+		ID:           testTxID,
+		Address:      testAddress,
+		Direction:    types.OutgoingDirection,
+		Status:       types.PendingTransaction,
+		Amount:       bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+		Fee:          bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+		FeeType:      bitcoin.SmartFee,
+		ConfTarget:   2,
+		EstimateMode: EstimateModeEconomical,
+	}
+	if _, err := w.storage.StoreTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.sendWithdrawal(tx, false); err != nil {
+		t.Fatal(err)
+	}
+	want := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0003"))
+	if n.sentFee != want {
+		t.Errorf("Expected withdrawal to be sent with fee rate %s, got %s",
+			want, n.sentFee)
+	}
+	if n.confTarget != 2 || n.mode != EstimateModeEconomical {
+		t.Errorf("Expected fee to be estimated with params of request, got "+
+			"%d blocks in %s mode", n.confTarget, n.mode)
+	}
+	stored, err := w.storage.GetTransactionByID(testTxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Fee != want {
+		t.Errorf("Expected new fee rate %s to be stored, got %s", want, stored.Fee)
+	}
+}
+
+func TestSmartFeeBelowMinimumNotUsedBeforeSending(t *testing.T) {
+	// floor of smart fee is lower than min fee
+	low := 0.000001
+	n := &nodeAPISmartFeeSendMock{
+		nodeAPISmartFeeMock: nodeAPISmartFeeMock{
+			estimate: &nodeapi.SmartFeeEstimate{FeeRate: &low},
+		},
+	}
+	w := newSmartFeeTestWallet(n)
+
+	previous := bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001"))
+	tx := &types.Transaction{
+		ID:        testTxID,
+		Address:   testAddress,
+		Direction: types.OutgoingDirection,
+		Status:    types.PendingTransaction,
+		Amount:    bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+		Fee:       previous,
+		FeeType:   bitcoin.SmartFee,
+	}
+	if _, err := w.storage.StoreTransaction(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.sendWithdrawal(tx, false); err != nil {
+		t.Fatal(err)
+	}
+	if n.sentFee != previous {
+		t.Errorf("Expected withdrawal to be sent with previous fee rate %s, "+
+			"got %s", previous, n.sentFee)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"time"
 
 	"github.com/gofrs/uuid"
 
@@ -13,8 +14,8 @@ import (
 var ErrHotWalletAddressNotGeneratedYet = errors.New("Hot wallet address not generated yet")
 
 // Storage is responsible for storing and fetching wallet-related information:
-// transactions, accounts, and various metainformation about current wallet or
-// its state. Currently, metainformation includes hot wallet address, last seen
+// transactions, accounts, invoices and various metainformation about current
+// wallet or its state. Currently, metainformation includes hot wallet address, last seen
 // bitcoin block hash and amount of money required to transfer from cold storage
 type Storage interface {
 	GetLastSeenBlockHash() (string, error)
@@ -24,11 +25,24 @@ type Storage interface {
 	GetTransactionByID(id uuid.UUID) (*types.Transaction, error)
 	GetBroadcastedTransactionsWithLessConfirmations(confirmations int64) ([]*types.Transaction, error)
 	GetPendingTransactions() ([]*types.Transaction, error)
+	GetDueScheduledTransactions(now time.Time) ([]*types.Transaction, error)
+	GetExpiredTransactions(now time.Time) ([]*types.Transaction, error)
 	updateReportedConfirmations(transaction *types.Transaction, reportedConfirmations int64) error
-	GetTransactionsWithFilter(directionFilter string, statusFilter string) ([]*types.Transaction, error)
+	updateApprovals(transaction *types.Transaction, approvals []types.Approval) error
+	updateFee(transaction *types.Transaction) error
+	updateReplacement(transaction *types.Transaction) error
+	GetTransactionsWithFilter(filter *TransactionsFilter) ([]*types.Transaction, error)
 
 	GetAccountByAddress(address string) (*Account, error)
+	GetAccountsWithFilter(filter *AccountsFilter) ([]*Account, error)
 	StoreAccount(account *Account) error
+	updateAccountMetainfo(update *AccountMetainfoUpdate) error
+
+	StoreInvoice(invoice *Invoice) error
+	GetInvoiceByID(id uuid.UUID) (*Invoice, error)
+	GetInvoiceByAddress(address string) (*Invoice, error)
+	GetExpiredUnpaidInvoices(now time.Time) ([]*Invoice, error)
+	updateInvoice(invoice *Invoice) error
 
 	GetHotWalletAddress() (string, error)
 	setHotWalletAddress(address string) error
@@ -52,6 +66,7 @@ func NewStorage(db *sql.DB) Storage {
 		return &InMemoryWalletStorage{
 			accounts:     make([]*Account, 0),
 			transactions: make([]*types.Transaction, 0),
+			invoices:     make([]*Invoice, 0),
 		}
 	}
 
@@ -0,0 +1,208 @@
+package wallet
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+// ColdStorageSweep describes automatic transfer of excess hot wallet balance
+// to cold storage, it is sent as data of cold-storage-sweep event. TxID and
+// Hash identify cold storage withdrawal that made the transfer. Balance is
+// confirmed balance before sweep, Reserved is a part of it needed to fund
+// withdrawals that are not sent yet (it is never swept) and Target is a balance wallet is
+// supposed to keep
+type ColdStorageSweep struct {
+	TxID     uuid.UUID         `json:"tx_id"`
+	Hash     string            `json:"hash"`
+	Address  string            `json:"address"`
+	Amount   bitcoin.BTCAmount `json:"amount"`
+	Fee      bitcoin.BTCAmount `json:"fee"`
+	FeeType  bitcoin.FeeType   `json:"fee_type"`
+	Balance  bitcoin.BTCAmount `json:"balance"`
+	Reserved bitcoin.BTCAmount `json:"reserved"`
+	Target   bitcoin.BTCAmount `json:"target"`
+}
+
+func init() {
+	events.RegisterNotificationUnmarshaler(events.ColdStorageSweepEvent, func(b []byte) (interface{}, error) {
+		var sweep ColdStorageSweep
+
+		err := json.Unmarshal(b, &sweep)
+		return &sweep, err
+	})
+}
+
+func (w *Wallet) initColdStorageSweep() {
+	if w.sweepHighWaterMark == 0 {
+		return
+	}
+	if w.coldWalletAddress == "" {
+		log.Fatal("wallet.cold_storage_sweep.high_water_mark is set in " +
+			"config, but wallet.cold_wallet_address is not: there is " +
+			"nowhere to sweep money to")
+	}
+	if w.sweepTarget >= w.sweepHighWaterMark {
+		log.Fatalf("wallet.cold_storage_sweep.target %s should be less than "+
+			"high_water_mark %s", w.sweepTarget, w.sweepHighWaterMark)
+	}
+	feeTypeStr := w.settings.GetString("wallet.cold_storage_sweep.fee_type")
+	feeType, err := bitcoin.FeeTypeFromString(feeTypeStr)
+	if err != nil || feeType == bitcoin.InvalidFee {
+		log.Fatalf("Invalid wallet.cold_storage_sweep.fee_type %q in config: "+
+			"should be 'per-kb-rate', 'fixed' or 'smart'", feeTypeStr)
+	}
+	switch {
+	case feeType == bitcoin.FixedFee && (w.sweepFee == 0 || w.sweepFee < w.minFeeFixed):
+		log.Fatalf("wallet.cold_storage_sweep.fee %s is less than "+
+			"wallet.min_fee.fixed %s or zero", w.sweepFee, w.minFeeFixed)
+	case feeType == bitcoin.PerKBRateFee && w.sweepFee != 0 && w.sweepFee < w.minFeePerKb:
+		// zero rate is allowed: it means rate is chosen by Bitcoin node
+		log.Fatalf("wallet.cold_storage_sweep.fee %s is less than "+
+			"wallet.min_fee.per_kb %s", w.sweepFee, w.minFeePerKb)
+	}
+	w.sweepFeeType = feeType
+	log.Printf(
+		"Balance above %s will be swept to cold storage address %s down to %s",
+		w.sweepHighWaterMark,
+		w.coldWalletAddress,
+		w.sweepTarget,
+	)
+}
+
+// sweepAmount tells how much should be swept to cold storage given confirmed
+// balance and money reserved for pending withdrawals. Sweep starts when
+// balance exceeds high-water mark and leaves target plus reserved money in
+// hot wallet. Zero means no sweep is needed
+func (w *Wallet) sweepAmount(balance, reserved bitcoin.BTCAmount) bitcoin.BTCAmount {
+	if w.sweepHighWaterMark == 0 || balance <= w.sweepHighWaterMark {
+		return 0
+	}
+	if balance <= reserved+w.sweepTarget {
+		return 0
+	}
+	amount := balance - reserved - w.sweepTarget
+	if amount < w.sweepMinAmount {
+		return 0
+	}
+	return amount
+}
+
+// reservedForWithdrawals returns amount of money needed to fund withdrawals
+// that are not sent yet: pending ones (see GetPendingTransactions), ones
+// queued to be sent in batch, scheduled ones and ones held for manual
+// confirmation
+func (w *Wallet) reservedForWithdrawals() (bitcoin.BTCAmount, error) {
+	txns, err := w.storage.GetPendingTransactions()
+	if err != nil {
+		return 0, err
+	}
+	waitingStatuses := []types.TransactionStatus{
+		types.QueuedTransaction,
+		types.ScheduledTransaction,
+		types.PendingManualConfirmationTransaction,
+	}
+	for _, status := range waitingStatuses {
+		waitingTxns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+			Status: status.String(),
+		})
+		if err != nil {
+			return 0, err
+		}
+		txns = append(txns, waitingTxns...)
+	}
+	var reserved int64
+	for _, tx := range txns {
+		reserved += withdrawalCost(tx)
+	}
+	return bitcoin.BTCAmount(reserved), nil
+}
+
+// sweepToColdStorage sends excess of confirmed balance to cold storage (see
+// sweepAmount) and notifies client with cold-storage-sweep event. Fee of
+// sweep is paid from swept amount. It is called by wallet updater goroutine
+// on each iteration
+func (w *Wallet) sweepToColdStorage() {
+	if w.sweepHighWaterMark == 0 {
+		return
+	}
+	if err := w.tryToSweepToColdStorage(); err != nil {
+		log.Printf("wallet: error: failed to sweep to cold storage: %v", err)
+	}
+}
+
+func (w *Wallet) tryToSweepToColdStorage() error {
+	confBal, _, err := w.nodeAPI.GetConfirmedAndUnconfirmedBalance()
+	if err != nil {
+		return err
+	}
+	balance := bitcoin.BTCAmount(confBal)
+	if balance <= w.sweepHighWaterMark {
+		return nil
+	}
+	reserved, err := w.reservedForWithdrawals()
+	if err != nil {
+		return err
+	}
+	amount := w.sweepAmount(balance, reserved)
+	if amount == 0 {
+		return nil
+	}
+
+	fee := w.sweepFee
+	if w.sweepFeeType == bitcoin.SmartFee {
+		if fee, err = w.smartFeeRate(0, ""); err != nil {
+			return err
+		}
+	}
+	log.Printf(
+		"Confirmed balance %s is above %s (%s reserved for pending "+
+			"withdrawals), sweeping %s to cold storage",
+		balance,
+		w.sweepHighWaterMark,
+		reserved,
+		amount,
+	)
+
+	tx := &types.Transaction{
+		ID:                    uuid.Must(uuid.NewV4()),
+		Address:               w.coldWalletAddress,
+		Direction:             types.OutgoingDirection,
+		Amount:                amount,
+		Metainfo:              map[string]interface{}{"cold_storage_sweep": true},
+		Fee:                   fee,
+		FeeType:               w.sweepFeeType,
+		FeePayer:              bitcoin.RecipientPaysFee,
+		ColdStorage:           true,
+		Fresh:                 true,
+		ReportedConfirmations: -1,
+	}
+	if err = w.withdraw(tx, false); err != nil {
+		return err
+	}
+
+	sweep := &ColdStorageSweep{
+		TxID:     tx.ID,
+		Hash:     tx.Hash,
+		Address:  tx.Address,
+		Amount:   tx.Amount,
+		Fee:      tx.Fee,
+		FeeType:  tx.FeeType,
+		Balance:  balance,
+		Reserved: reserved,
+		Target:   w.sweepTarget,
+	}
+	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		return currWallet.eventBroker.Notify(events.ColdStorageSweepEvent, sweep)
+	})
+	if err != nil {
+		return err
+	}
+	w.eventBroker.SendNotifications()
+	return nil
+}
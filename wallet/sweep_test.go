@@ -0,0 +1,186 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onederx/bitcoin-processing/bitcoin"
+	"github.com/onederx/bitcoin-processing/events"
+	settingstestutil "github.com/onederx/bitcoin-processing/settings/testutil"
+	"github.com/onederx/bitcoin-processing/wallet/types"
+)
+
+const testColdStorageAddress = "3QJmV3qfvL9SuYo34YihAf3sRCW3qSinyC"
+
+type sweepSend struct {
+	address          string
+	amount           bitcoin.BTCAmount
+	fee              bitcoin.BTCAmount
+	recipientPaysFee bool
+}
+
+type nodeAPISweepMock struct {
+	nodeAPISendManyMock
+
+	swept []sweepSend
+}
+
+func (n *nodeAPISweepMock) SendWithPerKBFee(address string, amount, fee bitcoin.BTCAmount, recipientPaysFee bool) (string, error) {
+	n.balance -= uint64(amount)
+	n.swept = append(n.swept, sweepSend{address, amount, fee, recipientPaysFee})
+	return testBatchTxHash, nil
+}
+
+func btc(amount string) bitcoin.BTCAmount {
+	return bitcoin.Must(bitcoin.BTCAmountFromStringedFloat(amount))
+}
+
+func newSweepTestWallet(n *nodeAPISweepMock) (*Wallet, *loggingEventBrokerMock) {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"transaction.max_confirmations":             1,
+			"wallet.cold_storage_sweep.high_water_mark": btc("10"),
+			"wallet.cold_storage_sweep.target":          btc("4"),
+			"wallet.cold_storage_sweep.min_amount":      btc("0.5"),
+			"wallet.cold_storage_sweep.fee":             btc("0.0002"),
+			"wallet.cold_storage_sweep.fee_type":        bitcoin.PerKBRateFee.String(),
+		},
+	}
+	e := &loggingEventBrokerMock{}
+	w := NewWallet(s, n, e, NewStorage(nil))
+	w.coldWalletAddress = testColdStorageAddress
+	w.initColdStorageSweep()
+	return w, e
+}
+
+func TestSweepAmount(t *testing.T) {
+	w, _ := newSweepTestWallet(&nodeAPISweepMock{})
+
+	tests := []struct {
+		balance  string
+		reserved string
+		want     string
+	}{
+		{"10", "0", "0"},
+		{"12", "0", "8"},
+		{"12", "3", "5"},
+		{"12", "7.6", "0"},
+		{"12", "9", "0"},
+		{"12", "20", "0"},
+	}
+	for _, test := range tests {
+		got := w.sweepAmount(btc(test.balance), btc(test.reserved))
+		if want := btc(test.want); got != want {
+			t.Errorf("Expected sweep amount for balance %s with %s reserved "+
+				"to be %s, got %s", test.balance, test.reserved, want, got)
+		}
+	}
+
+	w.sweepHighWaterMark = 0
+	if got := w.sweepAmount(btc("100"), 0); got != 0 {
+		t.Errorf("Expected disabled sweep to sweep nothing, got %s", got)
+	}
+}
+
+func TestSweepToColdStorage(t *testing.T) {
+	n := &nodeAPISweepMock{}
+	n.balance = uint64(btc("12"))
+	w, e := newSweepTestWallet(n)
+
+	pending := &types.Transaction{
+		ID:                    testTxID,
+		Address:               testAddress,
+		Direction:             types.OutgoingDirection,
+		Status:                types.PendingColdStorageTransaction,
+		Amount:                btc("20"),
+		FeeType:               bitcoin.FixedFee,
+		Fee:                   btc("0.001"),
+		FeePayer:              bitcoin.SenderPaysFee,
+		Fresh:                 true,
+		ReportedConfirmations: -1,
+	}
+	if _, err := w.storage.StoreTransaction(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	// balance is not enough for pending withdrawal, nothing is swept
+	w.sweepToColdStorage()
+	if len(n.swept) != 0 {
+		t.Fatalf("Expected money needed by pending withdrawal not to be "+
+			"swept, but sent %v", n.swept[0])
+	}
+
+	pending.Amount = btc("2")
+	if _, err := w.storage.StoreTransaction(pending); err != nil {
+		t.Fatal(err)
+	}
+	e.flushEvents()
+	w.sweepToColdStorage()
+	if len(n.swept) != 1 {
+		t.Fatalf("Expected one sweep, got %d", len(n.swept))
+	}
+	sent := n.swept[0]
+	wantAmount := btc("5.999")
+	if sent.address != testColdStorageAddress || sent.amount != wantAmount ||
+		sent.fee != btc("0.0002") || !sent.recipientPaysFee {
+		t.Errorf("Expected %s to be sent to %s with fee 0.0002 paid by "+
+			"recipient, got %+v", wantAmount, testColdStorageAddress, sent)
+	}
+
+	if len(e.log) != 1 || e.log[0].Type != events.ColdStorageSweepEvent {
+		t.Fatalf("Expected sweep to emit %s event, got %v",
+			events.ColdStorageSweepEvent, e.log)
+	}
+	sweep := e.log[0].Data.(*ColdStorageSweep)
+	if sweep.Hash != testBatchTxHash || sweep.Amount != wantAmount ||
+		sweep.Balance != btc("12") || sweep.Reserved != btc("2.001") {
+		t.Errorf("Unexpected sweep event data %+v", sweep)
+	}
+	tx, err := w.storage.GetTransactionByID(sweep.TxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tx.ColdStorage || tx.Status != types.NewTransaction {
+		t.Errorf("Expected sweep to be stored as sent cold storage "+
+			"withdrawal, got %v", tx)
+	}
+
+	// balance is now below high-water mark
+	w.sweepToColdStorage()
+	if len(n.swept) != 1 {
+		t.Errorf("Expected no more sweeps, got %d", len(n.swept))
+	}
+}
+
+func TestSweepKeepsMoneyForWithdrawalsNotSentYet(t *testing.T) {
+	n := &nodeAPISweepMock{}
+	n.balance = uint64(btc("12"))
+	w, e := newSweepTestWallet(n)
+
+	executeAfter := time.Now().Add(time.Hour)
+	scheduled := newTestWithdrawal(testAddress, "3", bitcoin.PerKBRateFee)
+	scheduled.Status = types.ScheduledTransaction
+	scheduled.ExecuteAfter = &executeAfter
+	held := newTestWithdrawal(testAddress, "2", bitcoin.FixedFee)
+	held.Status = types.PendingManualConfirmationTransaction
+	held.Fee = btc("0.001")
+	held.FeePayer = bitcoin.SenderPaysFee
+	for _, tx := range []*types.Transaction{scheduled, held} {
+		if _, err := w.storage.StoreTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w.sweepToColdStorage()
+	if len(n.swept) != 1 {
+		t.Fatalf("Expected one sweep, got %d", len(n.swept))
+	}
+	if got, want := n.swept[0].amount, btc("2.999"); got != want {
+		t.Errorf("Expected %s to be swept, got %s", want, got)
+	}
+	sweep := e.log[0].Data.(*ColdStorageSweep)
+	if got, want := sweep.Reserved, btc("5.001"); got != want {
+		t.Errorf("Expected %s to be reserved for scheduled and held "+
+			"withdrawals, got %s", want, got)
+	}
+}
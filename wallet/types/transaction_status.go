@@ -47,6 +47,39 @@ const (
 	// any other way by processing app
 	CancelledTransaction
 
+	// QueuedTransaction is a status of withdrawal collected by wallet to be
+	// sent together with other withdrawals in one Bitcoin transaction. Such
+	// withdrawals are sent when batching window (set by config parameter
+	// wallet.batching.window) ends or enough of them are collected
+	// (wallet.batching.max_size)
+	QueuedTransaction
+
+	// ConflictedTransaction is a status of tx that is not mined yet and
+	// conflicts with another Bitcoin tx spending some of the same inputs
+	// (Bitcoin node reports it in 'walletconflicts'). Only one of them can
+	// get into blockchain, so money of such tx should not be relied upon
+	// until it is confirmed. Replacements made by fee bumps are not
+	// considered conflicts
+	ConflictedTransaction
+
+	// DoubleSpentTransaction is a status of tx that can never be confirmed
+	// because conflicting tx spending the same inputs was mined instead of it
+	// (Bitcoin node reports negative number of confirmations for such tx). If
+	// conflicting tx is removed from blockchain by chain reorganization, tx
+	// may become valid again and get its status back
+	DoubleSpentTransaction
+
+	// DroppedTransaction is a status of withdrawal whose Bitcoin tx
+	// disappeared from mempool (for example, because its fee was too low)
+	// and could not be broadcasted again. Such withdrawal can be re-issued
+	// with higher fee by a fee bump
+	DroppedTransaction
+
+	// ScheduledTransaction is a status of withdrawal that should not be sent
+	// before its ExecuteAfter time. When this time comes, it is processed
+	// like a newly requested withdrawal: sent, queued or made pending
+	ScheduledTransaction
+
 	// InvalidTransaction is a status value generated when converting status
 	// from other type and value of source type is invalid
 	InvalidTransaction
@@ -60,6 +93,11 @@ var transactionStatusToStringMap = map[TransactionStatus]string{
 	PendingColdStorageTransaction:        "pending-cold-storage",
 	PendingManualConfirmationTransaction: "pending-manual-confirmation",
 	CancelledTransaction:                 "cancelled",
+	QueuedTransaction:                    "queued",
+	ConflictedTransaction:                "conflicted",
+	DoubleSpentTransaction:               "double-spent",
+	DroppedTransaction:                   "dropped",
+	ScheduledTransaction:                 "scheduled",
 }
 
 var stringToTransactionStatusMap = make(map[string]TransactionStatus)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcutil"
@@ -76,13 +77,95 @@ type Transaction struct {
 
 	FeeType bitcoin.FeeType `json:"fee_type"`
 
+	// FeePayer tells whether fee is paid by recipient (subtracted from amount
+	// sent) or by sender (paid by wallet on top of amount). Only valid for
+	// outgoing txns
+	FeePayer bitcoin.FeePayer `json:"fee_payer,omitempty"`
+
+	// ConfTarget and EstimateMode are parameters of fee rate estimate for
+	// withdrawals with 'smart' fee type. Rate is estimated again with them
+	// when withdrawal is sent. Zero and empty values mean defaults from config
+	ConfTarget   int    `json:"conf_target,omitempty"`
+	EstimateMode string `json:"estimate_mode,omitempty"`
+
 	// If tx is a withdrawal to cold storage, this is true. Otherwise false
 	ColdStorage bool `json:"cold_storage"`
 
+	// CreatedBy is an id of API key that requested this withdrawal. It is
+	// empty for incoming txns and if API authentication is disabled
+	CreatedBy string `json:"created_by,omitempty"`
+
+	// RequiredApprovals is a number of distinct approvals withdrawal needs
+	// to be sent if it is held for manual confirmation. It is 0 for txns that
+	// did not need manual confirmation
+	RequiredApprovals int `json:"required_approvals,omitempty"`
+
+	// Approvals is a list of approvals withdrawal pending manual confirmation
+	// has collected so far
+	Approvals []Approval `json:"approvals,omitempty"`
+
+	// BatchID is an id of batch withdrawal this tx is an entry of. All
+	// entries of a batch are sent by one Bitcoin transaction and share its
+	// hash. It is nil for txns that are not part of a batch
+	BatchID *uuid.UUID `json:"batch_id,omitempty"`
+
+	// ReplacedHashes are hashes of bitcoin txns that paid this withdrawal
+	// before its fee was bumped, oldest first. Any of them can still get
+	// into blockchain instead of replacement, in this case it becomes Hash
+	// again
+	ReplacedHashes []string `json:"replaced_hashes,omitempty"`
+
+	// ReplacedFees are fees and fee types withdrawal had while it was paid
+	// by txns listed in ReplacedHashes. If one of them gets into blockchain,
+	// withdrawal gets its fee back
+	ReplacedFees []ReplacedFee `json:"replaced_fees,omitempty"`
+
+	// WalletConflicts are hashes of Bitcoin txns that spend some of the same
+	// inputs as this one, as reported by Bitcoin node. Txns listed in
+	// ReplacedHashes are also reported there
+	WalletConflicts []string `json:"wallet_conflicts,omitempty"`
+
+	// ParentID is an id of incoming tx this tx accelerates: it is set for
+	// Child-Pays-For-Parent txns that spend output of slow deposit back to
+	// hot wallet. It is nil for other txns
+	ParentID *uuid.UUID `json:"parent_id,omitempty"`
+
+	// ExecuteAfter is a time scheduled withdrawal should be sent at. It is
+	// nil for withdrawals sent as soon as possible
+	ExecuteAfter *time.Time `json:"execute_after,omitempty"`
+
+	// ExpiresAt is a time withdrawal is automatically cancelled at if it is
+	// still scheduled or pending by then. It is nil for withdrawals that
+	// don't expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// CreatedAt is a time tx was first stored by processing: when withdrawal
+	// was requested or when incoming tx was first seen
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is a time tx was last changed by processing
+	UpdatedAt time.Time `json:"updated_at"`
+
 	Fresh                 bool  `json:"-"`
 	ReportedConfirmations int64 `json:"-"`
 }
 
+// Approval is a record of withdrawal being manually confirmed by some
+// approver. Approver is an id of API key used to confirm withdrawal, it is
+// empty if API authentication is disabled
+type Approval struct {
+	Approver string    `json:"approver"`
+	Time     time.Time `json:"time"`
+}
+
+// ReplacedFee is fee and fee type of withdrawal paid by bitcoin tx with
+// given hash before this tx was replaced by fee bump
+type ReplacedFee struct {
+	Hash    string            `json:"hash"`
+	Fee     bitcoin.BTCAmount `json:"fee"`
+	FeeType bitcoin.FeeType   `json:"fee_type"`
+}
+
 func (td TransactionDirection) String() string {
 	tdStr, ok := transactionDirectionToStringMap[td]
 	if !ok {
@@ -167,6 +250,7 @@ func (tx *Transaction) Update(other *Transaction) {
 	tx.BlockHash = other.BlockHash
 	tx.Confirmations = other.Confirmations
 	tx.Status = other.Status
+	tx.WalletConflicts = other.WalletConflicts
 }
 
 func (tx *Transaction) UpdateFromFullTxInfo(other *btcjson.GetTransactionResult) {
@@ -175,6 +259,26 @@ func (tx *Transaction) UpdateFromFullTxInfo(other *btcjson.GetTransactionResult)
 	}
 	tx.BlockHash = other.BlockHash
 	tx.Confirmations = other.Confirmations
+	tx.WalletConflicts = other.WalletConflicts
+}
+
+// Conflicts returns hashes of Bitcoin txns this tx conflicts with, excluding
+// ones it replaced by a fee bump
+func (tx *Transaction) Conflicts() []string {
+	var conflicts []string
+	for _, hash := range tx.WalletConflicts {
+		replaced := false
+		for _, replacedHash := range tx.ReplacedHashes {
+			if hash == replacedHash {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			conflicts = append(conflicts, hash)
+		}
+	}
+	return conflicts
 }
 
 func NewTransactionFromBTCJSON(btcNodeTransaction *btcjson.ListTransactionsResult) *Transaction {
@@ -229,6 +333,7 @@ func NewTransactionFromBTCJSON(btcNodeTransaction *btcjson.ListTransactionsResul
 		Hash:                  btcNodeTransaction.TxID,
 		BlockHash:             btcNodeTransaction.BlockHash,
 		Confirmations:         btcNodeTransaction.Confirmations,
+		WalletConflicts:       btcNodeTransaction.WalletConflicts,
 		Address:               btcNodeTransaction.Address,
 		Direction:             direction,
 		Status:                NewTransaction,
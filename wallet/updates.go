@@ -74,7 +74,27 @@ func (w *Wallet) notifyTransaction(tx *types.Transaction) error {
 	return nil
 }
 
-func (w *Wallet) updateTxInfo(tx *types.Transaction) (bool, error) {
+// storedTransaction returns a copy of given tx (just received from Bitcoin
+// node) as it is in storage, or nil if tx is not stored yet
+func (w *Wallet) storedTransaction(tx *types.Transaction) (*types.Transaction, error) {
+	txns, err := w.storage.GetTransactionsWithFilter(&TransactionsFilter{
+		Direction: tx.Direction.String(),
+		Address:   tx.Address,
+		Hash:      tx.Hash,
+		Limit:     1,
+	})
+	if err != nil || len(txns) == 0 {
+		return nil, err
+	}
+	stored := *txns[0]
+	return &stored, nil
+}
+
+// updateTxInfo stores info about tx received from Bitcoin node and notifies
+// client about changes. previous is a copy of tx as it was in storage before
+// this update (nil if tx was not stored), it is used to detect chain
+// reorganizations and conflicts
+func (w *Wallet) updateTxInfo(tx *types.Transaction, previous *types.Transaction) (bool, error) {
 	var err error
 
 	isHotStorageTx := tx.Address == w.hotWalletAddress
@@ -90,6 +110,9 @@ func (w *Wallet) updateTxInfo(tx *types.Transaction) (bool, error) {
 	}
 
 	oldStatus := tx.Status
+	if previous != nil {
+		oldStatus = previous.Status
+	}
 	w.setTxStatusByConfirmations(tx)
 
 	tx, err = w.storage.StoreTransaction(tx)
@@ -97,7 +120,10 @@ func (w *Wallet) updateTxInfo(tx *types.Transaction) (bool, error) {
 		return false, err
 	}
 
-	txInfoChanged := tx.Fresh || (oldStatus != tx.Status)
+	reorged := previous != nil && isReorged(previous.BlockHash, tx)
+	conflicted := isConflictStatus(tx.Status) && tx.Status != oldStatus
+	dropped := tx.Status == types.DroppedTransaction && tx.Status != oldStatus
+	txInfoChanged := tx.Fresh || (oldStatus != tx.Status) || reorged
 	if tx.Fresh {
 		log.Printf("New tx %s", tx.Hash)
 	}
@@ -110,9 +136,29 @@ func (w *Wallet) updateTxInfo(tx *types.Transaction) (bool, error) {
 			tx.ID,
 		)
 	}
-	if !isHotStorageTx && !tx.ColdStorage { // don't notify about internal txns
+	isInternalTx := isHotStorageTx || tx.ColdStorage
+	if reorged {
+		if err = w.handleReorg(tx, previous.BlockHash, !isInternalTx); err != nil {
+			return false, err
+		}
+	}
+	if conflicted {
+		if err = w.handleConflict(tx, !isInternalTx); err != nil {
+			return false, err
+		}
+	}
+	if dropped && !isInternalTx {
+		err = w.NotifyTransaction(events.WithdrawalDroppedEvent, *tx)
+		if err != nil {
+			return false, err
+		}
+	}
+	if !isInternalTx { // don't notify about internal txns
 		err = w.notifyTransaction(tx)
 	}
+	if err == nil && txInfoChanged && tx.Direction == types.IncomingDirection && !isInternalTx {
+		err = w.updateInvoiceOfAddress(tx.Address)
+	}
 
 	return txInfoChanged, err
 }
@@ -137,7 +183,25 @@ func (w *Wallet) checkForNewTransactions() {
 		}
 		for _, btcNodeTransaction := range lastTxData.Transactions {
 			tx := types.NewTransactionFromBTCJSON(&btcNodeTransaction)
-			currentTxInfoChanged, err := currWallet.updateTxInfo(tx)
+			if tx.Direction == types.OutgoingDirection {
+				// txns replaced by fee bump are tracked together with
+				// their replacements in checkForExistingTransactionUpdates
+				replaced, err := currWallet.isReplacedHash(tx.Hash)
+				if err != nil {
+					return err
+				}
+				if replaced {
+					continue
+				}
+			}
+			// tx may already be stored, and if its block was removed by
+			// reorganization, node now reports it in another block or
+			// without one
+			previous, err := currWallet.storedTransaction(tx)
+			if err != nil {
+				return err
+			}
+			currentTxInfoChanged, err := currWallet.updateTxInfo(tx, previous)
 			if err != nil {
 				return err
 			}
@@ -159,16 +223,27 @@ func (w *Wallet) checkForNewTransactions() {
 
 func (w *Wallet) setTxStatusByConfirmations(tx *types.Transaction) {
 	switch {
-	case tx.Status != types.NewTransaction && tx.Status != types.ConfirmedTransaction && tx.Status != types.FullyConfirmedTransaction:
+	case tx.Status != types.NewTransaction && tx.Status != types.ConfirmedTransaction && tx.Status != types.FullyConfirmedTransaction && !isConflictStatus(tx.Status) && tx.Status != types.DroppedTransaction:
 		// only "new" and "confirmed" statuses can be changed based on
 		// number of confirmations ("new" can become "confirmed", "confirmed"
 		// can become "fully-confirmed"). We also allow "fully-confirmed" to
 		// changed because statuses should be sent consistently, so, we want to
 		// be able to set status back to "new" or "confirmed" based on number of
 		// confirmations to report these statuses to client before reporting
-		// actual status "fully-confirmed"
+		// actual status "fully-confirmed". "conflicted" and "double-spent" txns
+		// can still be confirmed if conflicting tx is dropped or reorganized
+		// out of blockchain
 		return
-	case tx.Confirmations <= 0:
+	case tx.Status == types.DroppedTransaction && tx.Confirmations == 0 && len(tx.Conflicts()) == 0:
+		// dropped tx can still be mined if someone broadcasts it, otherwise
+		// it stays dropped until it is re-issued
+		return
+	case tx.Confirmations < 0:
+		// conflicting tx is in blockchain
+		tx.Status = types.DoubleSpentTransaction
+	case tx.Confirmations == 0 && len(tx.Conflicts()) > 0:
+		tx.Status = types.ConflictedTransaction
+	case tx.Confirmations == 0:
 		tx.Status = types.NewTransaction
 	case tx.Confirmations > 0 && tx.Confirmations < w.maxConfirmations:
 		tx.Status = types.ConfirmedTransaction
@@ -180,28 +255,50 @@ func (w *Wallet) setTxStatusByConfirmations(tx *types.Transaction) {
 func (w *Wallet) checkForExistingTransactionUpdates() {
 	anyTxInfoChanged := false
 
-	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+	tip, err := w.nodeAPI.GetBestBlockHash()
+	if err != nil {
+		log.Printf("wallet: error: failed to get chain tip: %v", err)
+		return
+	}
+	tipChanged := tip != w.lastCheckedTip
+
+	// number of txns still waiting for confirmations after this check
+	waitingCount := 0
+
+	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		// fully confirmed txns are still followed for reorgSafetyDepth more
+		// blocks in case they are removed from blockchain by reorganization
 		transactionsToCheck, err := currWallet.storage.GetBroadcastedTransactionsWithLessConfirmations(
-			w.maxConfirmations,
+			w.maxConfirmations + w.reorgSafetyDepth,
 		)
 		if err != nil {
 			return err
 		}
 
-		w.txnsWaitingBlockchainConfirmationCount.Set(float64(len(transactionsToCheck)))
-
 		for _, tx := range transactionsToCheck {
+			if (tx.Status == types.FullyConfirmedTransaction || tx.Status == types.DoubleSpentTransaction) && !tipChanged {
+				// nothing can happen to them until new block appears
+				continue
+			}
+			previous := *tx
 			fullTxInfo, err := currWallet.nodeAPI.GetTransaction(tx.Hash)
 			if err != nil {
 				return err
 			}
 			tx.UpdateFromFullTxInfo(fullTxInfo)
-			currentTxInfoChanged, err := currWallet.updateTxInfo(tx)
+			if err = currWallet.trackReplacements(tx); err != nil {
+				return err
+			}
+			received := time.Unix(fullTxInfo.TimeReceived, 0)
+			if err = currWallet.checkDropped(tx, received); err != nil {
+				return err
+			}
+			currentTxInfoChanged, err := currWallet.updateTxInfo(tx, &previous)
 			if err != nil {
 				return err
 			}
-			if tx.Status != types.NewTransaction && tx.Status != types.ConfirmedTransaction {
-				w.txnsWaitingBlockchainConfirmationCount.Dec()
+			if tx.Status == types.NewTransaction || tx.Status == types.ConfirmedTransaction {
+				waitingCount++
 			}
 			anyTxInfoChanged = anyTxInfoChanged || currentTxInfoChanged
 		}
@@ -214,6 +311,8 @@ func (w *Wallet) checkForExistingTransactionUpdates() {
 				"txns: %v", err)
 		return
 	}
+	w.lastCheckedTip = tip
+	w.txnsWaitingBlockchainConfirmationCount.Set(float64(waitingCount))
 
 	w.eventBroker.SendNotifications()
 
@@ -252,14 +351,39 @@ func (w *Wallet) mainLoop() (err error) {
 				withdrawRequest.hold,
 			)
 			close(withdrawRequest.result)
+		case batchRequest := <-w.batchWithdrawQueue:
+			batchRequest.result <- w.withdrawBatch(
+				batchRequest.txns,
+				batchRequest.hold,
+			)
+			close(batchRequest.result)
 		case cancelRequest := <-w.cancelQueue:
 			cancelRequest.result <- w.cancelPendingTx(cancelRequest.id)
 			close(cancelRequest.result)
 		case confirmRequest := <-w.confirmQueue:
-			confirmRequest.result <- w.confirmPendingTx(confirmRequest.id)
+			confirmRequest.result <- w.confirmPendingTx(
+				confirmRequest.id,
+				confirmRequest.approver,
+			)
 			close(confirmRequest.result)
+		case bumpFeeRequest := <-w.bumpFeeQueue:
+			bumpFeeRequest.result <- w.bumpFee(
+				bumpFeeRequest.id,
+				bumpFeeRequest.fee,
+				bumpFeeRequest.feeType,
+			)
+			close(bumpFeeRequest.result)
+		case accelerateRequest := <-w.accelerateDepositQueue:
+			accelerateRequest.result <- w.accelerateDeposit(
+				accelerateRequest.id,
+				accelerateRequest.childID,
+				accelerateRequest.feeRate,
+			)
+			close(accelerateRequest.result)
 		case <-w.pendingTxUpdateTrigger:
 			w.updatePendingTxns()
+		case <-w.batchFlushTrigger:
+			w.flushQueuedWithdrawals()
 		case <-w.stopTrigger:
 			return
 		}
@@ -273,6 +397,9 @@ func (w *Wallet) mainLoop() (err error) {
 		}
 
 		w.checkForWalletUpdates()
+		w.processScheduledWithdrawals()
+		w.checkExpiredInvoices()
+		w.sweepToColdStorage()
 
 		// check stopTrigger again to avoid executing any other requested
 		// operation if stop was requested
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -22,19 +23,50 @@ type walletData struct {
 	minWithdraw                          bitcoin.BTCAmount
 	minFeePerKb                          bitcoin.BTCAmount
 	minFeeFixed                          bitcoin.BTCAmount
+	defaultFeePayer                      bitcoin.FeePayer
+	defaultAddressType                   bitcoin.AddressType
 	minWithdrawWithoutManualConfirmation bitcoin.BTCAmount
 	maxConfirmations                     int64
+	reorgSafetyDepth                     int64
+	rebroadcastAfter                     time.Duration
+	approvalTiers                        []ApprovalTier
+	smartFeeConfTarget                   int
+	smartFeeEstimateMode                 string
+	smartFeeMinRate                      bitcoin.BTCAmount
+	smartFeeMaxRate                      bitcoin.BTCAmount
+	smartFeeFallbackRate                 bitcoin.BTCAmount
+	batchingWindow                       time.Duration
+	batchingMaxSize                      int
+	sweepHighWaterMark                   bitcoin.BTCAmount
+	sweepTarget                          bitcoin.BTCAmount
+	sweepMinAmount                       bitcoin.BTCAmount
+	sweepFee                             bitcoin.BTCAmount
+	sweepFeeType                         bitcoin.FeeType
+
+	// batchFlushTimer ends current batching window. It is only accessed from
+	// wallet updater goroutine
+	batchFlushTimer *time.Timer
+
+	// lastCheckedTip is a hash of chain tip at the moment of last check for
+	// updates on existing txns. It is only accessed from wallet updater
+	// goroutine
+	lastCheckedTip string
 
 	withdrawQueue           chan internalWithdrawRequest
+	batchWithdrawQueue      chan internalBatchWithdrawRequest
 	cancelQueue             chan internalCancelRequest
 	confirmQueue            chan internalConfirmRequest
+	bumpFeeQueue            chan internalBumpFeeRequest
+	accelerateDepositQueue  chan internalAccelerateDepositRequest
 	externalTxNotifications chan struct{}
 	pendingTxUpdateTrigger  chan struct{}
+	batchFlushTrigger       chan struct{}
 
 	stopTrigger chan struct{}
 
 	txnsWaitingManualConfirmationCount     prometheus.Gauge
 	txnsWaitingBlockchainConfirmationCount prometheus.Gauge
+	requiredFromColdStorage                prometheus.Gauge
 }
 
 // Wallet is responsible for processing and storing payments. It stores
@@ -66,13 +98,31 @@ func NewWallet(s settings.Settings, nodeAPI nodeapi.NodeAPI, eventBroker events.
 			minWithdraw:                          s.GetBTCAmount("wallet.min_withdraw"),
 			minFeePerKb:                          s.GetBTCAmount("wallet.min_fee.per_kb"),
 			minFeeFixed:                          s.GetBTCAmount("wallet.min_fee.fixed"),
+			defaultFeePayer:                      bitcoin.RecipientPaysFee,
 			minWithdrawWithoutManualConfirmation: minWithdrawWithoutManualConfirmation,
 			maxConfirmations:                     maxConfirmations,
+			reorgSafetyDepth:                     int64(s.GetInt("transaction.reorg_safety_depth")),
+			rebroadcastAfter:                     time.Duration(s.GetInt("transaction.rebroadcast_after")) * time.Second,
+			smartFeeConfTarget:                   s.GetInt("wallet.smart_fee.conf_target"),
+			smartFeeEstimateMode:                 s.GetString("wallet.smart_fee.estimate_mode"),
+			smartFeeMinRate:                      s.GetBTCAmount("wallet.smart_fee.min_rate"),
+			smartFeeMaxRate:                      s.GetBTCAmount("wallet.smart_fee.max_rate"),
+			smartFeeFallbackRate:                 s.GetBTCAmount("wallet.smart_fee.fallback_rate"),
+			batchingWindow:                       time.Duration(s.GetInt("wallet.batching.window")) * time.Millisecond,
+			batchingMaxSize:                      s.GetInt("wallet.batching.max_size"),
+			sweepHighWaterMark:                   s.GetBTCAmount("wallet.cold_storage_sweep.high_water_mark"),
+			sweepTarget:                          s.GetBTCAmount("wallet.cold_storage_sweep.target"),
+			sweepMinAmount:                       s.GetBTCAmount("wallet.cold_storage_sweep.min_amount"),
+			sweepFee:                             s.GetBTCAmount("wallet.cold_storage_sweep.fee"),
 			withdrawQueue:                        make(chan internalWithdrawRequest, internalQueueSize),
+			batchWithdrawQueue:                   make(chan internalBatchWithdrawRequest, internalQueueSize),
 			cancelQueue:                          make(chan internalCancelRequest, internalQueueSize),
 			confirmQueue:                         make(chan internalConfirmRequest, internalQueueSize),
+			bumpFeeQueue:                         make(chan internalBumpFeeRequest, internalQueueSize),
+			accelerateDepositQueue:               make(chan internalAccelerateDepositRequest, internalQueueSize),
 			externalTxNotifications:              make(chan struct{}, 3),
 			pendingTxUpdateTrigger:               make(chan struct{}, 3),
+			batchFlushTrigger:                    make(chan struct{}, 3),
 			stopTrigger:                          make(chan struct{}),
 		},
 	}
@@ -93,12 +143,19 @@ func (w *Wallet) initMetrics() {
 		Name:      "txns_waiting_blockchain_confirmation",
 		Help:      "Current number of transactions waiting for bitcoin confirmations.",
 	})
+	w.requiredFromColdStorage = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "bitcoin_processing",
+		Subsystem: "wallet",
+		Name:      "required_from_cold_storage_btc",
+		Help:      "Amount of BTC that should be transferred from cold storage to fund pending withdrawals.",
+	})
 }
 
 func (w *Wallet) registerMetrics() {
 	prometheus.DefaultRegisterer.MustRegister(
 		w.txnsWaitingManualConfirmationCount,
 		w.txnsWaitingBlockchainConfirmationCount,
+		w.requiredFromColdStorage,
 	)
 }
 
@@ -110,10 +167,19 @@ func (w *Wallet) Run() error {
 	if err != nil {
 		return err
 	}
+	if err = w.initRequiredFromColdStorageMetric(); err != nil {
+		return err
+	}
 
+	w.initDefaultAddressType()
 	w.initHotWallet()
 	w.initColdWallet()
+	w.initApprovalTiers()
+	w.initDefaultFeePayer()
+	w.initColdStorageSweep()
 	w.checkForWalletUpdates()
 	w.updatePendingTxns()
+	// send withdrawals that were queued before restart
+	w.flushQueuedWithdrawals()
 	return w.mainLoop()
 }
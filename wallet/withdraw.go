@@ -1,7 +1,6 @@
 package wallet
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"log"
@@ -19,19 +18,51 @@ const (
 	withdrawRetryBaseInterval = time.Second
 )
 
+// estimatedWithdrawalSize is a size in bytes of typical withdrawal tx (one
+// input, output to recipient and change output). It is used to estimate fee
+// of withdrawals with per KB rate that is paid by sender
+const estimatedWithdrawalSize = 250
+
+// estimatedBatchEntrySize is a size in bytes of a part of batch withdrawal tx
+// attributed to one entry (its output and one input). It is used to estimate
+// fee of batch withdrawals, which is always paid by sender
+const estimatedBatchEntrySize = 180
+
 // WithdrawRequest is a structure with parameters that can be set for new
 // withdrawal. In order to make a withdraw, caller must initialize this
 // structire and pass it to Withdraw method
 // Fields ID, FeeType and Metainfo are optional
+// ConfTarget (in blocks) and EstimateMode ("economical" or "conservative") are
+// only used with 'smart' fee type and are optional too, for this type Fee is
+// ignored and set to fee rate estimated by Bitcoin node
+// FeePayer is "recipient" or "sender", if it is empty, value of
+// "wallet.fee_payer" from config is used
 // Address can be optional for withdrawals to hot storage (because hot storage
 // address can be set in config)
+// CreatedBy is not sent by client: it is set by API server to id of API key
+// that requested withdrawal
+// AddressType is not sent by client either: it is set to type of destination
+// address when request is checked and returned to client in response
+// ExecuteAfter and ExpiresAt are optional too: withdrawal is not sent before
+// ExecuteAfter and is cancelled if it is still scheduled or pending at
+// ExpiresAt
 type WithdrawRequest struct {
-	ID       uuid.UUID         `json:"id,omitempty"`
-	Address  string            `json:"address,omitempty"`
-	Amount   bitcoin.BTCAmount `json:"amount"`
-	Fee      bitcoin.BTCAmount `json:"fee,omitempty"`
-	FeeType  string            `json:"fee_type,omitempty"`
-	Metainfo interface{}       `json:"metainfo"`
+	ID        uuid.UUID         `json:"id,omitempty"`
+	Address   string            `json:"address,omitempty"`
+	Amount    bitcoin.BTCAmount `json:"amount"`
+	Fee       bitcoin.BTCAmount `json:"fee,omitempty"`
+	FeeType   string            `json:"fee_type,omitempty"`
+	FeePayer  string            `json:"fee_payer,omitempty"`
+	Metainfo  interface{}       `json:"metainfo"`
+	CreatedBy string            `json:"-"`
+
+	AddressType bitcoin.AddressType `json:"address_type,omitempty"`
+
+	ConfTarget   int    `json:"conf_target,omitempty"`
+	EstimateMode string `json:"estimate_mode,omitempty"`
+
+	ExecuteAfter *time.Time `json:"execute_after,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
 }
 
 type internalWithdrawRequest struct {
@@ -54,6 +85,36 @@ func logWithdrawRequest(request *WithdrawRequest, feeType bitcoin.FeeType) {
 	)
 }
 
+func (w *Wallet) initDefaultFeePayer() {
+	feePayerStr := w.settings.GetString("wallet.fee_payer")
+	if feePayerStr == "" {
+		return
+	}
+	feePayer, err := bitcoin.FeePayerFromString(feePayerStr)
+	if err != nil || feePayer == bitcoin.InvalidFeePayer {
+		log.Fatalf("Invalid wallet.fee_payer %q in config: should be "+
+			"'recipient' or 'sender'", feePayerStr)
+	}
+	w.defaultFeePayer = feePayer
+}
+
+// withdrawalCost estimates amount of money needed to send withdrawal: its
+// amount plus fee if fee is paid by sender. For per KB fee rate it is
+// calculated for tx of typical size
+func withdrawalCost(tx *types.Transaction) int64 {
+	cost := int64(tx.Amount)
+	if tx.FeePayer != bitcoin.SenderPaysFee {
+		return cost
+	}
+	if tx.FeeType == bitcoin.FixedFee {
+		return cost + int64(tx.Fee)
+	}
+	if tx.BatchID != nil {
+		return cost + int64(tx.Fee)*estimatedBatchEntrySize/1000
+	}
+	return cost + int64(tx.Fee)*estimatedWithdrawalSize/1000
+}
+
 func isInsufficientFundsError(err error) bool {
 	rpcError, ok := err.(*nodeapi.JSONRPCError)
 	if !ok {
@@ -64,26 +125,36 @@ func isInsufficientFundsError(err error) bool {
 
 func (w *Wallet) checkWithdrawLimits(request *WithdrawRequest, feeType bitcoin.FeeType) (needManualConfirmation bool, err error) {
 	if request.Amount < w.minWithdraw {
-		return false, errors.New(
-			"Error: refusing to withdraw " + request.Amount.String() +
-				" because it is less than min withdraw amount " +
-				w.minWithdraw.String(),
+		return false, newError(
+			ErrorCodeAmountBelowMinimum,
+			"Error: refusing to withdraw %s because it is less than min "+
+				"withdraw amount %s",
+			request.Amount,
+			w.minWithdraw,
 		)
 	}
 
-	if feeType == bitcoin.PerKBRateFee && request.Fee < w.minFeePerKb {
-		return false, errors.New(
-			"Error: refusing to withdraw with fee " + request.Fee.String() +
-				" because it is less than min withdraw fee " +
-				w.minFeePerKb.String() + " for fee type " + feeType.String(),
+	isRate := feeType == bitcoin.PerKBRateFee || feeType == bitcoin.SmartFee
+
+	if isRate && request.Fee < w.minFeePerKb {
+		return false, newError(
+			ErrorCodeFeeBelowMinimum,
+			"Error: refusing to withdraw with fee %s because it is less than "+
+				"min withdraw fee %s for fee type %s",
+			request.Fee,
+			w.minFeePerKb,
+			feeType,
 		)
 	}
 
 	if feeType == bitcoin.FixedFee && request.Fee < w.minFeeFixed {
-		return false, errors.New(
-			"Error: refusing to withdraw with fee " + request.Fee.String() +
-				" because it is less than min withdraw fee " +
-				w.minFeeFixed.String() + " for fee type " + feeType.String(),
+		return false, newError(
+			ErrorCodeFeeBelowMinimum,
+			"Error: refusing to withdraw with fee %s because it is less than "+
+				"min withdraw fee %s for fee type %s",
+			request.Fee,
+			w.minFeeFixed,
+			feeType,
 		)
 	}
 
@@ -91,23 +162,54 @@ func (w *Wallet) checkWithdrawLimits(request *WithdrawRequest, feeType bitcoin.F
 		return true, nil
 	}
 
+	if w.tierApprovals(request.Amount) > 0 {
+		return true, nil
+	}
+
 	return false, nil
 }
 
+func checkWithdrawSchedule(request *WithdrawRequest, toColdStorage bool) error {
+	if request.ExecuteAfter == nil && request.ExpiresAt == nil {
+		return nil
+	}
+	if toColdStorage {
+		return newError(
+			ErrorCodeInvalidRequest,
+			"Withdrawals to cold storage can't be scheduled or expire",
+		)
+	}
+	if request.ExpiresAt == nil {
+		return nil
+	}
+	if !request.ExpiresAt.After(time.Now()) {
+		return newError(
+			ErrorCodeInvalidRequest,
+			"Withdrawal expiration time %s is in the past",
+			request.ExpiresAt,
+		)
+	}
+	if request.ExecuteAfter != nil && !request.ExpiresAt.After(*request.ExecuteAfter) {
+		return newError(
+			ErrorCodeInvalidRequest,
+			"Withdrawal expiration time %s is not later than its scheduled "+
+				"execution time %s",
+			request.ExpiresAt,
+			request.ExecuteAfter,
+		)
+	}
+	return nil
+}
+
 func (w *Wallet) ensureTxIDIsFree(id uuid.UUID) error {
 	_, err := w.storage.GetTransactionByID(id)
 
-	switch err {
-	case sql.ErrNoRows:
+	switch {
+	case err == nil:
+		return newError(ErrorCodeDuplicateID, "Tx with id %s already exists", id)
+	case isNoTxWithSuchIDError(err):
 		return nil
-	case nil:
-		return fmt.Errorf("Tx with id %s already exists", id)
 	default:
-		// XXX: Should be revised.
-		// Such error is returned by memory storage.
-		if _, ok := err.(ErrNoTxWithSuchID); ok {
-			return nil
-		}
 		return err
 	}
 }
@@ -121,7 +223,13 @@ func (w *Wallet) internalWithdrawBetweenOurAccounts(tx *types.Transaction, accou
 		tx.Fee = 0
 	}
 
-	if tx.Amount < tx.Fee {
+	// if fee is paid by sender, recipient gets the exact amount
+	recipientFee := tx.Fee
+	if tx.FeePayer == bitcoin.SenderPaysFee {
+		recipientFee = 0
+	}
+
+	if tx.Amount < recipientFee {
 		return fmt.Errorf(
 			"Internal withdraw fee %s is larger than withdraw amount %s",
 			tx.Fee, tx.Amount)
@@ -151,7 +259,7 @@ func (w *Wallet) internalWithdrawBetweenOurAccounts(tx *types.Transaction, accou
 		tx.Direction = types.IncomingDirection
 		tx.Metainfo = account.Metainfo
 		tx.ID = uuid.Nil
-		tx.Amount -= tx.Fee
+		tx.Amount -= recipientFee
 
 		tx, err := currWallet.storage.StoreTransaction(tx)
 
@@ -180,8 +288,10 @@ func (w *Wallet) sendWithdrawal(tx *types.Transaction, updatePending bool) error
 		return w.internalWithdrawBetweenOurAccounts(tx, ourAccount)
 	}
 
+	w.reestimateSmartFee([]*types.Transaction{tx})
+
 	switch tx.FeeType {
-	case bitcoin.PerKBRateFee:
+	case bitcoin.PerKBRateFee, bitcoin.SmartFee:
 		sendMoneyFunc = w.nodeAPI.SendWithPerKBFee
 	case bitcoin.FixedFee:
 		sendMoneyFunc = w.nodeAPI.SendWithFixedFee
@@ -189,31 +299,46 @@ func (w *Wallet) sendWithdrawal(tx *types.Transaction, updatePending bool) error
 		return errors.New("Fee type not supported: " + tx.FeeType.String())
 	}
 
-	err = w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
-		return currWallet.storage.LockWallet(map[string]interface{}{
+	return w.broadcastWithdrawal(
+		[]*types.Transaction{tx},
+		map[string]interface{}{
 			"operation": "withdraw",
 			"tx":        tx,
-		})
+		},
+		func() (string, error) {
+			return sendMoneyFunc(
+				tx.Address,
+				tx.Amount,
+				tx.Fee,
+				tx.FeePayer != bitcoin.SenderPaysFee,
+			)
+		},
+		updatePending,
+	)
+}
+
+// broadcastWithdrawal sends Bitcoin tx that pays given withdrawals using
+// sendFunc and stores result. Wallet is locked with lockData for the time
+// of sending so that processing refuses to start if it is interrupted in the
+// middle (see Check)
+func (w *Wallet) broadcastWithdrawal(txns []*types.Transaction, lockData map[string]interface{}, sendFunc func() (string, error), updatePending bool) error {
+	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
+		return currWallet.storage.LockWallet(lockData)
 	})
 
 	if err != nil {
 		return err
 	}
 
-	txHash, err := sendMoneyFunc(
-		tx.Address,
-		tx.Amount,
-		tx.Fee,
-		true, // recipient pays tx fee
-	)
+	txHash, err := sendFunc()
 
 	if err != nil {
-		err = w.handleWithdrawalError(err, tx)
+		err = w.handleWithdrawalError(err, txns)
 		if err != nil {
 			return err
 		}
 	} else {
-		w.handleWithdrawalSuccess(tx, txHash)
+		w.handleWithdrawalSuccess(txns, txHash)
 	}
 
 	w.eventBroker.SendNotifications()
@@ -228,51 +353,69 @@ func (w *Wallet) sendWithdrawal(tx *types.Transaction, updatePending bool) error
 	return nil
 }
 
-func (w *Wallet) handleWithdrawalSuccess(tx *types.Transaction, txHash string) {
-	tx.Status = types.NewTransaction
-	tx.Hash = txHash
+func (w *Wallet) handleWithdrawalSuccess(txns []*types.Transaction, txHash string) {
+	for _, tx := range txns {
+		tx.Status = types.NewTransaction
+		tx.Hash = txHash
 
-	log.Printf(
-		"Successfully created and broadcasted outgoing tx (withdrawal) %v",
-		tx,
-	)
+		log.Printf(
+			"Successfully created and broadcasted outgoing tx (withdrawal) %v",
+			tx,
+		)
+	}
 
 	persistWithdrawResultWithRetry(func() error {
 		return w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
-			_, err := currWallet.storage.StoreTransaction(tx)
-			if err != nil {
-				return err
-			}
-			if !tx.ColdStorage {
-				err = currWallet.notifyTransaction(tx)
-
+			for _, tx := range txns {
+				_, err := currWallet.storage.StoreTransaction(tx)
 				if err != nil {
 					return err
 				}
+				if tx.FeeType == bitcoin.SmartFee {
+					// rate might have been estimated again before sending
+					err = currWallet.storage.updateFee(tx)
+					if err != nil {
+						return err
+					}
+				}
+				if !tx.ColdStorage {
+					err = currWallet.notifyTransaction(tx)
+
+					if err != nil {
+						return err
+					}
+				}
 			}
 			return currWallet.storage.ClearWallet()
 		})
 	}, nil, false)
 }
 
-func (w *Wallet) handleWithdrawalError(err error, tx *types.Transaction) error {
+func (w *Wallet) handleWithdrawalError(err error, txns []*types.Transaction) error {
+	tx := txns[0]
 	makePending := false
 	if isInsufficientFundsError(err) && !tx.ColdStorage {
 		// this is a regular withdrawal and we got response that we
 		// don't have enough funds to send it: OK, make this tx pending
-		log.Printf("Not enough funds to send tx %v, marking as pending", tx)
+		// (batch withdrawal becomes pending as a whole)
+		for _, tx := range txns {
+			log.Printf("Not enough funds to send tx %v, marking as pending", tx)
+		}
 		makePending = true
 	}
 
 	persistWithdrawResultWithRetry(func() error {
 		return w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
-			// updatePendingTxStatus modifies tx and we want it to be the same
-			// as original in case of retry. That's why, make a copy here.
-			updateTx := *tx
 			if makePending {
-				err := currWallet.updatePendingTxStatus(&updateTx, types.PendingTransaction)
-				if err != nil {
-					return err
+				for _, tx := range txns {
+					// updatePendingTxStatus modifies tx and we want it to be
+					// the same as original in case of retry. That's why, make
+					// a copy here.
+					updateTx := *tx
+					err := currWallet.updatePendingTxStatus(&updateTx, types.PendingTransaction)
+					if err != nil {
+						return err
+					}
 				}
 			}
 			return currWallet.storage.ClearWallet()
@@ -280,6 +423,14 @@ func (w *Wallet) handleWithdrawalError(err error, tx *types.Transaction) error {
 	}, err, makePending)
 
 	if !makePending {
+		if isInsufficientFundsError(err) {
+			return newError(
+				ErrorCodeInsufficientFunds,
+				"Not enough funds to send withdrawal %s of %s to cold storage",
+				tx.ID,
+				tx.Amount,
+			)
+		}
 		return err
 	}
 	return nil
@@ -346,6 +497,7 @@ func (w *Wallet) withdrawViaWalletUpdater(tx *types.Transaction, hold bool) erro
 }
 
 func (w *Wallet) holdWithdrawalUntilConfirmed(tx *types.Transaction) error {
+	tx.RequiredApprovals = w.requiredApprovals(tx.Amount)
 	err := w.MakeTransactIfAvailable(func(currWallet *Wallet) error {
 		return currWallet.updatePendingTxStatus(
 			tx,
@@ -370,14 +522,31 @@ func (w *Wallet) withdraw(tx *types.Transaction, hold bool) error {
 
 	if hold {
 		log.Printf(
-			"Withdrawal %v has amount %s which is more than configured max "+
-				"amount to be processed without manual confirmation. Holding it "+
-				"until confirmed manually.",
+			"Withdrawal %v has amount %s which requires manual confirmation. "+
+				"Holding it until confirmed by %d approver(s).",
 			tx,
 			tx.Amount,
+			w.requiredApprovals(tx.Amount),
 		)
 		return w.holdWithdrawalUntilConfirmed(tx)
 	}
+
+	if isScheduledForLater(tx) {
+		return w.scheduleWithdrawal(tx)
+	}
+	return w.dispatchWithdrawal(tx)
+}
+
+// dispatchWithdrawal sends withdrawal that is ready to be sent or queues it
+// to be sent in batch
+func (w *Wallet) dispatchWithdrawal(tx *types.Transaction) error {
+	batchable, err := w.isBatchable(tx)
+	if err != nil {
+		return err
+	}
+	if batchable {
+		return w.queueWithdrawal(tx)
+	}
 	return w.sendWithdrawal(tx, true)
 }
 
@@ -386,10 +555,13 @@ func (w *Wallet) withdraw(tx *types.Transaction, hold bool) error {
 // tries to perform a withdrawal.
 // Withdrawal to hot wallet address are not allowed.
 // Regular withdrawal may require manual confirmation if its amount exceeds a
-// certain value ("wallet.min_withdraw_without_manual_confirmation" in config).
+// certain value ("wallet.min_withdraw_without_manual_confirmation" in config)
+// or falls into one of approval tiers ("wallet.approval_tiers" in config) which
+// also set how many distinct approvers have to confirm it.
 // There are restrictions on minimal withdrawal amount and fee value (set in
 // config by "wallet.min_withdraw", "wallet.min_fee.per_kb",
-// "wallet.min_fee.fixed")
+// "wallet.min_fee.fixed", rate estimated for 'smart' fee type is checked
+// against "wallet.min_fee.per_kb")
 // If withdrawal is allowed, but there is not enough money to send it, it
 // becomes pending (it will receive status 'pending' which may be then changed
 // to 'pending-cold-storage')
@@ -402,11 +574,37 @@ func (w *Wallet) withdraw(tx *types.Transaction, hold bool) error {
 func (w *Wallet) Withdraw(request *WithdrawRequest, toColdStorage bool) error {
 	feeType, err := bitcoin.FeeTypeFromString(request.FeeType)
 	if err != nil {
-		return err
+		return &Error{Code: ErrorCodeInvalidRequest, Message: err.Error()}
+	}
+
+	feePayer := w.defaultFeePayer
+	if request.FeePayer != "" {
+		feePayer, err = bitcoin.FeePayerFromString(request.FeePayer)
+		if err != nil || feePayer == bitcoin.InvalidFeePayer {
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Invalid fee payer %q: should be 'recipient' or 'sender'",
+				request.FeePayer,
+			)
+		}
+	}
+
+	if feeType == bitcoin.SmartFee {
+		// smart fee is resolved to a rate right away, so it is stored with
+		// tx and checked against min fee like a regular per KB rate. Rate is
+		// estimated again when withdrawal is actually sent
+		request.Fee, err = w.smartFeeRate(request.ConfTarget, request.EstimateMode)
+		if err != nil {
+			return err
+		}
 	}
 
 	logWithdrawRequest(request, feeType)
 
+	if err = checkWithdrawSchedule(request, toColdStorage); err != nil {
+		return err
+	}
+
 	needManualConfirmation := false
 
 	// do not check limits for cold storage withdrawals
@@ -419,11 +617,15 @@ func (w *Wallet) Withdraw(request *WithdrawRequest, toColdStorage bool) error {
 
 	if request.Address == "" {
 		if !toColdStorage {
-			return errors.New("Can't process withdraw: address is empty")
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Can't process withdraw: address is empty",
+			)
 		}
 		if w.coldWalletAddress == "" {
-			return errors.New(
-				"Withdraw to cold storage failed: address is not given in " +
+			return newError(
+				ErrorCodeInvalidRequest,
+				"Withdraw to cold storage failed: address is not given in "+
 					"request and not set in config",
 			)
 		}
@@ -435,13 +637,23 @@ func (w *Wallet) Withdraw(request *WithdrawRequest, toColdStorage bool) error {
 	}
 
 	if request.Address == w.hotWalletAddress {
-		return errors.New(
-			"Refusing to withdraw to hot wallet address: this operation " +
-				"makes no sence because hot wallet address belongs to " +
+		return newError(
+			ErrorCodeHotWalletAddress,
+			"Refusing to withdraw to hot wallet address: this operation "+
+				"makes no sence because hot wallet address belongs to "+
 				"wallet of bitcoin processing app",
 		)
 	}
 
+	request.AddressType, err = bitcoin.AddressTypeOf(request.Address)
+	if err != nil {
+		return newError(
+			ErrorCodeInvalidRequest,
+			"Can't process withdraw: %v",
+			err,
+		)
+	}
+
 	outgoingTx := &types.Transaction{
 		ID:                    request.ID,
 		Confirmations:         0,
@@ -451,9 +663,17 @@ func (w *Wallet) Withdraw(request *WithdrawRequest, toColdStorage bool) error {
 		Metainfo:              request.Metainfo,
 		Fee:                   request.Fee,
 		FeeType:               feeType,
+		FeePayer:              feePayer,
 		ColdStorage:           toColdStorage,
 		Fresh:                 true,
 		ReportedConfirmations: -1,
+		CreatedBy:             request.CreatedBy,
+		ExecuteAfter:          request.ExecuteAfter,
+		ExpiresAt:             request.ExpiresAt,
+	}
+	if feeType == bitcoin.SmartFee {
+		outgoingTx.ConfTarget = request.ConfTarget
+		outgoingTx.EstimateMode = request.EstimateMode
 	}
 
 	// withdraw to cold storage does not need confirmation
@@ -28,7 +28,7 @@ func (n *nodeAPIBalanceAndAddressMock) GetConfirmedAndUnconfirmedBalance() (uint
 	return 1, 0, nil
 }
 
-func (n *nodeAPIBalanceAndAddressMock) CreateNewAddress() (string, error) {
+func (n *nodeAPIBalanceAndAddressMock) CreateNewAddress(addressType bitcoin.AddressType) (string, error) {
 	return testAddress, nil
 }
 
@@ -128,7 +128,7 @@ func TestInternalWithdraw(t *testing.T) {
 	ws := NewStorage(nil)
 	w := NewWallet(s, n, e, ws)
 
-	acct, _ := w.CreateAccount(nil)
+	acct, _ := w.CreateAccount(nil, bitcoin.InvalidAddressType)
 
 	tx := &types.Transaction{
 		ID:                    testTxID,
@@ -235,7 +235,7 @@ func TestInternalWithdrawToColdStorage(t *testing.T) {
 	ws := NewStorage(nil)
 	w := NewWallet(s, n, e, ws)
 
-	acct, _ := w.CreateAccount(nil)
+	acct, _ := w.CreateAccount(nil, bitcoin.InvalidAddressType)
 
 	tx := &types.Transaction{
 		ID:                    testTxID,
@@ -311,7 +311,7 @@ func TestInternalWithdrawWhenFeeExceedsAmount(t *testing.T) {
 	ws := NewStorage(nil)
 	w := NewWallet(s, n, e, ws)
 
-	acct, _ := w.CreateAccount(nil)
+	acct, _ := w.CreateAccount(nil, bitcoin.InvalidAddressType)
 
 	tx := &types.Transaction{
 		ID:                    testTxID,
@@ -339,3 +339,128 @@ func TestInternalWithdrawWhenFeeExceedsAmount(t *testing.T) {
 			"but it generated %d events", len(e.log))
 	}
 }
+
+func TestWithdrawErrorCodes(t *testing.T) {
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"wallet.min_withdraw":  bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.001")),
+			"wallet.min_fee.fixed": bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+		},
+	}
+	n := &nodeAPIBalanceAndAddressMock{}
+	e := &loggingEventBrokerMock{}
+	ws := NewStorage(nil)
+	w := NewWallet(s, n, e, ws)
+
+	tests := []struct {
+		name     string
+		request  WithdrawRequest
+		wantCode ErrorCode
+	}{
+		{
+			name: "small amount",
+			request: WithdrawRequest{
+				Address: testAddress,
+				Amount:  bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+				Fee:     bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+				FeeType: "fixed",
+			},
+			wantCode: ErrorCodeAmountBelowMinimum,
+		},
+		{
+			name: "small fee",
+			request: WithdrawRequest{
+				Address: testAddress,
+				Amount:  bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")),
+				Fee:     bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.00001")),
+				FeeType: "fixed",
+			},
+			wantCode: ErrorCodeFeeBelowMinimum,
+		},
+		{
+			name: "unknown fee type",
+			request: WithdrawRequest{
+				Address: testAddress,
+				Amount:  bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("1")),
+				FeeType: "free",
+			},
+			wantCode: ErrorCodeInvalidRequest,
+		},
+	}
+
+	for _, test := range tests {
+		err := w.Withdraw(&test.request, false)
+		walletErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Withdraw with %s: expected *Error, got %v", test.name, err)
+			continue
+		}
+		if walletErr.Code != test.wantCode {
+			t.Errorf("Withdraw with %s: expected error code %s, got %s",
+				test.name, test.wantCode, walletErr.Code)
+		}
+	}
+}
+
+func TestWithdrawAddressType(t *testing.T) {
+	tests := []struct {
+		address  string
+		wantType bitcoin.AddressType
+	}{
+		{testAddress, bitcoin.LegacyAddress},
+		{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", bitcoin.LegacyAddress},
+		{"3QJmV3qfvL9SuYo34YihAf3sRCW3qSinyC", bitcoin.P2SHSegwitAddress},
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", bitcoin.Bech32Address},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", bitcoin.Bech32Address},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", bitcoin.Bech32mAddress},
+		// invalid base58 checksum
+		{"1MirQ9bwyQcGVJPwKUgapu5ouK2E2Ey4gY", bitcoin.InvalidAddressType},
+		// bech32m checksum with witness version 0
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", bitcoin.InvalidAddressType},
+		// bech32 checksum with witness version 1
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7k7grplx", bitcoin.InvalidAddressType},
+		// mixed case
+		{"bc1qW508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", bitcoin.InvalidAddressType},
+		{"not an address", bitcoin.InvalidAddressType},
+	}
+	s := &settingstestutil.SettingsMock{
+		Data: map[string]interface{}{
+			"wallet.min_fee.fixed": bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+		},
+	}
+	w := NewWallet(s, &nodeAPIBalanceAndAddressMock{}, &loggingEventBrokerMock{}, NewStorage(nil))
+	// withdrawal is accepted once it gets to wallet updater
+	go func() {
+		for request := range w.withdrawQueue {
+			close(request.result)
+		}
+	}()
+	defer close(w.withdrawQueue)
+
+	for _, test := range tests {
+		request := &WithdrawRequest{
+			ID:      uuid.Must(uuid.NewV4()),
+			Address: test.address,
+			Amount:  bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.1")),
+			Fee:     bitcoin.Must(bitcoin.BTCAmountFromStringedFloat("0.0001")),
+			FeeType: "fixed",
+		}
+		err := w.Withdraw(request, false)
+
+		if test.wantType == bitcoin.InvalidAddressType {
+			if walletErr, ok := err.(*Error); !ok || walletErr.Code != ErrorCodeInvalidRequest {
+				t.Errorf("Withdraw to %s: expected %s error, got %v",
+					test.address, ErrorCodeInvalidRequest, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Withdraw to %s: unexpected error %v", test.address, err)
+			continue
+		}
+		if request.AddressType != test.wantType {
+			t.Errorf("Withdraw to %s: expected address type %s, got %s",
+				test.address, test.wantType, request.AddressType)
+		}
+	}
+}